@@ -4,7 +4,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/bcrowe306/nltst_scheduler.git/services"
 	"github.com/joho/godotenv"
 )
 
@@ -20,7 +25,36 @@ type Config struct {
 	ClickSendUsername   string
 	ClickSendPassword   string
 	ClickSendFromNumber string
+	TextInChurchAPIKey  string
+	TextInChurchAPIURL  string
 	SendGridAPIKey      string
+	SendGridFromEmail   string
+	SendGridWebhookKey  string
+	SMTPHost            string
+	SMTPPort            int
+	SMTPUsername        string
+	SMTPPassword        string
+	SMTPFromEmail       string
+	SMTPFromName        string
+	SMTPSecurity        string
+	SMTPInsecure        bool
+	TelegramBotToken    string
+	TelegramBotUsername string
+	TelegramAPIURL      string
+	TelegramSecret      string
+	SMSProviders        []string
+	EmailProviders      []string
+	FakeNotifyFile      string
+	BaseURL             string
+	LinkSigningSecret   string
+	TimeZone            string
+	ReminderInterval    time.Duration
+	OutboxInterval      time.Duration
+	ScheduleChangeDelay time.Duration
+	DigestWeekdays      []time.Weekday
+	DigestTime          string
+	DigestDays          int
+	DigestSkipEmpty     bool
 	Port                string
 }
 
@@ -63,11 +97,142 @@ func LoadConfig() (*Config, error) {
 		log.Print("ClickSend Credentials are not supplied. SMS will not work")
 	}
 
+	// TextInChurch. The API URL can point at a local stub server.
+	textInChurchAPIKey := os.Getenv("TEXT_IN_CHURCH_API_KEY")
+	textInChurchAPIURL := os.Getenv("TEXT_IN_CHURCH_API_URL")
+	if textInChurchAPIURL == "" {
+		textInChurchAPIURL = "https://api.textinchurch.com/v1"
+	}
+
 	// SendGrid API Key
 	sendGridAPIKey := os.Getenv("SENDGRID_API_KEY")
 	if sendGridAPIKey == "" {
 		log.Print("SendGrid API Key is not supplied. Email functionality will not work")
 	}
+	sendGridFromEmail := os.Getenv("SENDGRID_FROM_EMAIL")
+	if sendGridAPIKey != "" && sendGridFromEmail == "" {
+		log.Print("SENDGRID_FROM_EMAIL is not supplied. Emails will not be sent")
+	}
+
+	// Verification key of SendGrid's signed event webhook, for delivery
+	// status
+	sendGridWebhookKey := os.Getenv("SENDGRID_WEBHOOK_KEY")
+	if sendGridWebhookKey != "" {
+		if _, err := services.ParseSendGridPublicKey(sendGridWebhookKey); err != nil {
+			return nil, fmt.Errorf("invalid SENDGRID_WEBHOOK_KEY: %w", err)
+		}
+	}
+
+	// SMTP email, for Gmail, Office 365, a self-hosted relay or a local sink
+	smtpHost := os.Getenv("SMTP_HOST")
+	smtpFromEmail := os.Getenv("SMTP_FROM_EMAIL")
+	if smtpHost != "" && smtpFromEmail == "" {
+		log.Print("SMTP_FROM_EMAIL is not supplied. Emails will not be sent over SMTP")
+	}
+	smtpPort := 0
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		smtpPort, err = strconv.Atoi(v)
+		if err != nil || smtpPort <= 0 || smtpPort > 65535 {
+			return nil, fmt.Errorf("invalid SMTP_PORT %q", v)
+		}
+	}
+	smtpSecurity := strings.ToLower(os.Getenv("SMTP_SECURITY"))
+	switch smtpSecurity {
+	case "":
+		smtpSecurity = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("invalid SMTP_SECURITY %q", smtpSecurity)
+	}
+	smtpInsecure := false
+	if v := os.Getenv("SMTP_TLS_INSECURE"); v != "" {
+		smtpInsecure, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_TLS_INSECURE %q", v)
+		}
+	}
+
+	// Telegram bot. The API URL can point at a local stub server.
+	telegramBotToken := os.Getenv("TELEGRAM_BOT_TOKEN")
+	telegramBotUsername := os.Getenv("TELEGRAM_BOT_USERNAME")
+	telegramAPIURL := os.Getenv("TELEGRAM_API_URL")
+	if telegramAPIURL == "" {
+		telegramAPIURL = "https://api.telegram.org"
+	}
+	telegramSecret := os.Getenv("TELEGRAM_WEBHOOK_SECRET")
+	if telegramBotToken != "" && telegramBotUsername == "" {
+		log.Print("TELEGRAM_BOT_USERNAME is not supplied. Members can't be sent Telegram links")
+	}
+	if telegramBotToken != "" && telegramSecret == "" {
+		log.Print("TELEGRAM_WEBHOOK_SECRET is not supplied. The Telegram bot will not receive messages")
+	}
+
+	// Notification providers, in failover order. "fake" records messages
+	// instead of sending them, to NOTIFY_FAKE_FILE when set.
+	smsProviders, err := providerList("SMS_PROVIDERS", "twilio,clicksend", "twilio", "clicksend", "textinchurch", "fake")
+	if err != nil {
+		return nil, err
+	}
+	emailProviders, err := providerList("EMAIL_PROVIDERS", "sendgrid", "sendgrid", "smtp", "fake")
+	if err != nil {
+		return nil, err
+	}
+	fakeNotifyFile := os.Getenv("NOTIFY_FAKE_FILE")
+
+	// How often the reminder worker checks for due reminders
+	reminderInterval := time.Minute
+	if v := os.Getenv("REMINDER_POLL_INTERVAL"); v != "" {
+		reminderInterval, err = time.ParseDuration(v)
+		if err != nil || reminderInterval <= 0 {
+			return nil, fmt.Errorf("invalid REMINDER_POLL_INTERVAL %q", v)
+		}
+	}
+
+	// How often the outbox worker checks for messages to deliver
+	outboxInterval := 15 * time.Second
+	if v := os.Getenv("OUTBOX_POLL_INTERVAL"); v != "" {
+		outboxInterval, err = time.ParseDuration(v)
+		if err != nil || outboxInterval <= 0 {
+			return nil, fmt.Errorf("invalid OUTBOX_POLL_INTERVAL %q", v)
+		}
+	}
+
+	// How long to collect schedule changes before telling a member about them
+	scheduleChangeDelay := 5 * time.Minute
+	if v := os.Getenv("SCHEDULE_CHANGE_DELAY"); v != "" {
+		scheduleChangeDelay, err = time.ParseDuration(v)
+		if err != nil || scheduleChangeDelay <= 0 {
+			return nil, fmt.Errorf("invalid SCHEDULE_CHANGE_DELAY %q", v)
+		}
+	}
+
+	// When members get the digest of their upcoming schedule, and how many
+	// days it covers
+	digestWeekdays, err := weekdayList("DIGEST_DAY", "sunday")
+	if err != nil {
+		return nil, err
+	}
+	digestTime := os.Getenv("DIGEST_TIME")
+	if digestTime == "" {
+		digestTime = "16:00"
+	}
+	if _, err := time.Parse("15:04", digestTime); err != nil {
+		return nil, fmt.Errorf("invalid DIGEST_TIME %q", digestTime)
+	}
+	digestDays := 7
+	if v := os.Getenv("DIGEST_DAYS"); v != "" {
+		digestDays, err = strconv.Atoi(v)
+		if err != nil || digestDays <= 0 {
+			return nil, fmt.Errorf("invalid DIGEST_DAYS %q", v)
+		}
+	}
+	digestSkipEmpty := true
+	if v := os.Getenv("DIGEST_SKIP_EMPTY"); v != "" {
+		digestSkipEmpty, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DIGEST_SKIP_EMPTY %q", v)
+		}
+	}
 
 	// Application Port
 	port := os.Getenv("PORT")
@@ -75,6 +240,25 @@ func LoadConfig() (*Config, error) {
 		port = "8080" // default port
 	}
 
+	// Public URL used when building links sent to members
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+
+	// Secret for signing links sent to members
+	linkSigningSecret := os.Getenv("LINK_SIGNING_SECRET")
+
+	// Organization time zone. Event times are entered and shown in this zone.
+	timeZone := os.Getenv("TIMEZONE")
+	if timeZone == "" {
+		log.Print("TIMEZONE is not supplied. Using the server's local time zone")
+		timeZone = "Local"
+	}
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return nil, fmt.Errorf("invalid TIMEZONE %q: %w", timeZone, err)
+	}
+
 	return &Config{
 		MongoURI:            mongoURI,
 		MongoDatabase:       mongoDB,
@@ -86,7 +270,90 @@ func LoadConfig() (*Config, error) {
 		ClickSendAPIKey:     clicksendAPIKey,
 		ClickSendUsername:   clicksendUsername,
 		ClickSendFromNumber: clicksendFromNumber,
+		TextInChurchAPIKey:  textInChurchAPIKey,
+		TextInChurchAPIURL:  textInChurchAPIURL,
 		SendGridAPIKey:      sendGridAPIKey,
+		SendGridFromEmail:   sendGridFromEmail,
+		SendGridWebhookKey:  sendGridWebhookKey,
+		SMTPHost:            smtpHost,
+		SMTPPort:            smtpPort,
+		SMTPUsername:        os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:        os.Getenv("SMTP_PASSWORD"),
+		SMTPFromEmail:       smtpFromEmail,
+		SMTPFromName:        os.Getenv("SMTP_FROM_NAME"),
+		SMTPSecurity:        smtpSecurity,
+		SMTPInsecure:        smtpInsecure,
+		TelegramBotToken:    telegramBotToken,
+		TelegramBotUsername: telegramBotUsername,
+		TelegramAPIURL:      telegramAPIURL,
+		TelegramSecret:      telegramSecret,
+		SMSProviders:        smsProviders,
+		EmailProviders:      emailProviders,
+		FakeNotifyFile:      fakeNotifyFile,
+		BaseURL:             baseURL,
+		LinkSigningSecret:   linkSigningSecret,
+		TimeZone:            timeZone,
+		ReminderInterval:    reminderInterval,
+		OutboxInterval:      outboxInterval,
+		ScheduleChangeDelay: scheduleChangeDelay,
+		DigestWeekdays:      digestWeekdays,
+		DigestTime:          digestTime,
+		DigestDays:          digestDays,
+		DigestSkipEmpty:     digestSkipEmpty,
 		Port:                port,
 	}, nil
 }
+
+// providerList reads a comma separated list of provider names from the
+// environment variable key, checking each against known.
+func providerList(key, fallback string, known ...string) ([]string, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		value = fallback
+	}
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("unknown provider %q in %s", name, key)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// weekdayList reads a comma separated list of weekday names, such as
+// "sunday" or "sun,wed", from the environment variable key. "daily" means
+// every day and "off" none.
+func weekdayList(key, fallback string) ([]time.Weekday, error) {
+	value := strings.ToLower(os.Getenv(key))
+	if value == "" {
+		value = fallback
+	}
+	if strings.TrimSpace(value) == "off" {
+		return nil, nil
+	}
+	if strings.TrimSpace(value) == "daily" {
+		value = "sun,mon,tue,wed,thu,fri,sat"
+	}
+	var days []time.Weekday
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			full := strings.ToLower(day.String())
+			if name == full || name == full[:3] {
+				days = append(days, day)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown day %q in %s", name, key)
+		}
+	}
+	return days, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/bcrowe306/nltst_scheduler.git/models"
 
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
@@ -41,14 +42,98 @@ func createDbSchema(config *Config, database *mongo.Database) {
 		Options: options.Index().SetUnique(true),
 	})
 
-	createCollection(database, "members")
+	membersColl := createCollection(database, "members")
+	membersColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "phoneNormalized", Value: 1}},
+	})
 	teamsColl := createCollection(database, "teams")
 	teamsColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
 		Keys:    map[string]interface{}{"name": 1},
 		Options: options.Index().SetUnique(true),
 	})
 	createCollection(database, "event_templates")
-	createCollection(database, "events")
+	eventsColl := createCollection(database, "events")
+	eventsColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: map[string]interface{}{"seriesId": 1},
+	})
+	eventsColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "reminderEnabled", Value: 1}, {Key: "date", Value: 1}},
+	})
+	createCollection(database, "event_series")
+	qualificationsColl := createCollection(database, "qualifications")
+	qualificationsColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "teamId", Value: 1}, {Key: "memberId", Value: 1}, {Key: "positionName", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	outboxColl := createCollection(database, models.OutboxCollection)
+	outboxColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "idempotencyKey", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"idempotencyKey": bson.M{"$exists": true}}),
+	})
+	outboxColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}},
+	})
+	outboxColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "createdAt", Value: -1}},
+	})
+	outboxColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "provider", Value: 1}, {Key: "providerId", Value: 1}},
+	})
+	outboxColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "broadcastId", Value: 1}},
+		Options: options.Index().
+			SetPartialFilterExpression(bson.M{"broadcastId": bson.M{"$exists": true}}),
+	})
+	broadcastsColl := createCollection(database, models.BroadcastCollection)
+	broadcastsColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "createdAt", Value: -1}},
+	})
+	createCollection(database, models.DigestCollection)
+	scheduleChangesColl := createCollection(database, models.ScheduleChangeCollection)
+	scheduleChangesColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "memberId", Value: 1}, {Key: "batchId", Value: 1}},
+	})
+	scheduleChangesColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "batchId", Value: 1}},
+	})
+	scheduleChangesColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "memberId", Value: 1}, {Key: "sentAt", Value: 1}},
+	})
+
+	// Store the default wording for any message template not yet saved
+	createCollection(database, models.MessageTemplateCollection)
+	if err := models.SeedMessageTemplates(database); err != nil {
+		log.Fatal("Error seeding message templates:", err)
+	}
+
+	// Convert events saved with clock strings to start and end instants
+	migrated, err := models.MigrateEventTimes(database)
+	if err != nil {
+		log.Fatal("Error migrating event times:", err)
+	}
+	if migrated > 0 {
+		log.Println("Migrated start and end times for", migrated, "events")
+	}
+
+	// Store normalized phone numbers for members saved before they were kept
+	migrated, err = models.MigrateMemberPhones(database)
+	if err != nil {
+		log.Fatal("Error migrating member phone numbers:", err)
+	}
+	if migrated > 0 {
+		log.Println("Normalized phone numbers for", migrated, "members")
+	}
+
+	// Record consent for the contact details of members saved before
+	// consent was tracked
+	migrated, err = models.MigrateMemberConsent(database)
+	if err != nil {
+		log.Fatal("Error migrating member consent:", err)
+	}
+	if migrated > 0 {
+		log.Println("Recorded existing consent for", migrated, "members")
+	}
 
 	// Create admin user
 	res, err := models.CreateUser(database, "Administrator", config.AdminEmail, config.AdminPassword, "")
@@ -2,6 +2,7 @@ package main
 
 import (
 	"time"
+	_ "time/tzdata" // DST rules for TIMEZONE even where the OS has no zoneinfo
 
 	"github.com/a-h/templ"
 	"github.com/gofiber/fiber/v3/log"
@@ -13,9 +14,11 @@ import (
 
 	"context"
 
+	"github.com/bcrowe306/nltst_scheduler.git/models"
 	"github.com/bcrowe306/nltst_scheduler.git/pages"
 	"github.com/bcrowe306/nltst_scheduler.git/routes"
 	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/bcrowe306/nltst_scheduler.git/workers"
 	"github.com/gofiber/fiber/v3/middleware/session"
 	"github.com/gofiber/storage/mongodb/v2"
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -35,6 +38,10 @@ func main() {
 	}
 	log.Info("Config loaded successfully")
 
+	if err := models.SetTimeZone(config.TimeZone); err != nil {
+		log.Fatal("Error loading time zone:", err)
+	}
+
 	// Connect to MongoDB database
 	client, err := mongo.Connect(options.Client().ApplyURI(config.MongoURI))
 	if err != nil {
@@ -56,7 +63,40 @@ func main() {
 	// CONFIGURE SERVICES
 	twilioService := services.NewTwilioService(config.TwilioAccountSID, config.TwilioAuthToken, config.TwilioFromNumber)
 	clicksendService := services.NewClickSendService(config.ClickSendUsername, config.ClickSendAPIKey, config.ClickSendFromNumber)
-	sendgridService := services.NewSendGridService(config.SendGridAPIKey)
+	textInChurchService := services.NewTextInChurchService(config.TextInChurchAPIKey, config.TextInChurchAPIURL)
+	twilioService.StatusCallback = config.BaseURL + "/sms/status"
+	sendgridService := services.NewSendGridService(config.SendGridAPIKey, config.SendGridFromEmail)
+	if config.SendGridWebhookKey != "" {
+		// Checked when the config was loaded
+		sendgridService.WebhookKey, _ = services.ParseSendGridPublicKey(config.SendGridWebhookKey)
+	}
+	smtpService := services.NewSMTPService(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.SMTPFromEmail, config.SMTPFromName, config.SMTPSecurity)
+	smtpService.InsecureSkipVerify = config.SMTPInsecure
+	telegramService := services.NewTelegramService(config.TelegramBotToken, config.TelegramAPIURL, config.TelegramBotUsername, config.TelegramSecret)
+	linkSigner := services.NewLinkSigner(config.LinkSigningSecret, config.BaseURL)
+	notifier := newNotifier(config, twilioService, clicksendService, textInChurchService, sendgridService, smtpService, telegramService)
+
+	// Point the Telegram bot's webhook at this app
+	if telegramService.Configured() && config.TelegramSecret != "" {
+		if err := telegramService.SetWebhook(config.BaseURL+"/telegram/webhook", config.TelegramSecret); err != nil {
+			log.Error("Error setting Telegram webhook: ", err)
+		}
+	}
+
+	// START BACKGROUND WORKERS
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	workers.NewReminderWorker(database, notifier, linkSigner).Start(workerCtx, config.ReminderInterval)
+	workers.NewOutboxWorker(database, notifier).Start(workerCtx, config.OutboxInterval)
+	workers.NewScheduleChangeWorker(database, notifier, linkSigner, config.ScheduleChangeDelay).Start(workerCtx, config.OutboxInterval)
+	if len(config.DigestWeekdays) > 0 {
+		workers.NewDigestWorker(database, notifier, workers.DigestSchedule{
+			Weekdays:  config.DigestWeekdays,
+			Clock:     config.DigestTime,
+			Days:      config.DigestDays,
+			SkipEmpty: config.DigestSkipEmpty,
+		}).Start(workerCtx, config.ReminderInterval)
+	}
 
 	// Start Fiber app with HTML template engine
 	engine := html.New("./views", ".html")
@@ -72,7 +112,12 @@ func main() {
 	app.State().Set("db", database)
 	app.State().Set("twilioService", twilioService)
 	app.State().Set("clicksendService", clicksendService)
+	app.State().Set("textInChurchService", textInChurchService)
 	app.State().Set("sendgridService", sendgridService)
+	app.State().Set("telegramService", telegramService)
+	app.State().Set("notifier", notifier)
+	app.State().Set("linkSigner", linkSigner)
+	app.State().Set("baseURL", config.BaseURL)
 
 	// Setup session middleware with MongoDB storage
 	store := mongodb.New(mongodb.Config{
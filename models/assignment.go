@@ -0,0 +1,36 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// CheckAssignment returns why member can't be assigned to the position, or
+// "" if nothing blocks it. Unavailability always blocks; a missing
+// qualification or a hard conflict blocks unless override is set.
+func CheckAssignment(db *mongo.Database, event *Event, positionID string, member *Member, override bool) (string, error) {
+	if reason := member.UnavailableReason(event.Date); reason != "" {
+		return member.FullName() + " is unavailable on " + event.Date.Format("Jan 2, 2006") + ": " + reason, nil
+	}
+	if override {
+		return "", nil
+	}
+
+	qualifications, err := GetQualificationMatrix(db, event.TeamID)
+	if err != nil {
+		return "", err
+	}
+	if position := event.FindPosition(positionID); position != nil && !qualifications.IsQualified(position.PositionName, member.ID) {
+		return member.FullName() + " is not qualified for " + position.PositionName, nil
+	}
+
+	conflicts, err := CheckAssignmentConflicts(db, event.ID, positionID, member.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, conflict := range conflicts {
+		if conflict.Severity == ConflictHard {
+			return member.FullName() + ": " + conflict.Message, nil
+		}
+	}
+	return "", nil
+}
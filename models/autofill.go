@@ -0,0 +1,171 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// FillProposal is an auto-fill suggestion for one open position slot.
+// MemberID is empty when nobody on the team is free that day.
+type FillProposal struct {
+	EventID      string
+	EventName    string
+	Date         time.Time
+	PositionID   string
+	PositionName string
+	MemberID     string
+	Candidates   []Member
+	Reason       string
+}
+
+// memberServiceStats tracks how often and how recently a member has served,
+// plus the days they are already booked.
+type memberServiceStats struct {
+	count      int
+	lastServed time.Time
+	bookedDays map[string]bool
+}
+
+// ProposeRosterFill suggests members for every open PositionAssignment in
+// the given events. Candidates come from each event's team and are rotated
+// fairly: whoever has served the fewest times goes first, ties going to
+// whoever served least recently. Nobody is proposed twice on the same day,
+// members whose availability rules cover the event date are skipped, and
+// only members qualified for a position are proposed for it.
+// Nothing is saved; the caller presents the proposals for a leader to
+// accept or tweak.
+func ProposeRosterFill(db *mongo.Database, events []Event) ([]FillProposal, error) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Date.Before(events[j].Date) })
+
+	teamMembers := map[string][]Member{}
+	teamQualifications := map[string]QualificationMatrix{}
+	stats := map[string]*memberServiceStats{}
+
+	var proposals []FillProposal
+	for _, event := range events {
+		members, ok := teamMembers[event.TeamID]
+		if !ok {
+			var err error
+			if event.TeamID == "" {
+				members, err = GetAllMembers(db)
+			} else {
+				members, err = GetTeamMembers(db, event.TeamID)
+			}
+			if err != nil {
+				return nil, err
+			}
+			teamMembers[event.TeamID] = members
+		}
+		qualifications, ok := teamQualifications[event.TeamID]
+		if !ok {
+			var err error
+			qualifications, err = GetQualificationMatrix(db, event.TeamID)
+			if err != nil {
+				return nil, err
+			}
+			teamQualifications[event.TeamID] = qualifications
+		}
+
+		for _, member := range members {
+			if _, ok := stats[member.ID]; ok {
+				continue
+			}
+			s, err := loadMemberServiceStats(db, member.ID)
+			if err != nil {
+				return nil, err
+			}
+			stats[member.ID] = s
+		}
+
+		day := event.Date.Format("2006-01-02")
+		for _, pa := range event.PositionAssignments {
+			if pa.MemberID != "" {
+				continue
+			}
+			proposal := FillProposal{
+				EventID:      event.ID,
+				EventName:    event.Name,
+				Date:         event.Date,
+				PositionID:   pa.ID,
+				PositionName: pa.PositionName,
+			}
+			for _, member := range members {
+				if qualifications.IsQualified(pa.PositionName, member.ID) {
+					proposal.Candidates = append(proposal.Candidates, member)
+				}
+			}
+
+			var best *Member
+			for i := range proposal.Candidates {
+				candidate := &proposal.Candidates[i]
+				s := stats[candidate.ID]
+				if s.bookedDays[day] {
+					continue
+				}
+				if _, unavailable := candidate.UnavailableOn(event.Date); unavailable {
+					continue
+				}
+				if best == nil || servesBefore(s, stats[best.ID]) {
+					best = candidate
+				}
+			}
+
+			if best == nil {
+				proposal.Reason = "Everyone qualified is already serving or unavailable this day"
+			} else {
+				s := stats[best.ID]
+				proposal.MemberID = best.ID
+				proposal.Reason = rotationReason(s)
+				s.count++
+				if event.Date.After(s.lastServed) {
+					s.lastServed = event.Date
+				}
+				s.bookedDays[day] = true
+			}
+			proposals = append(proposals, proposal)
+		}
+	}
+	return proposals, nil
+}
+
+func loadMemberServiceStats(db *mongo.Database, memberID string) (*memberServiceStats, error) {
+	events, err := GetEventsByMember(db, memberID)
+	if err != nil {
+		return nil, err
+	}
+	s := &memberServiceStats{bookedDays: map[string]bool{}}
+	for _, event := range events {
+		for _, pa := range event.PositionAssignments {
+			if pa.MemberID != memberID {
+				continue
+			}
+			s.count++
+			if event.Date.After(s.lastServed) {
+				s.lastServed = event.Date
+			}
+			s.bookedDays[event.Date.Format("2006-01-02")] = true
+		}
+	}
+	return s, nil
+}
+
+func servesBefore(a, b *memberServiceStats) bool {
+	if a.count != b.count {
+		return a.count < b.count
+	}
+	return a.lastServed.Before(b.lastServed)
+}
+
+func rotationReason(s *memberServiceStats) string {
+	if s.count == 0 {
+		return "Has not served yet"
+	}
+	times := "times"
+	if s.count == 1 {
+		times = "time"
+	}
+	return "Served " + strconv.Itoa(s.count) + " " + times + ", last on " + s.lastServed.Format("Jan 2")
+}
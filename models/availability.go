@@ -0,0 +1,141 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Availability rule kinds. A blackout covers a date range, for example a
+// trip out of town. A recurring rule covers a weekday, optionally only its
+// nth occurrence in the month ("never 2nd Sundays").
+const (
+	AvailabilityBlackout  = "blackout"
+	AvailabilityRecurring = "recurring"
+)
+
+type AvailabilityRule struct {
+	ID          string       `bson:"_id" json:"_id" form:"-"`
+	Kind        string       `bson:"kind" json:"kind" form:"kind"`
+	StartDate   time.Time    `bson:"startDate,omitempty" json:"startDate" form:"-"`
+	EndDate     time.Time    `bson:"endDate,omitempty" json:"endDate" form:"-"`
+	Weekday     time.Weekday `bson:"weekday" json:"weekday" form:"weekday"`
+	WeekOfMonth int          `bson:"weekOfMonth" json:"weekOfMonth" form:"weekOfMonth"`
+	Reason      string       `bson:"reason" json:"reason" form:"reason"`
+	CreatedAt   time.Time    `bson:"createdAt" json:"createdAt" form:"-"`
+}
+
+// Covers reports whether the rule makes the member unavailable on date.
+func (r AvailabilityRule) Covers(date time.Time) bool {
+	date = DateOnly(date)
+	switch r.Kind {
+	case AvailabilityBlackout:
+		return !date.Before(DateOnly(r.StartDate)) && !date.After(DateOnly(r.EndDate))
+	case AvailabilityRecurring:
+		if date.Weekday() != r.Weekday {
+			return false
+		}
+		switch {
+		case r.WeekOfMonth == 0:
+			return true
+		case r.WeekOfMonth < 0:
+			daysInMonth := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+			return date.Day()+7 > daysInMonth
+		default:
+			return (date.Day()-1)/7+1 == r.WeekOfMonth
+		}
+	}
+	return false
+}
+
+// Summary describes the rule for display, e.g. "Never 2nd Sundays".
+func (r AvailabilityRule) Summary() string {
+	if r.Kind == AvailabilityBlackout {
+		if DateOnly(r.StartDate).Equal(DateOnly(r.EndDate)) {
+			return "Away " + r.StartDate.Format("Jan 2, 2006")
+		}
+		return "Away " + r.StartDate.Format("Jan 2, 2006") + " to " + r.EndDate.Format("Jan 2, 2006")
+	}
+	day := r.Weekday.String() + "s"
+	switch {
+	case r.WeekOfMonth == 0:
+		return "Never " + day
+	case r.WeekOfMonth < 0:
+		return "Never last " + day + " of the month"
+	default:
+		return "Never " + ordinal(r.WeekOfMonth) + " " + day
+	}
+}
+
+// UnavailableOn returns the first availability rule covering date, if any.
+func (m *Member) UnavailableOn(date time.Time) (*AvailabilityRule, bool) {
+	for i := range m.Availability {
+		if m.Availability[i].Covers(date) {
+			return &m.Availability[i], true
+		}
+	}
+	return nil, false
+}
+
+// UnavailableReason returns a short explanation of why the member is not
+// available on date, or "" if they are.
+func (m *Member) UnavailableReason(date time.Time) string {
+	rule, unavailable := m.UnavailableOn(date)
+	if !unavailable {
+		return ""
+	}
+	if rule.Reason != "" {
+		return rule.Reason
+	}
+	return rule.Summary()
+}
+
+func AddAvailabilityRule(db *mongo.Database, memberID string, rule AvailabilityRule) (*mongo.UpdateResult, error) {
+	collection := db.Collection(MemberCollection)
+	rule.ID = uuid.NewString()
+	rule.CreatedAt = time.Now()
+	filter := bson.M{"_id": memberID}
+	update := bson.M{
+		"$push": bson.M{
+			"availability": rule,
+		},
+		"$set": bson.M{
+			"updatedAt": time.Now(),
+		},
+	}
+	res, err := collection.UpdateOne(context.TODO(), filter, update)
+	return res, err
+}
+
+func RemoveAvailabilityRule(db *mongo.Database, memberID string, ruleID string) (*mongo.UpdateResult, error) {
+	collection := db.Collection(MemberCollection)
+	filter := bson.M{"_id": memberID}
+	update := bson.M{
+		"$pull": bson.M{
+			"availability": bson.M{"_id": ruleID},
+		},
+		"$set": bson.M{
+			"updatedAt": time.Now(),
+		},
+	}
+	res, err := collection.UpdateOne(context.TODO(), filter, update)
+	return res, err
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
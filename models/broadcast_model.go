@@ -0,0 +1,280 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const BroadcastCollection = "broadcasts"
+
+// Who a broadcast goes to.
+const (
+	// AudienceTeam is every member of a team.
+	AudienceTeam = "team"
+	// AudienceEvent is everyone assigned to an event.
+	AudienceEvent = "event"
+	// AudienceDate is everyone serving at any event on a date.
+	AudienceDate = "date"
+	// AudienceMembers is a list of members picked by hand.
+	AudienceMembers = "members"
+)
+
+var ErrNoRecipients = errors.New("the broadcast has no recipients")
+
+// ErrAudienceIncomplete is returned when the team, event, date or kind of
+// audience hasn't been chosen yet.
+var ErrAudienceIncomplete = errors.New("no recipients have been chosen")
+
+// broadcastPlaceholder matches the placeholders a broadcast may use. A
+// broadcast isn't about one event, so only the recipient's names are
+// filled in.
+var broadcastPlaceholder = regexp.MustCompile(`\{\{\s*\.(FirstName|MemberName)\s*\}\}`)
+
+// BroadcastAudience picks the recipients of a broadcast. Only the field for
+// Kind is used.
+type BroadcastAudience struct {
+	Kind      string    `bson:"kind" json:"kind" form:"audience"`
+	TeamID    string    `bson:"teamId,omitempty" json:"teamId" form:"teamId"`
+	EventID   string    `bson:"eventId,omitempty" json:"eventId" form:"eventId"`
+	Date      time.Time `bson:"date,omitempty" json:"date" form:"-"`
+	MemberIDs []string  `bson:"memberIds,omitempty" json:"memberIds" form:"memberIds"`
+	// Label describes the audience, e.g. "Worship Team".
+	Label string `bson:"label" json:"label" form:"-"`
+}
+
+// Broadcast is a message sent to a group of members at once, with a record
+// of who it was queued for on which channels.
+type Broadcast struct {
+	ID         string               `bson:"_id" json:"id"`
+	Audience   BroadcastAudience    `bson:"audience" json:"audience"`
+	Channels   []string             `bson:"channels" json:"channels"`
+	Subject    string               `bson:"subject,omitempty" json:"subject"`
+	Body       string               `bson:"body" json:"body"`
+	SentBy     string               `bson:"sentBy,omitempty" json:"sentBy"`
+	Recipients []BroadcastRecipient `bson:"recipients" json:"recipients"`
+	CreatedAt  time.Time            `bson:"createdAt" json:"createdAt"`
+}
+
+// BroadcastRecipient is one member a broadcast was meant for. Channels is
+// empty when none of the broadcast's channels were open to them, and
+// Skipped says why.
+type BroadcastRecipient struct {
+	MemberID string   `bson:"memberId" json:"memberId"`
+	Name     string   `bson:"name" json:"name"`
+	Channels []string `bson:"channels,omitempty" json:"channels"`
+	Skipped  string   `bson:"skipped,omitempty" json:"skipped"`
+}
+
+// QueuedCount returns how many recipients were sent the broadcast on at
+// least one channel.
+func (b *Broadcast) QueuedCount() int {
+	count := 0
+	for _, r := range b.Recipients {
+		if len(r.Channels) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// PlanBroadcastRecipient works out which of channels a broadcast reaches
+// member on, following their communication preferences.
+func PlanBroadcastRecipient(member *Member, channels []string) BroadcastRecipient {
+	recipient := BroadcastRecipient{MemberID: member.ID, Name: member.FullName()}
+	recipient.Channels = member.Channels(channels)
+	if len(recipient.Channels) == 0 {
+		recipient.Skipped = "Hasn't agreed to messages on these channels"
+		if member.OptedOut {
+			recipient.Skipped = "Opted out of all messages"
+		}
+	}
+	return recipient
+}
+
+// ResolveBroadcastAudience finds the members audience covers, each once,
+// sorted by name. It fills in the audience label.
+func ResolveBroadcastAudience(db *mongo.Database, audience *BroadcastAudience) ([]Member, error) {
+	var ids []string
+	switch audience.Kind {
+	case AudienceTeam:
+		if audience.TeamID == "" {
+			return nil, ErrAudienceIncomplete
+		}
+		team, err := GetTeamByID(db, audience.TeamID)
+		if err != nil {
+			return nil, err
+		}
+		audience.Label = team.Name
+		for _, member := range team.Members {
+			ids = append(ids, member.ID)
+		}
+	case AudienceEvent:
+		if audience.EventID == "" {
+			return nil, ErrAudienceIncomplete
+		}
+		event, err := GetEventByID(db, audience.EventID)
+		if err != nil {
+			return nil, err
+		}
+		audience.Label = "Serving at " + event.Name + " on " + event.When()
+		ids = assignedMemberIDs([]Event{*event})
+	case AudienceDate:
+		if audience.Date.IsZero() {
+			return nil, ErrAudienceIncomplete
+		}
+		events, err := GetEventsInDateRange(db, audience.Date, audience.Date)
+		if err != nil {
+			return nil, err
+		}
+		audience.Label = "Serving on " + audience.Date.Format("Mon Jan 2, 2006")
+		ids = assignedMemberIDs(events)
+	case AudienceMembers:
+		ids = audience.MemberIDs
+		audience.Label = "Selected members"
+	default:
+		return nil, ErrAudienceIncomplete
+	}
+
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	ids = slices.DeleteFunc(ids, func(id string) bool { return id == "" })
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	members, err := GetMembersByIDs(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(members, func(a, b Member) int {
+		return strings.Compare(strings.ToLower(a.FullName()), strings.ToLower(b.FullName()))
+	})
+	return members, nil
+}
+
+func assignedMemberIDs(events []Event) []string {
+	var ids []string
+	for _, event := range events {
+		for _, pa := range event.PositionAssignments {
+			ids = append(ids, pa.MemberID)
+		}
+	}
+	return ids
+}
+
+// SendBroadcast records broadcast and queues its message for each of
+// members on the channels their preferences allow. Subject and Body are
+// sent as written apart from the {{.FirstName}} and {{.MemberName}}
+// placeholders.
+func SendBroadcast(db *mongo.Database, broadcast *Broadcast, members []Member) error {
+	if len(members) == 0 {
+		return ErrNoRecipients
+	}
+	body := broadcastTemplate(broadcast.Body)
+	t := MessageTemplate{SMS: body, EmailSubject: broadcastTemplate(broadcast.Subject), EmailText: body}
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	broadcast.ID = uuid.NewString()
+	broadcast.CreatedAt = time.Now()
+	broadcast.Recipients = nil
+	for i := range members {
+		broadcast.Recipients = append(broadcast.Recipients, PlanBroadcastRecipient(&members[i], broadcast.Channels))
+	}
+	if _, err := db.Collection(BroadcastCollection).InsertOne(context.TODO(), broadcast); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range members {
+		member := &members[i]
+		msg, err := t.Render(MessageData{FirstName: member.FirstName, MemberName: member.FullName()})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		msg.BroadcastID = broadcast.ID
+		msg.Key = "broadcast:" + broadcast.ID + ":" + member.ID
+		if err := EnqueueMemberMessage(db, member, broadcast.Channels, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// broadcastTemplate turns text into a message template that expands only
+// the broadcast placeholders, so braces elsewhere in it are sent as
+// written.
+func broadcastTemplate(text string) string {
+	var b strings.Builder
+	literal := func(s string) {
+		b.WriteString(strings.ReplaceAll(s, "{", `{{"{"}}`))
+	}
+	last := 0
+	for _, loc := range broadcastPlaceholder.FindAllStringSubmatchIndex(text, -1) {
+		literal(text[last:loc[0]])
+		b.WriteString("{{." + text[loc[2]:loc[3]] + "}}")
+		last = loc[1]
+	}
+	literal(text[last:])
+	return b.String()
+}
+
+// GetBroadcasts returns the most recent broadcasts, newest first.
+func GetBroadcasts(db *mongo.Database, limit int64) ([]Broadcast, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit)
+	cursor, err := db.Collection(BroadcastCollection).Find(context.TODO(), bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var broadcasts []Broadcast
+	for cursor.Next(context.TODO()) {
+		var broadcast Broadcast
+		if err := cursor.Decode(&broadcast); err != nil {
+			return nil, err
+		}
+		broadcasts = append(broadcasts, broadcast)
+	}
+	return broadcasts, cursor.Err()
+}
+
+func GetBroadcastByID(db *mongo.Database, id string) (*Broadcast, error) {
+	var broadcast Broadcast
+	err := db.Collection(BroadcastCollection).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&broadcast)
+	if err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
+
+// GetBroadcastMessages returns the outbox messages queued for a broadcast,
+// so its delivery can be followed.
+func GetBroadcastMessages(db *mongo.Database, broadcastID string) ([]OutboxMessage, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := db.Collection(OutboxCollection).Find(context.TODO(), bson.M{"broadcastId": broadcastID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var messages []OutboxMessage
+	for cursor.Next(context.TODO()) {
+		var msg OutboxMessage
+		if err := cursor.Decode(&msg); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, cursor.Err()
+}
@@ -0,0 +1,33 @@
+package models
+
+import "testing"
+
+func TestBroadcastTemplate(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hi {{.FirstName}}, see you Sunday", "Hi Jo, see you Sunday"},
+		{"Dear {{ .MemberName }}", "Dear Jo Smith"},
+		{"Use the code {{WELCOME}} at the door", "Use the code {{WELCOME}} at the door"},
+		{"{{{.FirstName}}}", "{Jo}"},
+		{"{{.EventName}} and {{.ConfirmLink}}", "{{.EventName}} and {{.ConfirmLink}}"},
+		{`{{"{"}} {{end}} {{`, `{{"{"}} {{end}} {{`},
+		{"No braces at all", "No braces at all"},
+	}
+	for _, tt := range tests {
+		template := MessageTemplate{SMS: broadcastTemplate(tt.text)}
+		if err := template.Validate(); err != nil {
+			t.Errorf("%q: %v", tt.text, err)
+			continue
+		}
+		msg, err := template.Render(MessageData{FirstName: "Jo", MemberName: "Jo Smith"})
+		if err != nil {
+			t.Errorf("%q: %v", tt.text, err)
+			continue
+		}
+		if msg.Body != tt.want {
+			t.Errorf("%q rendered as %q, want %q", tt.text, msg.Body, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// Calendar view modes.
+const (
+	CalendarMonth = "month"
+	CalendarWeek  = "week"
+	CalendarDay   = "day"
+)
+
+// CalendarView describes the date window shown by a schedule calendar.
+// Start and End are the first and last calendar days displayed; a month
+// view is padded out to whole weeks.
+type CalendarView struct {
+	Mode   string
+	Anchor time.Time
+	Start  time.Time
+	End    time.Time
+	Prev   time.Time
+	Next   time.Time
+}
+
+// NewCalendarView builds the window for mode around anchor. Unknown modes
+// fall back to the month view.
+func NewCalendarView(mode string, anchor time.Time) CalendarView {
+	anchor = DateOnly(anchor)
+	v := CalendarView{Mode: mode, Anchor: anchor}
+	switch mode {
+	case CalendarDay:
+		v.Start, v.End = anchor, anchor
+		v.Prev, v.Next = anchor.AddDate(0, 0, -1), anchor.AddDate(0, 0, 1)
+	case CalendarWeek:
+		v.Start = startOfWeek(anchor)
+		v.End = v.Start.AddDate(0, 0, 6)
+		v.Prev, v.Next = anchor.AddDate(0, 0, -7), anchor.AddDate(0, 0, 7)
+	default:
+		v.Mode = CalendarMonth
+		first := time.Date(anchor.Year(), anchor.Month(), 1, 0, 0, 0, 0, time.UTC)
+		last := first.AddDate(0, 1, -1)
+		v.Start = startOfWeek(first)
+		v.End = startOfWeek(last).AddDate(0, 0, 6)
+		v.Prev, v.Next = first.AddDate(0, -1, 0), first.AddDate(0, 1, 0)
+	}
+	return v
+}
+
+// Days lists every calendar day in the window.
+func (v CalendarView) Days() []time.Time {
+	var days []time.Time
+	for d := v.Start; !d.After(v.End); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+// Title describes the window, e.g. "October 2026" or "Oct 18 - 24, 2026".
+func (v CalendarView) Title() string {
+	switch v.Mode {
+	case CalendarDay:
+		return v.Anchor.Format("Monday, January 2, 2006")
+	case CalendarWeek:
+		if v.Start.Month() == v.End.Month() {
+			return v.Start.Format("Jan 2") + " - " + v.End.Format("2, 2006")
+		}
+		return v.Start.Format("Jan 2") + " - " + v.End.Format("Jan 2, 2006")
+	}
+	return v.Anchor.Format("January 2006")
+}
+
+// InPeriod reports whether day belongs to the anchored period, so a month
+// view can dim the padding days from neighbouring months.
+func (v CalendarView) InPeriod(day time.Time) bool {
+	if v.Mode == CalendarMonth {
+		return day.Month() == v.Anchor.Month()
+	}
+	return true
+}
+
+// WindowEnd returns the last instant of the window, for date range queries.
+func (v CalendarView) WindowEnd() time.Time {
+	return v.End.Add(24*time.Hour - time.Nanosecond)
+}
+
+// Today returns the current calendar day in the organization time zone.
+func Today() time.Time {
+	return DateOnly(time.Now().In(location))
+}
+
+func startOfWeek(day time.Time) time.Time {
+	return day.AddDate(0, 0, -int(day.Weekday()))
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Assignment statuses. An assignment without a status is pending.
+const (
+	AssignmentConfirmed = "confirmed"
+	AssignmentDeclined  = "declined"
+)
+
+var (
+	ErrNoPendingAssignment = errors.New("no upcoming assignment is waiting for a reply")
+	ErrAssignmentChanged   = errors.New("member no longer holds this position")
+)
+
+// ParseConfirmationReply reads a YES or NO style reply to a reminder. ok is
+// false when the reply is neither.
+func ParseConfirmationReply(body string) (status string, ok bool) {
+	word, _, _ := strings.Cut(strings.ToUpper(strings.TrimSpace(body)), " ")
+	word = strings.Trim(word, ".!")
+	switch word {
+	case "YES", "Y", "CONFIRM", "C", "OK":
+		return AssignmentConfirmed, true
+	case "NO", "N", "DECLINE", "D":
+		return AssignmentDeclined, true
+	}
+	return "", false
+}
+
+// RespondToNextAssignment records status on the member's positions at their
+// next upcoming event that has a reply outstanding. It returns the event as
+// it was and the positions that were updated.
+func RespondToNextAssignment(db *mongo.Database, memberID, status string, now time.Time) (*Event, []PositionAssignment, error) {
+	collection := db.Collection(EventCollection)
+	pending := bson.M{"memberId": memberID, "status": bson.M{"$in": bson.A{nil, ""}}}
+	filter := bson.M{
+		"positionAssignments": bson.M{"$elemMatch": pending},
+		"$or": bson.A{
+			bson.M{"startAt": bson.M{"$gte": now}},
+			bson.M{"startAt": bson.M{"$exists": false}, "date": bson.M{"$gte": DateOnly(now.In(location))}},
+		},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "startAt", Value: 1}})
+
+	var event Event
+	err := collection.FindOne(context.TODO(), filter, opts).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, ErrNoPendingAssignment
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	update := bson.M{"$set": bson.M{
+		"positionAssignments.$[pa].status":      status,
+		"positionAssignments.$[pa].respondedAt": now,
+	}}
+	updateOpts := options.UpdateOne().SetArrayFilters([]any{
+		bson.M{"pa.memberId": memberID, "pa.status": bson.M{"$in": bson.A{nil, ""}}},
+	})
+	if _, err := collection.UpdateOne(context.TODO(), bson.M{"_id": event.ID}, update, updateOpts); err != nil {
+		return nil, nil, err
+	}
+
+	var positions []PositionAssignment
+	for _, pa := range event.PositionAssignments {
+		if pa.MemberID == memberID && pa.Status == "" {
+			positions = append(positions, pa)
+		}
+	}
+	return &event, positions, nil
+}
+
+// RespondToAssignment records status on one position, as long as memberID
+// still holds it. A member may change their reply.
+func RespondToAssignment(db *mongo.Database, eventID, positionID, memberID, status string, now time.Time) error {
+	collection := db.Collection(EventCollection)
+	filter := bson.M{
+		"_id":                 eventID,
+		"positionAssignments": bson.M{"$elemMatch": bson.M{"_id": positionID, "memberId": memberID}},
+	}
+	update := bson.M{"$set": bson.M{
+		"positionAssignments.$.status":      status,
+		"positionAssignments.$.respondedAt": now,
+	}}
+	res, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrAssignmentChanged
+	}
+	return nil
+}
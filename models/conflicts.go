@@ -0,0 +1,219 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Conflict severities. A hard conflict means the member is booked twice at
+// the same time: two positions in one event, or two events whose times
+// overlap. A soft conflict means two bookings on the same day that don't
+// overlap, such as back-to-back services.
+const (
+	ConflictHard = "hard"
+	ConflictSoft = "soft"
+)
+
+type Conflict struct {
+	Severity          string
+	MemberID          string
+	MemberName        string
+	Date              time.Time
+	EventID           string
+	EventName         string
+	PositionName      string
+	OtherEventID      string
+	OtherEventName    string
+	OtherPositionName string
+	Message           string
+}
+
+// Involves reports whether the conflict touches the given event.
+func (c Conflict) Involves(eventID string) bool {
+	return c.EventID == eventID || c.OtherEventID == eventID
+}
+
+type memberBooking struct {
+	event    *Event
+	position PositionAssignment
+}
+
+// FindConflicts checks the assignments in events against each other and
+// returns every double booking it finds, hard conflicts first.
+func FindConflicts(events []Event) []Conflict {
+	bookings := map[string][]memberBooking{}
+	for i := range events {
+		for _, pa := range events[i].PositionAssignments {
+			if pa.MemberID == "" {
+				continue
+			}
+			key := pa.MemberID + "|" + events[i].Date.Format("2006-01-02")
+			bookings[key] = append(bookings[key], memberBooking{event: &events[i], position: pa})
+		}
+	}
+
+	var conflicts []Conflict
+	for _, list := range bookings {
+		for i := 0; i < len(list); i++ {
+			for j := i + 1; j < len(list); j++ {
+				conflicts = append(conflicts, compareBookings(list[i], list[j]))
+			}
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Severity != conflicts[j].Severity {
+			return conflicts[i].Severity == ConflictHard
+		}
+		if !conflicts[i].Date.Equal(conflicts[j].Date) {
+			return conflicts[i].Date.Before(conflicts[j].Date)
+		}
+		return conflicts[i].MemberID < conflicts[j].MemberID
+	})
+	return conflicts
+}
+
+func compareBookings(a, b memberBooking) Conflict {
+	c := Conflict{
+		Severity:          ConflictSoft,
+		MemberID:          a.position.MemberID,
+		Date:              a.event.Date,
+		EventID:           a.event.ID,
+		EventName:         a.event.Name,
+		PositionName:      a.position.PositionName,
+		OtherEventID:      b.event.ID,
+		OtherEventName:    b.event.Name,
+		OtherPositionName: b.position.PositionName,
+	}
+
+	switch {
+	case a.event.ID == b.event.ID:
+		c.Severity = ConflictHard
+		c.Message = "Assigned to both " + a.position.PositionName + " and " + b.position.PositionName + " in " + a.event.Name
+	case eventsOverlap(a.event, b.event):
+		c.Severity = ConflictHard
+		c.Message = "Serving " + a.event.Name + " and " + b.event.Name + " at the same time"
+	default:
+		c.Message = "Serving " + a.event.Name + " and " + b.event.Name + " on the same day"
+	}
+	return c
+}
+
+// eventsOverlap compares two same-day events by their start and end
+// instants. Events without a start time are never treated as overlapping.
+func eventsOverlap(a, b *Event) bool {
+	if a.StartAt.IsZero() || b.StartAt.IsZero() {
+		return false
+	}
+	return a.StartAt.Before(eventEnd(b)) && b.StartAt.Before(eventEnd(a))
+}
+
+// eventEnd returns when the event finishes. A missing end time counts as a
+// one minute event.
+func eventEnd(e *Event) time.Time {
+	if e.EndAt.After(e.StartAt) {
+		return e.EndAt
+	}
+	return e.StartAt.Add(time.Minute)
+}
+
+// DetectConflicts runs FindConflicts over events and fills in member names.
+func DetectConflicts(db *mongo.Database, events []Event) ([]Conflict, error) {
+	conflicts := FindConflicts(events)
+	if len(conflicts) == 0 {
+		return conflicts, nil
+	}
+
+	ids := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		ids = append(ids, c.MemberID)
+	}
+	members, err := GetMembersByIDs(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	for _, m := range members {
+		names[m.ID] = m.FullName()
+	}
+	for i := range conflicts {
+		conflicts[i].MemberName = names[conflicts[i].MemberID]
+	}
+	return conflicts, nil
+}
+
+// GetScheduleConflicts returns the conflicts among events dated between
+// start and end, inclusive.
+func GetScheduleConflicts(db *mongo.Database, start, end time.Time) ([]Conflict, error) {
+	events, err := GetEventsInDateRange(db, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return DetectConflicts(db, events)
+}
+
+// CheckAssignmentConflicts reports the conflicts that assigning memberID to
+// positionID in eventID would create, without saving anything.
+func CheckAssignmentConflicts(db *mongo.Database, eventID, positionID, memberID string) ([]Conflict, error) {
+	event, err := GetEventByID(db, eventID)
+	if err != nil {
+		return nil, err
+	}
+	day := DateOnly(event.Date)
+	events, err := GetEventsInDateRange(db, day, day.Add(24*time.Hour-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range events {
+		if events[i].ID != eventID {
+			continue
+		}
+		for j := range events[i].PositionAssignments {
+			if events[i].PositionAssignments[j].ID == positionID {
+				events[i].PositionAssignments[j].MemberID = memberID
+			}
+		}
+	}
+
+	all, err := DetectConflicts(db, events)
+	if err != nil {
+		return nil, err
+	}
+	var conflicts []Conflict
+	for _, c := range all {
+		if c.MemberID == memberID && c.Involves(eventID) {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts, nil
+}
+
+// ConflictsForEvent filters conflicts down to those touching eventID.
+func ConflictsForEvent(conflicts []Conflict, eventID string) []Conflict {
+	var out []Conflict
+	for _, c := range conflicts {
+		if c.Involves(eventID) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// MemberConflict returns the most severe conflict for memberID in eventID,
+// if there is one.
+func MemberConflict(conflicts []Conflict, eventID, memberID string) (*Conflict, bool) {
+	var found *Conflict
+	for i := range conflicts {
+		c := &conflicts[i]
+		if c.MemberID != memberID || !c.Involves(eventID) {
+			continue
+		}
+		if found == nil || (c.Severity == ConflictHard && found.Severity != ConflictHard) {
+			found = c
+		}
+	}
+	return found, found != nil
+}
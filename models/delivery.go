@@ -0,0 +1,129 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// DeliveryProblem returns the problem last reported delivering to the
+// member on channel, or "".
+func (m *Member) DeliveryProblem(channel string) string {
+	switch channel {
+	case ChannelSMS:
+		return m.SMSDeliveryProblem
+	case ChannelEmail:
+		return m.EmailDeliveryProblem
+	}
+	return ""
+}
+
+// deliveryProblemFields names the member fields holding the delivery
+// problem for channel.
+func deliveryProblemFields(channel string) (string, string, bool) {
+	switch channel {
+	case ChannelSMS:
+		return "smsDeliveryProblem", "smsDeliveryProblemAt", true
+	case ChannelEmail:
+		return "emailDeliveryProblem", "emailDeliveryProblemAt", true
+	}
+	return "", "", false
+}
+
+// RecordDelivery stores a provider's report of what became of a message it
+// sent, matched on the provider's message ID, and updates the recipient's
+// delivery problem for that channel. A report older than the one already
+// stored is ignored. It returns the updated message, or nil when no message
+// matches.
+func RecordDelivery(db *mongo.Database, provider, providerID, status, detail string, at time.Time) (*OutboxMessage, error) {
+	if providerID == "" {
+		return nil, nil
+	}
+	filter := bson.M{
+		"provider":   provider,
+		"providerId": providerID,
+		"$or": bson.A{
+			bson.M{"deliveryAt": bson.M{"$exists": false}},
+			bson.M{"deliveryAt": bson.M{"$lte": at}},
+		},
+	}
+	set := bson.M{"deliveryStatus": status, "deliveryAt": at, "updatedAt": time.Now()}
+	update := bson.M{"$set": set}
+	if detail != "" {
+		set["deliveryDetail"] = detail
+	} else {
+		update["$unset"] = bson.M{"deliveryDetail": ""}
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var msg OutboxMessage
+	err := db.Collection(OutboxCollection).FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(&msg)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg.MemberID == "" {
+		return &msg, nil
+	}
+	return &msg, flagMemberDelivery(db, &msg, status, detail, at)
+}
+
+// flagMemberDelivery records or clears the delivery problem on the member
+// msg went to, as long as their contact detail is still the one it was sent
+// to.
+func flagMemberDelivery(db *mongo.Database, msg *OutboxMessage, status, detail string, at time.Time) error {
+	problemField, atField, ok := deliveryProblemFields(msg.Channel)
+	if !ok {
+		return nil
+	}
+	contactField := "phoneNumber"
+	if msg.Channel == ChannelEmail {
+		contactField = "email"
+	}
+	filter := bson.M{"_id": msg.MemberID, contactField: msg.To}
+
+	var update bson.M
+	if status == services.DeliveryDelivered {
+		// Only a delivery newer than the problem clears it
+		filter["$or"] = bson.A{
+			bson.M{atField: bson.M{"$exists": false}},
+			bson.M{atField: bson.M{"$lte": at}},
+		}
+		update = bson.M{"$unset": bson.M{problemField: "", atField: ""}}
+	} else {
+		if detail == "" {
+			detail = deliveryProblemLabel(status)
+		}
+		update = bson.M{"$set": bson.M{problemField: detail, atField: at}}
+	}
+	_, err := db.Collection(MemberCollection).UpdateOne(context.TODO(), filter, update)
+	return err
+}
+
+func deliveryProblemLabel(status string) string {
+	switch status {
+	case services.DeliveryBounced:
+		return "Bounced"
+	case services.DeliverySpam:
+		return "Marked our message as spam"
+	}
+	return "Not delivered"
+}
+
+// ClearDeliveryProblem forgets the delivery problem on a member's channel,
+// as when they are given a new phone number or email address.
+func ClearDeliveryProblem(db *mongo.Database, memberID, channel string) error {
+	problemField, atField, ok := deliveryProblemFields(channel)
+	if !ok {
+		return nil
+	}
+	_, err := db.Collection(MemberCollection).UpdateOne(context.TODO(), bson.M{"_id": memberID},
+		bson.M{"$unset": bson.M{problemField: "", atField: ""}})
+	return err
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const DigestCollection = "digests"
+
+// DigestLease is how long an instance may spend sending a day's digest
+// before another may take over.
+const DigestLease = 15 * time.Minute
+
+// Digest tracks the schedule digest for a day, so one app instance at a
+// time sends it and it is done once it has been queued for every member.
+// Each member's message has its own idempotency key, so a digest taken
+// over part way through never reaches anyone twice.
+type Digest struct {
+	// ID is the send day, e.g. "2026-10-19".
+	ID          string     `bson:"_id" json:"id"`
+	Start       time.Time  `bson:"start" json:"start"`
+	End         time.Time  `bson:"end" json:"end"`
+	LockedUntil time.Time  `bson:"lockedUntil" json:"lockedUntil"`
+	CompletedAt *time.Time `bson:"completedAt,omitempty" json:"completedAt"`
+	CreatedAt   time.Time  `bson:"createdAt" json:"createdAt"`
+}
+
+// ScheduleLine is one upcoming assignment in a schedule digest.
+type ScheduleLine struct {
+	Date      string
+	Time      string
+	When      string
+	EventName string
+	Team      string
+	Position  string
+}
+
+// ClaimDigest takes the digest for the calendar day start, covering the
+// days up to end, for DigestLease. A digest that is done, or that another
+// caller holds, can't be claimed; it reports whether this caller won the
+// claim.
+func ClaimDigest(db *mongo.Database, start, end time.Time, now time.Time) (bool, error) {
+	filter := bson.M{
+		"_id":         start.Format("2006-01-02"),
+		"completedAt": bson.M{"$exists": false},
+		"lockedUntil": bson.M{"$lte": now},
+	}
+	update := bson.M{
+		"$set":         bson.M{"lockedUntil": now.Add(DigestLease)},
+		"$setOnInsert": bson.M{"start": start, "end": end, "createdAt": now},
+	}
+	_, err := db.Collection(DigestCollection).UpdateOne(context.TODO(), filter, update, options.UpdateOne().SetUpsert(true))
+	// The day exists but isn't claimable, so the upsert tried to insert it
+	// again
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CompleteDigest marks the digest for the day start as done.
+func CompleteDigest(db *mongo.Database, start time.Time, now time.Time) error {
+	_, err := db.Collection(DigestCollection).UpdateOne(context.TODO(), bson.M{"_id": start.Format("2006-01-02")},
+		bson.M{"$set": bson.M{"completedAt": now}})
+	return err
+}
+
+// ReleaseDigest gives up the claim on the digest for the day start, after
+// it could not be sent, so the next run tries again.
+func ReleaseDigest(db *mongo.Database, start time.Time) error {
+	_, err := db.Collection(DigestCollection).UpdateOne(context.TODO(),
+		bson.M{"_id": start.Format("2006-01-02"), "completedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"lockedUntil": time.Time{}}})
+	return err
+}
+
+// NewScheduleLines lists the events of a member's schedule that haven't
+// started by now. teams maps team IDs to names.
+func NewScheduleLines(events []MemberScheduleEvent, teams map[string]string, now time.Time) []ScheduleLine {
+	var lines []ScheduleLine
+	for _, e := range events {
+		if !e.StartAt.IsZero() && e.StartAt.Before(now) {
+			continue
+		}
+		line := ScheduleLine{
+			Date:      e.Date.Format("Mon Jan 2"),
+			Time:      e.TimeRange(),
+			When:      e.Date.Format("Mon, Jan 2"),
+			EventName: e.Name,
+			Team:      teams[e.TeamID],
+			Position:  strings.Join(e.PositionNames, " and "),
+		}
+		if !e.StartAt.IsZero() {
+			line.When += " at " + e.StartAt.In(location).Format("3:04 PM")
+		}
+		if line.Position == "" {
+			line.Position = e.PositionName
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// FormatPeriod describes the calendar days start to end, e.g.
+// "Oct 19 - Oct 25".
+func FormatPeriod(start, end time.Time) string {
+	if start.Equal(end) {
+		return start.Format("Mon Jan 2")
+	}
+	return start.Format("Jan 2") + " - " + end.Format("Jan 2")
+}
@@ -30,12 +30,14 @@ type Event struct {
 	Name                string               `bson:"name" json:"name" query:"name" form:"name"`
 	Description         string               `bson:"description" json:"description" query:"description" form:"description"`
 	Template            string               `bson:"template" json:"template" query:"template" form:"template"`
-	StartTime           string               `bson:"startTime" json:"startTime" query:"startTime" form:"startTime"`
-	EndTime             string               `bson:"endTime" json:"endTime" query:"endTime" form:"endTime"`
+	StartAt             time.Time            `bson:"startAt,omitempty" json:"startAt" query:"-" form:"-"`
+	EndAt               time.Time            `bson:"endAt,omitempty" json:"endAt" query:"-" form:"-"`
 	Date                time.Time            `bson:"date" json:"date" query:"date" form:"date"`
 	ReminderInterval    time.Duration        `bson:"reminderInterval" json:"reminderInterval" query:"reminderInterval" form:"reminderInterval"`
 	ReminderEnabled     bool                 `bson:"reminderEnabled" json:"reminderEnabled" query:"reminderEnabled" form:"reminderEnabled"`
+	ReminderSentAt      time.Time            `bson:"reminderSentAt,omitempty" json:"reminderSentAt" query:"-" form:"-"`
 	TeamID              string               `bson:"teamId" json:"teamId" query:"teamId" form:"teamId"`
+	SeriesID            string               `bson:"seriesId,omitempty" json:"seriesId" query:"seriesId" form:"seriesId"`
 	PositionAssignments []PositionAssignment `bson:"positionAssignments,omitempty" json:"positionAssignments" query:"positionAssignments" form:"positionAssignments"`
 	CreatedAt           time.Time            `json:"createdAt" bson:"createdAt"`
 	UpdatedAt           time.Time            `json:"updatedAt" bson:"updatedAt"`
@@ -47,6 +49,77 @@ type PositionAssignment struct {
 	Description  string   `bson:"description" json:"description" query:"description" form:"description"`
 	MemberID     string   `bson:"memberId" json:"memberId" query:"memberId" form:"memberId"`
 	Members      []string `bson:"members,omitempty" json:"members" query:"members" form:"members"`
+	// OriginalMemberID is who held the slot before the first accepted swap.
+	OriginalMemberID string        `bson:"originalMemberId,omitempty" json:"originalMemberId" query:"-" form:"-"`
+	SwapRequests     []SwapRequest `bson:"swapRequests,omitempty" json:"swapRequests" query:"-" form:"-"`
+	// Status is the member's reply to their assignment; blank while pending.
+	Status      string     `bson:"status,omitempty" json:"status" query:"-" form:"-"`
+	RespondedAt *time.Time `bson:"respondedAt,omitempty" json:"respondedAt" query:"-" form:"-"`
+}
+
+// TimeRange formats the event's start and end in the organization time zone.
+func (e *Event) TimeRange() string {
+	return FormatTimeRange(e.StartAt, e.EndAt)
+}
+
+// StartClock returns the local start time as "15:04", for time inputs.
+func (e *Event) StartClock() string {
+	return ClockString(e.StartAt)
+}
+
+// EndClock returns the local end time as "15:04", for time inputs.
+func (e *Event) EndClock() string {
+	return ClockString(e.EndAt)
+}
+
+// ReminderAt returns when the event's reminder is due, counting back from
+// its start. Events without a start time count from local midnight.
+func (e *Event) ReminderAt() time.Time {
+	start := e.StartAt
+	if start.IsZero() {
+		start = time.Date(e.Date.Year(), e.Date.Month(), e.Date.Day(), 0, 0, 0, 0, location)
+	}
+	return start.Add(-e.ReminderInterval)
+}
+
+// When describes the event's day and start time for messages, e.g.
+// "Sun, Oct 18 at 9:00 AM".
+func (e *Event) When() string {
+	when := e.Date.Format("Mon, Jan 2")
+	if !e.StartAt.IsZero() {
+		when += " at " + e.StartAt.In(location).Format("3:04 PM")
+	}
+	return when
+}
+
+// LinkExpiry is when signed links about the event stop working: the end of
+// the day after it in the organization time zone.
+func (e *Event) LinkExpiry() time.Time {
+	year, month, day := e.Date.Date()
+	return time.Date(year, month, day+2, 0, 0, 0, 0, location)
+}
+
+// UnconfirmedCount returns how many of the event's filled positions have
+// not been confirmed by their member.
+func (e *Event) UnconfirmedCount() int {
+	count := 0
+	for _, pa := range e.PositionAssignments {
+		if pa.MemberID != "" && pa.Status != AssignmentConfirmed {
+			count++
+		}
+	}
+	return count
+}
+
+// AssignedCount returns how many of the event's positions have a member.
+func (e *Event) AssignedCount() int {
+	count := 0
+	for _, pa := range e.PositionAssignments {
+		if pa.MemberID != "" {
+			count++
+		}
+	}
+	return count
 }
 
 type PositionAssignmentWithMember struct {
@@ -55,6 +128,7 @@ type PositionAssignmentWithMember struct {
 	Description  string   `bson:"description" json:"description" query:"description" form:"description"`
 	Member       Member   `bson:"member" json:"member" query:"member" form:"member"`
 	Members      []Member `bson:"members,omitempty" json:"members,omitempty" query:"members,omitempty" form:"members,omitempty"`
+	Status       string   `bson:"status,omitempty" json:"status" query:"-" form:"-"`
 }
 
 type EventWithMemberDetails struct {
@@ -62,8 +136,8 @@ type EventWithMemberDetails struct {
 	Name                string                         `bson:"name" json:"name" query:"name" form:"name"`
 	Description         string                         `bson:"description" json:"description" query:"description" form:"description"`
 	Template            string                         `bson:"template" json:"template" query:"template" form:"template"`
-	StartTime           string                         `bson:"startTime" json:"startTime" query:"startTime" form:"startTime"`
-	EndTime             string                         `bson:"endTime" json:"endTime" query:"endTime" form:"endTime"`
+	StartAt             time.Time                      `bson:"startAt,omitempty" json:"startAt" query:"-" form:"-"`
+	EndAt               time.Time                      `bson:"endAt,omitempty" json:"endAt" query:"-" form:"-"`
 	Date                time.Time                      `bson:"date" json:"date" query:"date" form:"date"`
 	ReminderInterval    time.Duration                  `bson:"reminderInterval" json:"reminderInterval" query:"reminderInterval" form:"reminderInterval"`
 	ReminderEnabled     bool                           `bson:"reminderEnabled" json:"reminderEnabled" query:"reminderEnabled" form:"reminderEnabled"`
@@ -73,13 +147,18 @@ type EventWithMemberDetails struct {
 	PositionAssignments []PositionAssignmentWithMember `bson:"positionAssignments" json:"positionAssignments" query:"positionAssignments" form:"positionAssignments"`
 }
 
+// TimeRange formats the event's start and end in the organization time zone.
+func (e EventWithMemberDetails) TimeRange() string {
+	return FormatTimeRange(e.StartAt, e.EndAt)
+}
+
 type EventByPosition struct {
 	ID          string    `bson:"_id,omitempty" json:"_id" query:"_id" form:"_id"`
 	Name        string    `bson:"name" json:"name" query:"name" form:"name"`
 	Description string    `bson:"description" json:"description" query:"description" form:"description"`
 	Template    string    `bson:"template" json:"template" query:"template" form:"template"`
-	StartTime   string    `bson:"startTime" json:"startTime" query:"startTime" form:"startTime"`
-	EndTime     string    `bson:"endTime" json:"endTime" query:"endTime" form:"endTime"`
+	StartAt     time.Time `bson:"startAt,omitempty" json:"startAt" query:"-" form:"-"`
+	EndAt       time.Time `bson:"endAt,omitempty" json:"endAt" query:"-" form:"-"`
 	Date        time.Time `bson:"date" json:"date" query:"date" form:"date"`
 	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
@@ -97,17 +176,23 @@ type MemberScheduleEvent struct {
 	Name             string        `bson:"name" json:"name" query:"name" form:"name"`
 	Description      string        `bson:"description" json:"description" query:"description" form:"description"`
 	Template         string        `bson:"template" json:"template" query:"template" form:"template"`
-	StartTime        string        `bson:"startTime" json:"startTime" query:"startTime" form:"startTime"`
-	EndTime          string        `bson:"endTime" json:"endTime" query:"endTime" form:"endTime"`
+	StartAt          time.Time     `bson:"startAt,omitempty" json:"startAt" query:"-" form:"-"`
+	EndAt            time.Time     `bson:"endAt,omitempty" json:"endAt" query:"-" form:"-"`
 	Date             time.Time     `bson:"date" json:"date" query:"date" form:"date"`
 	ReminderInterval time.Duration `bson:"reminderInterval" json:"reminderInterval" query:"reminderInterval" form:"reminderInterval"`
 	ReminderEnabled  bool          `bson:"reminderEnabled" json:"reminderEnabled" query:"reminderEnabled" form:"reminderEnabled"`
 	TeamID           string        `bson:"teamId" json:"teamId" query:"teamId" form:"teamId"`
 	PositionName     string        `bson:"positionName,omitempty" json:"positionName" query:"positionName" form:"positionName"`
+	PositionNames    []string      `bson:"positionNames,omitempty" json:"positionNames" query:"-" form:"-"`
 	CreatedAt        time.Time     `json:"createdAt" bson:"createdAt"`
 	UpdatedAt        time.Time     `json:"updatedAt" bson:"updatedAt"`
 }
 
+// TimeRange formats the event's start and end in the organization time zone.
+func (e MemberScheduleEvent) TimeRange() string {
+	return FormatTimeRange(e.StartAt, e.EndAt)
+}
+
 type MemberSchedule struct {
 	ID          string                `bson:"_id" json:"_id"`
 	FirstName   string                `json:"firstName" bson:"firstName" query:"firstName" form:"firstName"`
@@ -119,6 +204,10 @@ type MemberSchedule struct {
 	Events      []MemberScheduleEvent `bson:"events" json:"events" query:"events" form:"events"`
 }
 
+func (m *MemberSchedule) FullName() string {
+	return m.FirstName + " " + m.LastName
+}
+
 func GetAllEvents(db *mongo.Database) ([]Event, error) {
 	collection := db.Collection(EventCollection)
 	cursor, err := collection.Find(context.TODO(), bson.M{})
@@ -174,23 +263,88 @@ func InsertEvent(db *mongo.Database, event *Event) (*mongo.InsertOneResult, erro
 	return res, err
 }
 
+// NewEventFromTemplate builds an event for the given date from an event
+// template, with one open PositionAssignment per template position.
+func NewEventFromTemplate(eventTemplate *EventTemplate, date time.Time) Event {
+	event := Event{
+		Name:                eventTemplate.Name,
+		Description:         eventTemplate.Description,
+		Template:            eventTemplate.ID,
+		Date:                date,
+		TeamID:              eventTemplate.TeamID,
+		PositionAssignments: make([]PositionAssignment, 0, len(eventTemplate.Positions)),
+	}
+	event.StartAt, event.EndAt = EventInstants(date, eventTemplate.StartTime, eventTemplate.EndTime)
+	for _, pos := range eventTemplate.Positions {
+		event.PositionAssignments = append(event.PositionAssignments, PositionAssignment{
+			PositionName: pos.Name,
+			Description:  pos.Description,
+		})
+	}
+	return event
+}
+
+// InsertEvents inserts several events at once. Unlike InsertEvent it keeps
+// the events' position assignments, giving each slot a new ID.
+func InsertEvents(db *mongo.Database, events []Event) (*mongo.InsertManyResult, error) {
+	collection := db.Collection(EventCollection)
+	docs := make([]interface{}, 0, len(events))
+	for i := range events {
+		events[i].ID = uuid.NewString()
+		events[i].CreatedAt = time.Now()
+		events[i].UpdatedAt = time.Now()
+		if events[i].PositionAssignments == nil {
+			events[i].PositionAssignments = make([]PositionAssignment, 0)
+		}
+		for j := range events[i].PositionAssignments {
+			events[i].PositionAssignments[j].ID = uuid.NewString()
+		}
+		docs = append(docs, events[i])
+	}
+	res, err := collection.InsertMany(context.TODO(), docs)
+	return res, err
+}
+
+// UpdateEvent saves the event's details. Members assigned to it are told
+// when its day or start time changes.
 func UpdateEvent(db *mongo.Database, event *Event) (*mongo.UpdateResult, error) {
 	collection := db.Collection(EventCollection)
+	before, err := GetEventByID(db, event.ID)
+	if err != nil {
+		return nil, err
+	}
 	filter := bson.M{"_id": event.ID}
 	update := bson.M{
 		"$set": bson.M{
-			"name":        event.Name,
-			"description": event.Description,
-			"template":    event.Template,
-			"startTime":   event.StartTime,
-			"endTime":     event.EndTime,
-			"date":        event.Date,
-			"teamId":      event.TeamID,
-			"updatedAt":   time.Now(),
+			"name":             event.Name,
+			"description":      event.Description,
+			"template":         event.Template,
+			"startAt":          event.StartAt,
+			"endAt":            event.EndAt,
+			"date":             event.Date,
+			"teamId":           event.TeamID,
+			"reminderEnabled":  event.ReminderEnabled,
+			"reminderInterval": event.ReminderInterval,
+			"updatedAt":        time.Now(),
+		},
+		// The times are set now, so drop any legacy clock strings that
+		// MigrateEventTimes couldn't read
+		"$unset": bson.M{
+			"startTime":     "",
+			"endTime":       "",
+			"timesMigrated": "",
 		},
 	}
 	res, err := collection.UpdateOne(context.TODO(), filter, update)
-	return res, err
+	if err != nil {
+		return res, err
+	}
+	if !before.Date.Equal(event.Date) || !before.StartAt.Equal(event.StartAt) {
+		if err := recordEventMoved(db, before, event); err != nil {
+			log.Print("Error recording schedule change: ", err)
+		}
+	}
+	return res, nil
 }
 
 func DeleteEvent(db *mongo.Database, eventID string) (*mongo.DeleteResult, error) {
@@ -225,28 +379,79 @@ func RemovePosition(db *mongo.Database, eventID string, positionID string) (*mon
 	return res, err
 }
 
+// AssignPositionToMember puts memberID in the position. The member and
+// anyone they replace are told about the change.
 func AssignPositionToMember(db *mongo.Database, eventID string, positionID string, memberID string) (*mongo.UpdateResult, error) {
 	collection := db.Collection(EventCollection)
+	before, err := GetEventByID(db, eventID)
+	if err != nil {
+		return nil, err
+	}
 	filter := bson.M{"_id": eventID, "positionAssignments._id": positionID}
 	update := bson.M{
 		"$set": bson.M{
 			"positionAssignments.$.memberId": memberID,
 		},
 	}
+	// A new holder hasn't replied yet
+	position := before.FindPosition(positionID)
+	changed := position != nil && position.MemberID != memberID
+	if changed {
+		update["$unset"] = bson.M{
+			"positionAssignments.$.status":      "",
+			"positionAssignments.$.respondedAt": "",
+		}
+	}
 	res, err := collection.UpdateOne(context.TODO(), filter, update)
-	return res, err
+	if err != nil {
+		return res, err
+	}
+	if changed {
+		if err := cancelOpenSwapRequests(db, eventID, position); err != nil {
+			log.Print("Error cancelling swap requests: ", err)
+		}
+	}
+	if err := recordAssignmentChange(db, before, positionID, memberID); err != nil {
+		log.Print("Error recording schedule change: ", err)
+	}
+	return res, nil
 }
 
-func UnassignPositionFromMember(db *mongo.Database, eventID string, positionID string) (*mongo.UpdateResult, error) {
-	collection := db.Collection(EventCollection)
-	filter := bson.M{"_id": eventID, "positionAssignments._id": positionID}
+// FillEmptyPosition puts memberID in the position only if nobody holds it,
+// reporting whether it did. The member is told about the change.
+func FillEmptyPosition(db *mongo.Database, eventID string, positionID string, memberID string) (bool, error) {
+	before, err := GetEventByID(db, eventID)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{
+		"_id": eventID,
+		"positionAssignments": bson.M{"$elemMatch": bson.M{
+			"_id":      positionID,
+			"memberId": bson.M{"$in": bson.A{"", nil}},
+		}},
+	}
 	update := bson.M{
-		"$set": bson.M{
-			"positionAssignments.$.memberId": "",
-		},
+		"$set":   bson.M{"positionAssignments.$.memberId": memberID},
+		"$unset": bson.M{"positionAssignments.$.status": "", "positionAssignments.$.respondedAt": ""},
 	}
-	res, err := collection.UpdateOne(context.TODO(), filter, update)
-	return res, err
+	res, err := db.Collection(EventCollection).UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	if res.MatchedCount == 0 {
+		return false, nil
+	}
+	if err := recordAssignmentChange(db, before, positionID, memberID); err != nil {
+		log.Print("Error recording schedule change: ", err)
+	}
+	return true, nil
+}
+
+// UnassignPositionFromMember empties the position and tells the member who
+// held it.
+func UnassignPositionFromMember(db *mongo.Database, eventID string, positionID string) (*mongo.UpdateResult, error) {
+	return AssignPositionToMember(db, eventID, positionID, "")
 }
 
 func GetEventsByService(db *mongo.Database, templateID string) ([]Event, error) {
@@ -305,6 +510,42 @@ func GetEventsByMember(db *mongo.Database, memberID string) ([]Event, error) {
 	return events, nil
 }
 
+func GetEventsBySeries(db *mongo.Database, seriesID string) ([]Event, error) {
+	collection := db.Collection(EventCollection)
+	filter := bson.M{"seriesId": seriesID}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+	cursor, err := collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var events []Event
+	for cursor.Next(context.TODO()) {
+		var event Event
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// DeleteEventsBySeries deletes the events of a series dated after the given
+// date. Pass the zero time to delete every event in the series.
+func DeleteEventsBySeries(db *mongo.Database, seriesID string, after time.Time) (*mongo.DeleteResult, error) {
+	collection := db.Collection(EventCollection)
+	filter := bson.M{"seriesId": seriesID}
+	if !after.IsZero() {
+		filter["date"] = bson.M{"$gt": after}
+	}
+	res, err := collection.DeleteMany(context.TODO(), filter)
+	return res, err
+}
+
 func DeleteEventsByService(db *mongo.Database, templateID string) (*mongo.DeleteResult, error) {
 	collection := db.Collection(EventCollection)
 	filter := bson.M{"template": templateID}
@@ -339,8 +580,21 @@ func RemovePositionAssignment(db *mongo.Database, eventID string, positionID str
 // Special queries and aggregations
 
 func GetEventsWithMemberDetails(db *mongo.Database) ([]EventWithMemberDetails, error) {
+	return getEventsWithMemberDetails(db, bson.M{})
+}
+
+// GetEventsWithMemberDetailsInRange is GetEventsWithMemberDetails limited to
+// events dated between start and end, inclusive.
+func GetEventsWithMemberDetailsInRange(db *mongo.Database, start, end time.Time) ([]EventWithMemberDetails, error) {
+	return getEventsWithMemberDetails(db, bson.M{"date": bson.M{"$gte": start, "$lte": end}})
+}
+
+func getEventsWithMemberDetails(db *mongo.Database, match bson.M) ([]EventWithMemberDetails, error) {
 	collection := db.Collection(EventCollection)
 	pipeline := mongo.Pipeline{
+		{
+			{Key: "$match", Value: match},
+		},
 		{
 			{Key: "$unwind", Value: bson.M{"path": "$positionAssignments", "preserveNullAndEmptyArrays": true}},
 		},
@@ -362,8 +616,8 @@ func GetEventsWithMemberDetails(db *mongo.Database) ([]EventWithMemberDetails, e
 				"description": bson.M{"$first": "$description"},
 				"teamId":      bson.M{"$first": "$teamId"},
 				"date":        bson.M{"$first": "$date"},
-				"startTime":   bson.M{"$first": "$startTime"},
-				"endTime":     bson.M{"$first": "$endTime"},
+				"startAt":     bson.M{"$first": "$startAt"},
+				"endAt":       bson.M{"$first": "$endAt"},
 				"createdAt":   bson.M{"$first": "$createdAt"},
 				"updatedAt":   bson.M{"$first": "$updatedAt"},
 				"template":    bson.M{"$first": "$template"},
@@ -372,11 +626,12 @@ func GetEventsWithMemberDetails(db *mongo.Database) ([]EventWithMemberDetails, e
 						"_id":          "$positionAssignments._id",
 						"description":  "$positionAssignments.description",
 						"positionName": "$positionAssignments.positionName",
+						"status":       "$positionAssignments.status",
 						"member":       "$memberDetails",
 					},
 				},
 			}}},
-		{{Key: "$sort", Value: bson.M{"date": 1}}},
+		{{Key: "$sort", Value: bson.D{{Key: "date", Value: 1}, {Key: "startAt", Value: 1}}}},
 	}
 
 	cursor, err := collection.Aggregate(context.TODO(), pipeline)
@@ -427,8 +682,8 @@ func GetEventsGroupedByPositionName(db *mongo.Database) ([]EventsGroupedByPositi
 						"name":        "$name",
 						"description": "$description",
 						"date":        "$date",
-						"startTime":   "$startTime",
-						"endTime":     "$endTime",
+						"startAt":     "$startAt",
+						"endAt":       "$endAt",
 						"template":    "$template",
 						"createdAt":   "$createdAt",
 						"updatedAt":   "$updatedAt",
@@ -438,7 +693,7 @@ func GetEventsGroupedByPositionName(db *mongo.Database) ([]EventsGroupedByPositi
 			}},
 		},
 		{
-			{Key: "$sort", Value: bson.M{"events.date": 1, "events.startTime": 1}},
+			{Key: "$sort", Value: bson.M{"events.date": 1, "events.startAt": 1}},
 		},
 	}
 
@@ -483,7 +738,7 @@ func GetMemberSchedule(db *mongo.Database, memberID string, startDate, endDate t
 									"$$memberId",
 									bson.M{
 										"$map": bson.M{
-											"input": "$positionAssignments",
+											"input": bson.M{"$ifNull": []interface{}{"$positionAssignments", []interface{}{}}},
 											"as":    "pa",
 											"in":    "$$pa.memberId",
 										},
@@ -518,6 +773,19 @@ func GetMemberSchedule(db *mongo.Database, memberID string, startDate, endDate t
 									"in": "$$matchedPA.positionName",
 								},
 							},
+							"positionNames": bson.M{
+								"$map": bson.M{
+									"input": bson.M{
+										"$filter": bson.M{
+											"input": "$positionAssignments",
+											"as":    "pa",
+											"cond":  bson.M{"$eq": []interface{}{"$$pa.memberId", "$$memberId"}},
+										},
+									},
+									"as": "pa",
+									"in": "$$pa.positionName",
+								},
+							},
 						}},
 					},
 					{
@@ -526,7 +794,7 @@ func GetMemberSchedule(db *mongo.Database, memberID string, startDate, endDate t
 						}},
 					},
 					{
-						{Key: "$sort", Value: bson.M{"date": 1, "startTime": 1}},
+						{Key: "$sort", Value: bson.D{{Key: "date", Value: 1}, {Key: "startAt", Value: 1}}},
 					},
 				},
 				"as": "events",
@@ -573,7 +841,7 @@ func GetMembersSchedules(db *mongo.Database, startDate, endDate time.Time) ([]Me
 									"$$memberId",
 									bson.M{
 										"$map": bson.M{
-											"input": "$positionAssignments",
+											"input": bson.M{"$ifNull": []interface{}{"$positionAssignments", []interface{}{}}},
 											"as":    "pa",
 											"in":    "$$pa.memberId",
 										},
@@ -608,6 +876,19 @@ func GetMembersSchedules(db *mongo.Database, startDate, endDate time.Time) ([]Me
 									"in": "$$matchedPA.positionName",
 								},
 							},
+							"positionNames": bson.M{
+								"$map": bson.M{
+									"input": bson.M{
+										"$filter": bson.M{
+											"input": "$positionAssignments",
+											"as":    "pa",
+											"cond":  bson.M{"$eq": []interface{}{"$$pa.memberId", "$$memberId"}},
+										},
+									},
+									"as": "pa",
+									"in": "$$pa.positionName",
+								},
+							},
 						}},
 					},
 					{
@@ -616,12 +897,15 @@ func GetMembersSchedules(db *mongo.Database, startDate, endDate time.Time) ([]Me
 						}},
 					},
 					{
-						{Key: "$sort", Value: bson.M{"date": 1, "startTime": 1}},
+						{Key: "$sort", Value: bson.D{{Key: "date", Value: 1}, {Key: "startAt", Value: 1}}},
 					},
 				},
 				"as": "events",
 			}},
 		},
+		{
+			{Key: "$sort", Value: bson.D{{Key: "lastName", Value: 1}, {Key: "firstName", Value: 1}}},
+		},
 	}
 
 	cursor, err := collection.Aggregate(context.TODO(), pipeline)
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const EventSeriesCollection = "event_series"
+
+// EventSeries is a run of events materialized from an EventTemplate and a
+// recurrence rule. StartDate is the first date of the series and EndDate the
+// horizon events have been generated up to.
+type EventSeries struct {
+	ID         string    `bson:"_id,omitempty" json:"_id" query:"_id" form:"_id"`
+	TemplateID string    `bson:"templateId" json:"templateId" query:"templateId" form:"templateId"`
+	Name       string    `bson:"name" json:"name" query:"name" form:"name"`
+	Recurrence string    `bson:"recurrence" json:"recurrence" query:"recurrence" form:"recurrence"`
+	StartDate  time.Time `bson:"startDate" json:"startDate" query:"startDate" form:"startDate"`
+	EndDate    time.Time `bson:"endDate" json:"endDate" query:"endDate" form:"endDate"`
+	CreatedAt  time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+func InsertEventSeries(db *mongo.Database, series *EventSeries) (*mongo.InsertOneResult, error) {
+	series.ID = uuid.NewString()
+	series.CreatedAt = time.Now()
+	series.UpdatedAt = time.Now()
+	collection := db.Collection(EventSeriesCollection)
+	res, err := collection.InsertOne(context.TODO(), series)
+	return res, err
+}
+
+func GetEventSeriesByID(db *mongo.Database, seriesID string) (*EventSeries, error) {
+	collection := db.Collection(EventSeriesCollection)
+	var series EventSeries
+	err := collection.FindOne(context.TODO(), bson.M{"_id": seriesID}).Decode(&series)
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+func GetEventSeriesByTemplate(db *mongo.Database, templateID string) ([]EventSeries, error) {
+	collection := db.Collection(EventSeriesCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "startDate", Value: 1}})
+	cursor, err := collection.Find(context.TODO(), bson.M{"templateId": templateID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var series []EventSeries
+	for cursor.Next(context.TODO()) {
+		var s EventSeries
+		if err := cursor.Decode(&s); err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+func UpdateEventSeriesEndDate(db *mongo.Database, seriesID string, endDate time.Time) (*mongo.UpdateResult, error) {
+	collection := db.Collection(EventSeriesCollection)
+	filter := bson.M{"_id": seriesID}
+	update := bson.M{
+		"$set": bson.M{
+			"endDate":   endDate,
+			"updatedAt": time.Now(),
+		},
+	}
+	res, err := collection.UpdateOne(context.TODO(), filter, update)
+	return res, err
+}
+
+func DeleteEventSeries(db *mongo.Database, seriesID string) (*mongo.DeleteResult, error) {
+	collection := db.Collection(EventSeriesCollection)
+	res, err := collection.DeleteOne(context.TODO(), bson.M{"_id": seriesID})
+	return res, err
+}
+
+// CreateEventSeries snapshots the template's recurrence rule into a new
+// series and materializes its events from startDate through endDate.
+// It returns the new series and the number of events created.
+func CreateEventSeries(db *mongo.Database, eventTemplate *EventTemplate, startDate, endDate time.Time) (*EventSeries, int, error) {
+	rule, err := ParseRecurrenceRule(eventTemplate.Recurrence)
+	if err != nil {
+		return nil, 0, err
+	}
+	startDate = DateOnly(startDate)
+	endDate = DateOnly(endDate)
+	if endDate.Before(startDate) {
+		return nil, 0, errors.New("series end date is before its start date")
+	}
+
+	series := &EventSeries{
+		TemplateID: eventTemplate.ID,
+		Name:       eventTemplate.Name,
+		Recurrence: rule.String(),
+		StartDate:  startDate,
+		EndDate:    endDate,
+	}
+	if _, err := InsertEventSeries(db, series); err != nil {
+		return nil, 0, err
+	}
+
+	created, err := materializeSeriesEvents(db, series, eventTemplate, rule, time.Time{}, endDate)
+	return series, created, err
+}
+
+// ExtendEventSeries generates the series' events after its current horizon
+// up to the new end date.
+func ExtendEventSeries(db *mongo.Database, seriesID string, endDate time.Time) (int, error) {
+	series, err := GetEventSeriesByID(db, seriesID)
+	if err != nil {
+		return 0, err
+	}
+	endDate = DateOnly(endDate)
+	if !endDate.After(series.EndDate) {
+		return 0, errors.New("new end date must be after the current end date")
+	}
+
+	eventTemplate, err := GetEventTemplateByID(db, series.TemplateID)
+	if err != nil {
+		return 0, err
+	}
+	rule, err := ParseRecurrenceRule(series.Recurrence)
+	if err != nil {
+		return 0, err
+	}
+
+	created, err := materializeSeriesEvents(db, series, eventTemplate, rule, series.EndDate, endDate)
+	if err != nil {
+		return created, err
+	}
+	_, err = UpdateEventSeriesEndDate(db, seriesID, endDate)
+	return created, err
+}
+
+// ShortenEventSeries deletes the series' events after the new end date,
+// which must be before the current one. Later dates go through
+// ExtendEventSeries so the events in between are created.
+func ShortenEventSeries(db *mongo.Database, seriesID string, endDate time.Time) (int64, error) {
+	series, err := GetEventSeriesByID(db, seriesID)
+	if err != nil {
+		return 0, err
+	}
+	endDate = DateOnly(endDate)
+	if endDate.Before(series.StartDate) {
+		return 0, errors.New("new end date is before the series start date")
+	}
+	if !endDate.Before(series.EndDate) {
+		return 0, errors.New("new end date must be before the current end date; extend the series to end it later")
+	}
+
+	res, err := DeleteEventsBySeries(db, seriesID, endDate)
+	if err != nil {
+		return 0, err
+	}
+	_, err = UpdateEventSeriesEndDate(db, seriesID, endDate)
+	return res.DeletedCount, err
+}
+
+// DeleteEventSeriesWithEvents deletes a series and every event generated
+// for it.
+func DeleteEventSeriesWithEvents(db *mongo.Database, seriesID string) (int64, error) {
+	res, err := DeleteEventsBySeries(db, seriesID, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := DeleteEventSeries(db, seriesID); err != nil {
+		return res.DeletedCount, err
+	}
+	return res.DeletedCount, nil
+}
+
+// materializeSeriesEvents inserts an event for every occurrence of the rule
+// that falls after `after` and on or before `until`.
+func materializeSeriesEvents(db *mongo.Database, series *EventSeries, eventTemplate *EventTemplate, rule *RecurrenceRule, after, until time.Time) (int, error) {
+	var events []Event
+	for _, date := range rule.OccurrencesAfter(series.StartDate, after, until) {
+		event := NewEventFromTemplate(eventTemplate, date)
+		event.SeriesID = series.ID
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	if _, err := InsertEvents(db, events); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
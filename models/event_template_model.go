@@ -24,6 +24,7 @@ type EventTemplate struct {
 	EndTime     string     `bson:"endTime,omitempty" json:"endTime" query:"endTime" form:"endTime"`
 	Positions   []Position `bson:"positions,omitempty" json:"positions" query:"positions" form:"positions"`
 	TeamID      string     `bson:"teamId,omitempty" json:"teamId" query:"teamId" form:"teamId"`
+	Recurrence  string     `bson:"recurrence,omitempty" json:"recurrence" query:"recurrence" form:"recurrence"`
 	CreatedAt   time.Time  `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time  `bson:"updatedAt" json:"updatedAt"`
 }
@@ -36,6 +37,7 @@ type EventTemplateView struct {
 	EndTime     string     `bson:"endTime,omitempty" json:"endTime" query:"endTime" form:"endTime"`
 	Positions   []Position `bson:"positions,omitempty" json:"positions" query:"positions" form:"positions"`
 	TeamID      string     `bson:"teamId,omitempty" json:"teamId" query:"teamId" form:"teamId"`
+	Recurrence  string     `bson:"recurrence,omitempty" json:"recurrence" query:"recurrence" form:"recurrence"`
 	Team        Team       `bson:"team,omitempty" json:"team" query:"team" form:"team"`
 	CreatedAt   time.Time  `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time  `bson:"updatedAt" json:"updatedAt"`
@@ -101,6 +103,7 @@ func UpdateEventTemplate(db *mongo.Database, eventTemplate *EventTemplate) (*mon
 		"$set": bson.M{
 			"name":        eventTemplate.Name,
 			"description": eventTemplate.Description,
+			"recurrence":  eventTemplate.Recurrence,
 			"updatedAt":   time.Now(),
 		},
 	}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// icsEscaper escapes text values in iCalendar content lines.
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// MemberScheduleICS returns an iCalendar file with one event for each of a
+// member's assignments that hasn't started by now, so it can be added to
+// their calendar. teams maps team IDs to names. The event UIDs are stable,
+// so importing a later file updates the same entries.
+func MemberScheduleICS(memberID string, events []MemberScheduleEvent, teams map[string]string, now time.Time) []byte {
+	var b strings.Builder
+	line := func(s string) {
+		// Lines are folded at 75 octets, continuing with a space
+		for len(s) > 75 {
+			cut := 75
+			for cut > 0 && !utf8Start(s[cut]) {
+				cut--
+			}
+			b.WriteString(s[:cut] + "\r\n")
+			s = " " + s[cut:]
+		}
+		b.WriteString(s + "\r\n")
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//nltst_scheduler//Schedule//EN")
+	line("METHOD:PUBLISH")
+	for _, e := range events {
+		if !e.StartAt.IsZero() && e.StartAt.Before(now) {
+			continue
+		}
+		position := strings.Join(e.PositionNames, " and ")
+		if position == "" {
+			position = e.PositionName
+		}
+		summary := e.Name
+		if position != "" {
+			summary = position + " - " + e.Name
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:" + e.ID + "-" + memberID + "@nltst_scheduler")
+		line("DTSTAMP:" + stamp)
+		if e.StartAt.IsZero() {
+			line("DTSTART;VALUE=DATE:" + e.Date.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + e.Date.AddDate(0, 0, 1).Format("20060102"))
+		} else {
+			line("DTSTART:" + e.StartAt.UTC().Format("20060102T150405Z"))
+			if !e.EndAt.IsZero() {
+				line("DTEND:" + e.EndAt.UTC().Format("20060102T150405Z"))
+			}
+		}
+		line("SUMMARY:" + icsEscaper.Replace(summary))
+		if team := teams[e.TeamID]; team != "" {
+			line("DESCRIPTION:" + icsEscaper.Replace(team))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// utf8Start reports whether c begins a UTF-8 encoded character, so folding
+// never splits one.
+func utf8Start(c byte) bool {
+	return c&0xC0 != 0x80
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestMemberScheduleICS(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []MemberScheduleEvent{
+		{ID: "past", Name: "Last week", StartAt: now.Add(-time.Hour)},
+		{ID: "e1", Name: "Sunday Service, 9am", StartAt: now.Add(24 * time.Hour), EndAt: now.Add(26 * time.Hour), TeamID: "t1", PositionNames: []string{"Camera", "Lights"}},
+		{ID: "e2", Name: "Café night; " + strings.Repeat("é", 40), Date: time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC), PositionName: "Sound"},
+	}
+	ics := string(MemberScheduleICS("m1", events, map[string]string{"t1": "Media"}, now))
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:e1-m1@nltst_scheduler\r\n",
+		"DTSTART:20260302T120000Z\r\nDTEND:20260302T140000Z\r\n",
+		`SUMMARY:Camera and Lights - Sunday Service\, 9am` + "\r\n",
+		"DESCRIPTION:Media\r\n",
+		"DTSTART;VALUE=DATE:20260304\r\nDTEND;VALUE=DATE:20260305\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("calendar is missing %q:\n%s", want, ics)
+		}
+	}
+	if strings.Contains(ics, "UID:past-") {
+		t.Error("calendar includes an event that already started")
+	}
+
+	for _, l := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line is %d octets: %q", len(l), l)
+		}
+		if !utf8.ValidString(l) {
+			t.Errorf("folding split a character: %q", l)
+		}
+	}
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	if !strings.Contains(unfolded, `SUMMARY:Sound - Café night\; `+strings.Repeat("é", 40)+"\r\n") {
+		t.Errorf("folded summary does not unfold to the original:\n%s", ics)
+	}
+}
@@ -12,13 +12,46 @@ import (
 const MemberCollection = "members"
 
 type Member struct {
-	ID          string    `bson:"_id" json:"_id"`
-	FirstName   string    `json:"firstName" bson:"firstName" query:"firstName" form:"firstName"`
-	LastName    string    `json:"lastName" bson:"lastName" query:"lastName" form:"lastName"`
-	Email       string    `json:"email" bson:"email" query:"email" form:"email"`
-	PhoneNumber string    `json:"phoneNumber" bson:"phoneNumber" query:"phoneNumber" form:"phoneNumber"`
-	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
+	ID          string `bson:"_id" json:"_id"`
+	FirstName   string `json:"firstName" bson:"firstName" query:"firstName" form:"firstName"`
+	LastName    string `json:"lastName" bson:"lastName" query:"lastName" form:"lastName"`
+	Email       string `json:"email" bson:"email" query:"email" form:"email"`
+	PhoneNumber string `json:"phoneNumber" bson:"phoneNumber" query:"phoneNumber" form:"phoneNumber"`
+	// PhoneNormalized is PhoneNumber in E.164 form, for matching inbound texts.
+	PhoneNormalized string             `json:"-" bson:"phoneNormalized,omitempty" form:"-"`
+	Availability    []AvailabilityRule `json:"availability" bson:"availability,omitempty" form:"-"`
+	// PreferredChannel, when set, is the only channel the member is sent
+	// messages on, as long as they accept it.
+	PreferredChannel string `json:"preferredChannel" bson:"preferredChannel,omitempty" form:"preferredChannel"`
+	// Quiet hours ("15:04", organization time zone) hold messages until
+	// they end.
+	QuietHoursStart string `json:"quietHoursStart" bson:"quietHoursStart,omitempty" form:"quietHoursStart"`
+	QuietHoursEnd   string `json:"quietHoursEnd" bson:"quietHoursEnd,omitempty" form:"quietHoursEnd"`
+	// Consent is changed through ApplyMemberConsent and the SMS keywords so
+	// every change lands in ConsentHistory.
+	SMSOptInAt     *time.Time     `json:"smsOptInAt" bson:"smsOptInAt,omitempty" form:"-"`
+	EmailOptInAt   *time.Time     `json:"emailOptInAt" bson:"emailOptInAt,omitempty" form:"-"`
+	OptedOut       bool           `json:"optedOut" bson:"optedOut,omitempty" form:"-"`
+	OptedOutAt     *time.Time     `json:"optedOutAt" bson:"optedOutAt,omitempty" form:"-"`
+	ConsentHistory []ConsentEvent `json:"consentHistory" bson:"consentHistory,omitempty" form:"-"`
+	// Telegram chat the member linked through the bot, and the one-time
+	// code for linking it.
+	TelegramChatID        string     `json:"telegramChatId" bson:"telegramChatId,omitempty" form:"-"`
+	TelegramUsername      string     `json:"telegramUsername" bson:"telegramUsername,omitempty" form:"-"`
+	TelegramOptInAt       *time.Time `json:"telegramOptInAt" bson:"telegramOptInAt,omitempty" form:"-"`
+	TelegramLinkCode      string     `json:"-" bson:"telegramLinkCode,omitempty" form:"-"`
+	TelegramLinkExpiresAt *time.Time `json:"-" bson:"telegramLinkExpiresAt,omitempty" form:"-"`
+	// Delivery problems providers reported for the member's phone number
+	// and email address. They clear when a later message gets through or
+	// the contact detail changes.
+	SMSDeliveryProblem     string     `json:"smsDeliveryProblem" bson:"smsDeliveryProblem,omitempty" form:"-"`
+	SMSDeliveryProblemAt   *time.Time `json:"smsDeliveryProblemAt" bson:"smsDeliveryProblemAt,omitempty" form:"-"`
+	EmailDeliveryProblem   string     `json:"emailDeliveryProblem" bson:"emailDeliveryProblem,omitempty" form:"-"`
+	EmailDeliveryProblemAt *time.Time `json:"emailDeliveryProblemAt" bson:"emailDeliveryProblemAt,omitempty" form:"-"`
+	// TextInChurchID is the member's contact ID in TextInChurch, once synced.
+	TextInChurchID string    `json:"textInChurchId" bson:"textInChurchId,omitempty" form:"-"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt" bson:"updatedAt"`
 }
 
 func (m *Member) FullName() string {
@@ -83,6 +116,8 @@ func InsertMember(db *mongo.Database, member *Member) (*mongo.InsertOneResult, e
 	member.ID = uuid.NewString()
 	member.CreatedAt = time.Now()
 	member.UpdatedAt = time.Now()
+	member.PhoneNormalized = NormalizePhone(member.PhoneNumber)
+	cleanPreferences(member)
 	collection := db.Collection(MemberCollection)
 	res, err := collection.InsertOne(context.TODO(), member)
 	return res, err
@@ -91,19 +126,32 @@ func InsertMember(db *mongo.Database, member *Member) (*mongo.InsertOneResult, e
 func UpdateMember(db *mongo.Database, id string, member *Member) (*mongo.UpdateResult, error) {
 	collection := db.Collection(MemberCollection)
 	filter := bson.M{"_id": id}
+	cleanPreferences(member)
 	update := bson.M{
 		"$set": bson.M{
-			"firstName":   member.FirstName,
-			"lastName":    member.LastName,
-			"email":       member.Email,
-			"phoneNumber": member.PhoneNumber,
-			"updatedAt":   time.Now(),
+			"firstName":        member.FirstName,
+			"lastName":         member.LastName,
+			"email":            member.Email,
+			"phoneNumber":      member.PhoneNumber,
+			"phoneNormalized":  NormalizePhone(member.PhoneNumber),
+			"preferredChannel": member.PreferredChannel,
+			"quietHoursStart":  member.QuietHoursStart,
+			"quietHoursEnd":    member.QuietHoursEnd,
+			"updatedAt":        time.Now(),
 		},
 	}
 	res, err := collection.UpdateOne(context.TODO(), filter, update)
 	return res, err
 }
 
+// SetMemberTextInChurchID records the TextInChurch contact a member was
+// synced to.
+func SetMemberTextInChurchID(db *mongo.Database, id, contactID string) error {
+	collection := db.Collection(MemberCollection)
+	_, err := collection.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"textInChurchId": contactID}})
+	return err
+}
+
 func DeleteMember(db *mongo.Database, id string) (*mongo.DeleteResult, error) {
 	collection := db.Collection(MemberCollection)
 	res, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
@@ -120,10 +168,16 @@ func GetMemberByEmail(db *mongo.Database, email string) (*Member, error) {
 	return &member, nil
 }
 
+// GetMemberByPhone finds a member by phone number, however either number
+// is formatted.
 func GetMemberByPhone(db *mongo.Database, phone string) (*Member, error) {
 	collection := db.Collection(MemberCollection)
 	var member Member
-	err := collection.FindOne(context.TODO(), bson.M{"phone": phone}).Decode(&member)
+	normalized := NormalizePhone(phone)
+	if normalized == "" {
+		return nil, mongo.ErrNoDocuments
+	}
+	err := collection.FindOne(context.TODO(), bson.M{"phoneNormalized": normalized}).Decode(&member)
 	if err != nil {
 		return nil, err
 	}
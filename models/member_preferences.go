@@ -0,0 +1,273 @@
+package models
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Consent actions recorded in a member's consent history. An event without
+// a channel applies to all messages.
+const (
+	ConsentOptIn  = "opt_in"
+	ConsentOptOut = "opt_out"
+)
+
+// Where a consent change came from.
+const (
+	ConsentSourceAdmin     = "admin"
+	ConsentSourceSMS       = "sms"
+	ConsentSourceMigration = "migration"
+	ConsentSourceTelegram  = "telegram"
+)
+
+// Keywords members can text in to manage their messages.
+const (
+	KeywordStop  = "stop"
+	KeywordStart = "start"
+	KeywordHelp  = "help"
+)
+
+// ConsentEvent is one change to what a member has agreed to receive.
+type ConsentEvent struct {
+	At      time.Time `bson:"at" json:"at"`
+	Action  string    `bson:"action" json:"action"`
+	Channel string    `bson:"channel,omitempty" json:"channel"`
+	Source  string    `bson:"source" json:"source"`
+	Note    string    `bson:"note,omitempty" json:"note"`
+}
+
+// Summary describes the event for the consent history, e.g. "Opted in to
+// SMS".
+func (e ConsentEvent) Summary() string {
+	what := "all messages"
+	switch e.Channel {
+	case ChannelSMS:
+		what = "SMS"
+	case ChannelEmail:
+		what = "email"
+	case ChannelTelegram:
+		what = "Telegram"
+	}
+	if e.Action == ConsentOptOut {
+		return "Opted out of " + what
+	}
+	return "Opted in to " + what
+}
+
+// ConsentChoice is what an admin has recorded a member as agreeing to.
+type ConsentChoice struct {
+	SMS      bool
+	Email    bool
+	OptedOut bool
+}
+
+// ParseSMSKeyword recognizes the carrier keywords for stopping, restarting
+// and getting help with texts. It returns "" for any other message.
+func ParseSMSKeyword(body string) string {
+	switch strings.ToUpper(strings.Trim(strings.TrimSpace(body), ".!")) {
+	case "STOP", "STOPALL", "UNSUBSCRIBE", "CANCEL", "END", "QUIT":
+		return KeywordStop
+	case "START", "UNSTOP":
+		return KeywordStart
+	case "HELP", "INFO":
+		return KeywordHelp
+	}
+	return ""
+}
+
+// Accepts reports whether member may be sent messages on channel: they
+// have agreed to it, have contact details for it and have not opted out.
+func (m *Member) Accepts(channel string) bool {
+	if m.OptedOut {
+		return false
+	}
+	switch channel {
+	case ChannelSMS:
+		return m.SMSOptInAt != nil && m.PhoneNumber != ""
+	case ChannelEmail:
+		return m.EmailOptInAt != nil && m.Email != ""
+	case ChannelTelegram:
+		return m.TelegramOptInAt != nil && m.TelegramChatID != ""
+	}
+	return false
+}
+
+// Channels narrows available to the channels member accepts. A member with
+// a preferred channel they accept only gets that one.
+func (m *Member) Channels(available []string) []string {
+	var accepted []string
+	for _, channel := range available {
+		if m.Accepts(channel) {
+			accepted = append(accepted, channel)
+		}
+	}
+	if m.PreferredChannel != "" && slices.Contains(accepted, m.PreferredChannel) {
+		return []string{m.PreferredChannel}
+	}
+	return accepted
+}
+
+// QuietUntil returns when the member's quiet hours end if now falls inside
+// them, or the zero time if messages can go now. Quiet hours are wall clock
+// times in the organization time zone and may run past midnight.
+func (m *Member) QuietUntil(now time.Time) time.Time {
+	local := now.In(location)
+	start, ok := AtClock(local, m.QuietHoursStart)
+	if !ok {
+		return time.Time{}
+	}
+	end, ok := AtClock(local, m.QuietHoursEnd)
+	if !ok || start.Equal(end) {
+		return time.Time{}
+	}
+	if start.Before(end) {
+		if !now.Before(start) && now.Before(end) {
+			return end
+		}
+		return time.Time{}
+	}
+	// Overnight, e.g. 21:00 to 08:00
+	if now.Before(end) {
+		return end
+	}
+	if !now.Before(start) {
+		end, _ = AtClock(local.AddDate(0, 0, 1), m.QuietHoursEnd)
+		return end
+	}
+	return time.Time{}
+}
+
+// QuietHours describes the member's quiet hours, or "" when they have none.
+func (m *Member) QuietHours() string {
+	if m.QuietHoursStart == "" || m.QuietHoursEnd == "" {
+		return ""
+	}
+	return m.QuietHoursStart + " to " + m.QuietHoursEnd
+}
+
+// cleanPreferences drops preference values the form can't have meant.
+func cleanPreferences(member *Member) {
+	switch member.PreferredChannel {
+	case ChannelSMS, ChannelEmail, ChannelTelegram:
+	default:
+		member.PreferredChannel = ""
+	}
+	if _, err := time.Parse("15:04", member.QuietHoursStart); err != nil {
+		member.QuietHoursStart = ""
+	}
+	if _, err := time.Parse("15:04", member.QuietHoursEnd); err != nil {
+		member.QuietHoursEnd = ""
+	}
+}
+
+// ApplyMemberConsent brings member's consent in line with choice,
+// recording each change in their consent history.
+func ApplyMemberConsent(db *mongo.Database, member *Member, choice ConsentChoice, source string) error {
+	now := time.Now()
+	set := bson.M{}
+	unset := bson.M{}
+	var events []ConsentEvent
+
+	channel := func(name, field string, current *time.Time, want bool) {
+		if want && current == nil {
+			set[field] = now
+			events = append(events, ConsentEvent{At: now, Action: ConsentOptIn, Channel: name, Source: source})
+		} else if !want && current != nil {
+			unset[field] = ""
+			events = append(events, ConsentEvent{At: now, Action: ConsentOptOut, Channel: name, Source: source})
+		}
+	}
+	channel(ChannelSMS, "smsOptInAt", member.SMSOptInAt, choice.SMS)
+	channel(ChannelEmail, "emailOptInAt", member.EmailOptInAt, choice.Email)
+
+	if choice.OptedOut && !member.OptedOut {
+		set["optedOut"] = true
+		set["optedOutAt"] = now
+		events = append(events, ConsentEvent{At: now, Action: ConsentOptOut, Source: source})
+	} else if !choice.OptedOut && member.OptedOut {
+		unset["optedOut"] = ""
+		unset["optedOutAt"] = ""
+		events = append(events, ConsentEvent{At: now, Action: ConsentOptIn, Source: source})
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+	update := bson.M{"$push": bson.M{"consentHistory": bson.M{"$each": events}}}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	_, err := db.Collection(MemberCollection).UpdateOne(context.TODO(), bson.M{"_id": member.ID}, update)
+	return err
+}
+
+// OptOutMember stops all messages to a member, as when they text STOP.
+func OptOutMember(db *mongo.Database, memberID, source, note string) error {
+	now := time.Now()
+	_, err := db.Collection(MemberCollection).UpdateOne(context.TODO(), bson.M{"_id": memberID}, bson.M{
+		"$set":  bson.M{"optedOut": true, "optedOutAt": now},
+		"$push": bson.M{"consentHistory": ConsentEvent{At: now, Action: ConsentOptOut, Source: source, Note: note}},
+	})
+	return err
+}
+
+// OptInMemberSMS lifts a member's opt-out and records their agreement to
+// texts, as when they text START.
+func OptInMemberSMS(db *mongo.Database, memberID, source, note string) error {
+	now := time.Now()
+	_, err := db.Collection(MemberCollection).UpdateOne(context.TODO(), bson.M{"_id": memberID}, bson.M{
+		"$set":   bson.M{"smsOptInAt": now},
+		"$unset": bson.M{"optedOut": "", "optedOutAt": ""},
+		"$push":  bson.M{"consentHistory": ConsentEvent{At: now, Action: ConsentOptIn, Channel: ChannelSMS, Source: source, Note: note}},
+	})
+	return err
+}
+
+// MigrateMemberConsent records consent for the contact details of members
+// saved before consent was tracked, so they keep getting the messages they
+// did before. Members already opted out stay opted out. It returns the
+// number of members whose consent was recorded.
+func MigrateMemberConsent(db *mongo.Database) (int, error) {
+	collection := db.Collection(MemberCollection)
+	cursor, err := collection.Find(context.TODO(), bson.M{"consentHistory": bson.M{"$exists": false}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.TODO())
+
+	migrated := 0
+	for cursor.Next(context.TODO()) {
+		var member Member
+		if err := cursor.Decode(&member); err != nil {
+			return migrated, err
+		}
+		err := ApplyMemberConsent(db, &member, ConsentChoice{
+			SMS:      member.PhoneNumber != "",
+			Email:    member.Email != "",
+			OptedOut: member.OptedOut,
+		}, ConsentSourceMigration)
+		if err != nil {
+			return migrated, err
+		}
+		// A member with nothing to record, such as one without contact
+		// details, gets an empty history so later starts pass over them
+		res, err := collection.UpdateOne(context.TODO(),
+			bson.M{"_id": member.ID, "consentHistory": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"consentHistory": bson.A{}}})
+		if err != nil {
+			return migrated, err
+		}
+		if res.ModifiedCount == 0 {
+			migrated++
+		}
+	}
+	return migrated, cursor.Err()
+}
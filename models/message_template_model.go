@@ -0,0 +1,399 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const MessageTemplateCollection = "message_templates"
+
+// Message template keys, one for each kind of message the app sends.
+const (
+	TemplateReminder       = "reminder"
+	TemplateScheduleChange = "schedule_change"
+	TemplateSwapOffer      = "swap_offer"
+	TemplateSwapCovered    = "swap_covered"
+	TemplateSwapTaken      = "swap_taken"
+	TemplateSwapLeader     = "swap_leader"
+	TemplateDeclineAlert   = "decline_alert"
+	TemplateDigest         = "digest"
+)
+
+var ErrUnknownTemplate = errors.New("unknown message template")
+
+// MessageTemplate is the editable wording of one kind of message. Each
+// part is a Go template over MessageData. SMS is sent by text; the email
+// parts are sent by email, with EmailHTML as the optional rich version of
+// EmailText.
+type MessageTemplate struct {
+	Key          string    `bson:"_id" json:"key" query:"key" form:"key"`
+	Name         string    `bson:"name" json:"name" query:"-" form:"-"`
+	Description  string    `bson:"description" json:"description" query:"-" form:"-"`
+	SMS          string    `bson:"sms" json:"sms" query:"sms" form:"sms"`
+	EmailSubject string    `bson:"emailSubject" json:"emailSubject" query:"emailSubject" form:"emailSubject"`
+	EmailText    string    `bson:"emailText" json:"emailText" query:"emailText" form:"emailText"`
+	EmailHTML    string    `bson:"emailHtml" json:"emailHtml" query:"emailHtml" form:"emailHtml"`
+	UpdatedAt    time.Time `bson:"updatedAt,omitempty" json:"updatedAt" query:"-" form:"-"`
+}
+
+// AssignmentLinks are the signed links for replying to one assignment.
+type AssignmentLinks struct {
+	Position    string
+	ConfirmLink string
+	DeclineLink string
+}
+
+// ChangeLine is one change in a schedule change message. New assignments
+// carry links for replying to them.
+type ChangeLine struct {
+	Text        string
+	ConfirmLink string
+	DeclineLink string
+}
+
+// MessageData holds the placeholders available to message templates.
+// FirstName and MemberName are the recipient's; FromName and ToName are
+// the members giving up and taking over a slot. Period and Schedule are
+// filled in for schedule digests.
+type MessageData struct {
+	FirstName   string
+	MemberName  string
+	EventName   string
+	Date        string
+	Time        string
+	When        string
+	Position    string
+	ConfirmLink string
+	DeclineLink string
+	SwapLink    string
+	FromName    string
+	ToName      string
+	Assignments []AssignmentLinks
+	Changes     []ChangeLine
+	Period      string
+	Schedule    []ScheduleLine
+}
+
+// MessagePlaceholder documents a field of MessageData for template editors.
+type MessagePlaceholder struct {
+	Name        string
+	Description string
+}
+
+var MessagePlaceholders = []MessagePlaceholder{
+	{"{{.FirstName}}", "Recipient's first name"},
+	{"{{.MemberName}}", "Recipient's full name"},
+	{"{{.EventName}}", "Event name"},
+	{"{{.Date}}", "Event date, e.g. Oct 18"},
+	{"{{.Time}}", "Event start time, e.g. 9:00 AM"},
+	{"{{.When}}", "Event day and time, e.g. Sun, Oct 18 at 9:00 AM"},
+	{"{{.Position}}", "Position names"},
+	{"{{.ConfirmLink}}", "Link to confirm the first position"},
+	{"{{.DeclineLink}}", "Link to decline the first position"},
+	{"{{.SwapLink}}", "Link to take a swap"},
+	{"{{.FromName}}", "Member giving up or declining a slot"},
+	{"{{.ToName}}", "Member taking over a slot"},
+	{"{{range .Assignments}}…{{end}}", "Each position with .Position, .ConfirmLink and .DeclineLink"},
+	{"{{range .Changes}}…{{end}}", "Each schedule change with .Text, .ConfirmLink and .DeclineLink"},
+	{"{{.Period}}", "Days a digest covers, e.g. Oct 19 - Oct 25"},
+	{"{{range .Schedule}}…{{end}}", "Each upcoming assignment with .Date, .Time, .When, .EventName, .Team and .Position"},
+}
+
+// NewMessageData fills in the recipient and event placeholders. positions
+// are the recipient's positions at the event.
+func NewMessageData(member *Member, event *Event, positions ...string) MessageData {
+	data := MessageData{}
+	if member != nil {
+		data.FirstName = member.FirstName
+		data.MemberName = member.FullName()
+	}
+	if event != nil {
+		data.EventName = event.Name
+		data.Date = event.Date.Format("Jan 2")
+		data.When = event.When()
+		if !event.StartAt.IsZero() {
+			data.Time = event.StartAt.In(location).Format("3:04 PM")
+		}
+	}
+	data.Position = strings.Join(positions, " and ")
+	return data
+}
+
+// DefaultMessageTemplates is the wording each template starts with, and
+// what it returns to when reset.
+var DefaultMessageTemplates = []MessageTemplate{
+	{
+		Key:          TemplateReminder,
+		Name:         "Reminder",
+		Description:  "Sent to each assigned member ahead of an event.",
+		SMS:          "Hi {{.FirstName}}, a reminder that you're serving {{.Position}} for {{.EventName}} on {{.When}}. Reply YES to confirm or NO if you can't make it.",
+		EmailSubject: "Reminder: {{.EventName}} on {{.Date}}",
+		EmailText: "Hi {{.FirstName}}, a reminder that you're serving {{.Position}} for {{.EventName}} on {{.When}}.\n" +
+			"{{range .Assignments}}\n{{.Position}}:\n  Confirm: {{.ConfirmLink}}\n  Can't make it: {{.DeclineLink}}\n{{end}}",
+		EmailHTML: "<p>Hi {{.FirstName}},</p>\n<p>A reminder that you're serving <strong>{{.Position}}</strong> for {{.EventName}} on {{.When}}.</p>\n" +
+			"{{range .Assignments}}<p>{{.Position}}: <a href=\"{{.ConfirmLink}}\">Confirm</a> &middot; <a href=\"{{.DeclineLink}}\">I can't make it</a></p>\n{{end}}",
+	},
+	{
+		Key:          TemplateScheduleChange,
+		Name:         "Schedule change",
+		Description:  "Sent when a member is added to, removed from or moved on the schedule.",
+		SMS:          "Hi {{.FirstName}}, your schedule has changed:{{range .Changes}}\n- {{.Text}}{{end}}",
+		EmailSubject: "Your schedule has changed",
+		EmailText: "Hi {{.FirstName}}, your schedule has changed:\n" +
+			"{{range .Changes}}- {{.Text}}\n{{if .ConfirmLink}}  Confirm: {{.ConfirmLink}}\n  Can't make it: {{.DeclineLink}}\n{{end}}{{end}}",
+		EmailHTML: "<p>Hi {{.FirstName}}, your schedule has changed:</p>\n<ul>\n" +
+			"{{range .Changes}}<li>{{.Text}}{{if .ConfirmLink}} &mdash; <a href=\"{{.ConfirmLink}}\">Confirm</a> &middot; <a href=\"{{.DeclineLink}}\">I can't make it</a>{{end}}</li>\n{{end}}</ul>",
+	},
+	{
+		Key:          TemplateSwapOffer,
+		Name:         "Swap offer",
+		Description:  "Sent to teammates who could cover a slot someone asked to swap.",
+		SMS:          "{{.FromName}} needs someone to cover {{.Position}} for {{.EventName}} on {{.When}}. Take the slot: {{.SwapLink}}",
+		EmailSubject: "Can you cover {{.Position}} on {{.Date}}?",
+		EmailText:    "{{.FromName}} needs someone to cover {{.Position}} for {{.EventName}} on {{.When}}. Take the slot: {{.SwapLink}}",
+		EmailHTML:    "<p>{{.FromName}} needs someone to cover <strong>{{.Position}}</strong> for {{.EventName}} on {{.When}}.</p>\n<p><a href=\"{{.SwapLink}}\">Take the slot</a></p>",
+	},
+	{
+		Key:          TemplateSwapCovered,
+		Name:         "Swap accepted (previous member)",
+		Description:  "Sent to the member whose slot was taken by a teammate.",
+		SMS:          "{{.ToName}} is covering {{.Position}} for {{.EventName}} on {{.When}}. You are no longer scheduled.",
+		EmailSubject: "Swap accepted: {{.Position}} on {{.Date}}",
+		EmailText:    "{{.ToName}} is covering {{.Position}} for {{.EventName}} on {{.When}}. You are no longer scheduled.",
+		EmailHTML:    "<p>{{.ToName}} is covering <strong>{{.Position}}</strong> for {{.EventName}} on {{.When}}. You are no longer scheduled.</p>",
+	},
+	{
+		Key:          TemplateSwapTaken,
+		Name:         "Swap accepted (new member)",
+		Description:  "Sent to the member who took over a slot.",
+		SMS:          "You are now scheduled for {{.Position}} for {{.EventName}} on {{.When}}. Thanks for covering for {{.FromName}}.",
+		EmailSubject: "Swap accepted: {{.Position}} on {{.Date}}",
+		EmailText:    "You are now scheduled for {{.Position}} for {{.EventName}} on {{.When}}. Thanks for covering for {{.FromName}}.",
+		EmailHTML:    "<p>You are now scheduled for <strong>{{.Position}}</strong> for {{.EventName}} on {{.When}}. Thanks for covering for {{.FromName}}.</p>",
+	},
+	{
+		Key:          TemplateSwapLeader,
+		Name:         "Swap accepted (team leader)",
+		Description:  "Sent to the team leader when a slot changes hands.",
+		SMS:          "{{.ToName}} took over {{.Position}} for {{.EventName}} on {{.When}} from {{.FromName}}.",
+		EmailSubject: "Swap accepted: {{.Position}} on {{.Date}}",
+		EmailText:    "{{.ToName}} took over {{.Position}} for {{.EventName}} on {{.When}} from {{.FromName}}.",
+		EmailHTML:    "<p>{{.ToName}} took over <strong>{{.Position}}</strong> for {{.EventName}} on {{.When}} from {{.FromName}}.</p>",
+	},
+	{
+		Key:          TemplateDeclineAlert,
+		Name:         "Decline alert",
+		Description:  "Sent to the team leader when a member declines an assignment.",
+		SMS:          "{{.FromName}} has declined {{.Position}} for {{.EventName}} on {{.When}}. The slot needs covering.",
+		EmailSubject: "{{.FromName}} can't make {{.Date}}",
+		EmailText:    "{{.FromName}} has declined {{.Position}} for {{.EventName}} on {{.When}}. The slot needs covering.",
+		EmailHTML:    "<p>{{.FromName}} has declined <strong>{{.Position}}</strong> for {{.EventName}} on {{.When}}. The slot needs covering.</p>",
+	},
+	{
+		Key:          TemplateDigest,
+		Name:         "Schedule digest",
+		Description:  "Sent to each member on the digest day with their upcoming assignments. The text version goes to members who don't get email.",
+		SMS:          "Hi {{.FirstName}}, your schedule for {{.Period}}:{{range .Schedule}}\n- {{.When}}: {{.EventName}} ({{.Position}}){{else}} nothing scheduled.{{end}}",
+		EmailSubject: "Your schedule for {{.Period}}",
+		EmailText: "Hi {{.FirstName}}, here's your schedule for {{.Period}}:\n" +
+			"{{range .Schedule}}\n{{.Date}}, {{.Time}}\n  {{.EventName}}: {{.Position}}{{if .Team}} ({{.Team}}){{end}}\n{{else}}\nYou're not scheduled to serve.\n{{end}}",
+		EmailHTML: "<p>Hi {{.FirstName}}, here's your schedule for {{.Period}}:</p>\n" +
+			"{{if .Schedule}}<table cellpadding=\"6\" style=\"border-collapse: collapse\">\n" +
+			"<tr style=\"text-align: left; border-bottom: 1px solid #ccc\"><th>Date</th><th>Time</th><th>Event</th><th>Team</th><th>Position</th></tr>\n" +
+			"{{range .Schedule}}<tr><td>{{.Date}}</td><td>{{.Time}}</td><td>{{.EventName}}</td><td>{{.Team}}</td><td><strong>{{.Position}}</strong></td></tr>\n{{end}}" +
+			"</table>{{else}}<p>You're not scheduled to serve.</p>{{end}}",
+	},
+}
+
+// DefaultMessageTemplate returns the built-in wording for key.
+func DefaultMessageTemplate(key string) (*MessageTemplate, error) {
+	for _, t := range DefaultMessageTemplates {
+		if t.Key == key {
+			return &t, nil
+		}
+	}
+	return nil, ErrUnknownTemplate
+}
+
+// SeedMessageTemplates stores the default wording for any template not yet
+// in the database, leaving edited templates alone.
+func SeedMessageTemplates(db *mongo.Database) error {
+	collection := db.Collection(MessageTemplateCollection)
+	for _, t := range DefaultMessageTemplates {
+		_, err := collection.UpdateOne(context.TODO(), bson.M{"_id": t.Key},
+			bson.M{"$setOnInsert": t}, options.UpdateOne().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMessageTemplates returns every template in the order they are listed
+// in DefaultMessageTemplates.
+func GetMessageTemplates(db *mongo.Database) ([]MessageTemplate, error) {
+	var templates []MessageTemplate
+	for _, t := range DefaultMessageTemplates {
+		stored, err := GetMessageTemplate(db, t.Key)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *stored)
+	}
+	return templates, nil
+}
+
+// GetMessageTemplate returns the stored template for key, falling back to
+// the default wording if it has not been saved.
+func GetMessageTemplate(db *mongo.Database, key string) (*MessageTemplate, error) {
+	fallback, err := DefaultMessageTemplate(key)
+	if err != nil {
+		return nil, err
+	}
+	var t MessageTemplate
+	err = db.Collection(MessageTemplateCollection).FindOne(context.TODO(), bson.M{"_id": key}).Decode(&t)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fallback, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Names and descriptions come from the code so they stay current
+	t.Name, t.Description = fallback.Name, fallback.Description
+	return &t, nil
+}
+
+// UpdateMessageTemplate saves new wording for a template after checking
+// that every part parses.
+func UpdateMessageTemplate(db *mongo.Database, t *MessageTemplate) error {
+	if _, err := DefaultMessageTemplate(t.Key); err != nil {
+		return err
+	}
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	_, err := db.Collection(MessageTemplateCollection).UpdateOne(context.TODO(), bson.M{"_id": t.Key},
+		bson.M{"$set": bson.M{
+			"sms":          t.SMS,
+			"emailSubject": t.EmailSubject,
+			"emailText":    t.EmailText,
+			"emailHtml":    t.EmailHTML,
+			"updatedAt":    time.Now(),
+		}}, options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+// ResetMessageTemplate puts a template back to its default wording.
+func ResetMessageTemplate(db *mongo.Database, key string) (*MessageTemplate, error) {
+	t, err := DefaultMessageTemplate(key)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Collection(MessageTemplateCollection).ReplaceOne(context.TODO(), bson.M{"_id": key}, t,
+		options.Replace().SetUpsert(true))
+	return t, err
+}
+
+// sampleMessageData has every placeholder filled in and one entry in each
+// list, so Validate reaches the fields used inside ranges and ifs.
+var sampleMessageData = MessageData{
+	FirstName: "Jo", MemberName: "Jo Smith", EventName: "Sunday Service",
+	Date: "Oct 18", Time: "9:00 AM", When: "Sun, Oct 18 at 9:00 AM", Position: "Camera",
+	ConfirmLink: "https://example.com/confirm", DeclineLink: "https://example.com/decline", SwapLink: "https://example.com/swap",
+	FromName: "Sam Lee", ToName: "Jo Smith", Period: "Oct 19 - Oct 25",
+	Assignments: []AssignmentLinks{{Position: "Camera", ConfirmLink: "https://example.com/confirm", DeclineLink: "https://example.com/decline"}},
+	Changes:     []ChangeLine{{Text: "Added: Camera for Sunday Service", ConfirmLink: "https://example.com/confirm", DeclineLink: "https://example.com/decline"}},
+	Schedule:    []ScheduleLine{{Date: "Oct 18", Time: "9:00 AM", When: "Sun, Oct 18 at 9:00 AM", EventName: "Sunday Service", Team: "Media", Position: "Camera"}},
+}
+
+// Validate checks that every part of the template parses and only uses
+// placeholders MessageData has.
+func (t *MessageTemplate) Validate() error {
+	_, err := t.Render(sampleMessageData)
+	return err
+}
+
+// Render fills in the template with data. The HTML part is escaped as
+// HTML; the other parts are used as written.
+func (t *MessageTemplate) Render(data MessageData) (MemberMessage, error) {
+	var msg MemberMessage
+	var err error
+	if msg.Body, err = renderText("SMS", t.SMS, data); err != nil {
+		return msg, err
+	}
+	if msg.Subject, err = renderText("subject", t.EmailSubject, data); err != nil {
+		return msg, err
+	}
+	// Subjects are a single line
+	msg.Subject = strings.Join(strings.Fields(msg.Subject), " ")
+	if msg.EmailBody, err = renderText("email text", t.EmailText, data); err != nil {
+		return msg, err
+	}
+	if strings.TrimSpace(t.EmailHTML) != "" {
+		tmpl, err := htmltemplate.New("email HTML").Parse(t.EmailHTML)
+		if err != nil {
+			return msg, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return msg, err
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
+
+func renderText(name, text string, data MessageData) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// RenderMemberMessage renders the template stored under templateKey for
+// data. key becomes the message's idempotency key.
+func RenderMemberMessage(db *mongo.Database, templateKey string, data MessageData, key string) (MemberMessage, error) {
+	t, err := GetMessageTemplate(db, templateKey)
+	if err != nil {
+		return MemberMessage{}, err
+	}
+	msg, err := t.Render(data)
+	if err != nil {
+		return msg, err
+	}
+	msg.Key = key
+	return msg, nil
+}
+
+// GetNextStaffedEvent returns the next upcoming event with at least one
+// member assigned, or nil when there is none.
+func GetNextStaffedEvent(db *mongo.Database, now time.Time) (*Event, error) {
+	filter := bson.M{
+		"positionAssignments.memberId": bson.M{"$nin": bson.A{nil, ""}},
+		"date":                         bson.M{"$gte": DateOnly(now.In(location))},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "startAt", Value: 1}})
+	var event Event
+	err := db.Collection(EventCollection).FindOne(context.TODO(), filter, opts).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDefaultMessageTemplatesRender(t *testing.T) {
+	for _, tmpl := range DefaultMessageTemplates {
+		msg, err := tmpl.Render(sampleMessageData)
+		if err != nil {
+			t.Errorf("%s: %v", tmpl.Key, err)
+			continue
+		}
+		for part, text := range map[string]string{"SMS": msg.Body, "subject": msg.Subject, "email text": msg.EmailBody, "email HTML": msg.HTML} {
+			if text == "" {
+				t.Errorf("%s: %s is empty", tmpl.Key, part)
+			}
+			if strings.Contains(text, "<no value>") {
+				t.Errorf("%s: %s has a missing value: %q", tmpl.Key, part, text)
+			}
+		}
+	}
+}
+
+func TestMessageTemplateRender(t *testing.T) {
+	tmpl, err := DefaultMessageTemplate(TemplateReminder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := sampleMessageData
+	data.FirstName = "<script>alert(1)</script>"
+	msg, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(msg.Body, "Hi <script>alert(1)</script>, a reminder that you're serving Camera for Sunday Service on Sun, Oct 18 at 9:00 AM.") {
+		t.Errorf("SMS = %q", msg.Body)
+	}
+	if strings.Contains(msg.HTML, "<script>") || !strings.Contains(msg.HTML, "Hi &lt;script&gt;alert(1)&lt;/script&gt;,") {
+		t.Errorf("HTML does not escape the name: %q", msg.HTML)
+	}
+	if msg.Subject != "Reminder: Sunday Service on Oct 18" {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+
+	tmpl.EmailSubject = "Reminder:\n  {{.EventName}}\n"
+	if msg, _ := tmpl.Render(data); msg.Subject != "Reminder: Sunday Service" {
+		t.Errorf("subject = %q, want one line", msg.Subject)
+	}
+}
+
+func TestMessageTemplateValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*MessageTemplate)
+		ok   bool
+	}{
+		{"default", func(*MessageTemplate) {}, true},
+		{"no HTML", func(m *MessageTemplate) { m.EmailHTML = "" }, true},
+		{"unknown placeholder", func(m *MessageTemplate) { m.SMS = "Hi {{.Nope}}" }, false},
+		{"unknown placeholder in a range", func(m *MessageTemplate) { m.EmailText = "{{range .Assignments}}{{.Nope}}{{end}}" }, false},
+		{"unknown placeholder in HTML", func(m *MessageTemplate) { m.EmailHTML = "<p>{{.Nope}}</p>" }, false},
+		{"unclosed action", func(m *MessageTemplate) { m.EmailSubject = "Reminder {{.EventName" }, false},
+		{"unclosed range", func(m *MessageTemplate) { m.EmailText = "{{range .Changes}}{{.Text}}" }, false},
+	}
+	for _, tt := range tests {
+		tmpl, err := DefaultMessageTemplate(TemplateReminder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.edit(tmpl)
+		if err := tmpl.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+	}
+}
+
+func TestUpdateMessageTemplateChecksFirst(t *testing.T) {
+	// Neither reaches the database
+	if err := UpdateMessageTemplate(nil, &MessageTemplate{Key: "nope"}); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("unknown key: error = %v", err)
+	}
+	if err := UpdateMessageTemplate(nil, &MessageTemplate{Key: TemplateReminder, SMS: "Hi {{.Nope}}"}); err == nil {
+		t.Error("a template with an unknown placeholder was saved")
+	}
+}
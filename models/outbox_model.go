@@ -0,0 +1,364 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const OutboxCollection = "outbox"
+
+// Outbox message statuses.
+const (
+	OutboxPending   = "pending"
+	OutboxSending   = "sending"
+	OutboxSent      = "sent"
+	OutboxFailed    = "failed"
+	OutboxCancelled = "cancelled"
+)
+
+// Outbox channels, matching the notifier's channel names.
+const (
+	ChannelSMS      = "sms"
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+)
+
+var OutboxStatuses = []string{OutboxPending, OutboxSending, OutboxSent, OutboxFailed, OutboxCancelled}
+
+// OutboxMaxAttempts is how many times delivery is tried before a message is
+// marked failed.
+const OutboxMaxAttempts = 6
+
+// OutboxLease is how long a claimed message may stay in the sending state
+// before another worker may claim it again.
+const OutboxLease = 5 * time.Minute
+
+var ErrOutboxNotFound = errors.New("outbox message not found or not in a state that allows this")
+
+// OutboxMessage is one outgoing SMS or email. Messages with the same
+// IdempotencyKey are only queued once.
+type OutboxMessage struct {
+	ID             string     `bson:"_id" json:"id" query:"id" form:"id"`
+	IdempotencyKey string     `bson:"idempotencyKey,omitempty" json:"idempotencyKey" query:"idempotencyKey" form:"idempotencyKey"`
+	Channel        string     `bson:"channel" json:"channel" query:"channel" form:"channel"`
+	MemberID       string     `bson:"memberId,omitempty" json:"memberId" query:"memberId" form:"memberId"`
+	To             string     `bson:"to" json:"to" query:"to" form:"to"`
+	Subject        string     `bson:"subject,omitempty" json:"subject" query:"subject" form:"subject"`
+	Body           string     `bson:"body" json:"body" query:"body" form:"body"`
+	HTML           string     `bson:"html,omitempty" json:"html" query:"html" form:"html"`
+	Status         string     `bson:"status" json:"status" query:"status" form:"status"`
+	Attempts       int        `bson:"attempts" json:"attempts" query:"attempts" form:"attempts"`
+	NextAttemptAt  time.Time  `bson:"nextAttemptAt" json:"nextAttemptAt" query:"nextAttemptAt" form:"nextAttemptAt"`
+	LockedUntil    *time.Time `bson:"lockedUntil,omitempty" json:"lockedUntil" query:"lockedUntil" form:"lockedUntil"`
+	Provider       string     `bson:"provider,omitempty" json:"provider" query:"provider" form:"provider"`
+	ProviderID     string     `bson:"providerId,omitempty" json:"providerId" query:"providerId" form:"providerId"`
+	LastError      string     `bson:"lastError,omitempty" json:"lastError" query:"lastError" form:"lastError"`
+	CreatedAt      time.Time  `bson:"createdAt" json:"createdAt" query:"createdAt" form:"createdAt"`
+	UpdatedAt      time.Time  `bson:"updatedAt" json:"updatedAt" query:"updatedAt" form:"updatedAt"`
+	SentAt         *time.Time `bson:"sentAt,omitempty" json:"sentAt" query:"sentAt" form:"sentAt"`
+	// Actions are offered as buttons on channels that support them.
+	Actions []MessageAction `bson:"actions,omitempty" json:"actions" query:"-" form:"-"`
+	// Attachments are sent with emails.
+	Attachments []MessageAttachment `bson:"attachments,omitempty" json:"-" query:"-" form:"-"`
+	// BroadcastID links the message to the broadcast it was sent for.
+	BroadcastID string `bson:"broadcastId,omitempty" json:"broadcastId" query:"-" form:"-"`
+	// Delivery is what the provider later reported became of the message.
+	DeliveryStatus string     `bson:"deliveryStatus,omitempty" json:"deliveryStatus" query:"-" form:"-"`
+	DeliveryDetail string     `bson:"deliveryDetail,omitempty" json:"deliveryDetail" query:"-" form:"-"`
+	DeliveryAt     *time.Time `bson:"deliveryAt,omitempty" json:"deliveryAt" query:"-" form:"-"`
+}
+
+// MessageAttachment is a file sent with an email. Keep them small: each
+// message stores its own copy.
+type MessageAttachment struct {
+	Filename    string `bson:"filename" json:"filename"`
+	ContentType string `bson:"contentType" json:"contentType"`
+	Data        []byte `bson:"data" json:"-"`
+}
+
+// MessageAction is a button offered with a message. Data is handed back
+// when the recipient presses it.
+type MessageAction struct {
+	Label string `bson:"label" json:"label"`
+	Data  string `bson:"data" json:"data"`
+}
+
+// CanRetry reports whether an admin may send the message again.
+func (m *OutboxMessage) CanRetry() bool {
+	return m.Status == OutboxFailed || m.Status == OutboxCancelled
+}
+
+// CanCancel reports whether the message is still waiting to be sent.
+func (m *OutboxMessage) CanCancel() bool {
+	return m.Status == OutboxPending
+}
+
+// EnqueueMessage queues msg for delivery. When a message with the same
+// idempotency key is already queued, that message is returned instead and
+// nothing new is queued.
+func EnqueueMessage(db *mongo.Database, msg OutboxMessage) (*OutboxMessage, error) {
+	coll := db.Collection(OutboxCollection)
+	now := time.Now()
+	msg.ID = uuid.NewString()
+	msg.Status = OutboxPending
+	msg.Attempts = 0
+	if msg.NextAttemptAt.IsZero() {
+		msg.NextAttemptAt = now
+	}
+	msg.CreatedAt = now
+	msg.UpdatedAt = now
+
+	_, err := coll.InsertOne(context.TODO(), msg)
+	if err != nil {
+		if msg.IdempotencyKey != "" && mongo.IsDuplicateKeyError(err) {
+			var existing OutboxMessage
+			err = coll.FindOne(context.TODO(), bson.M{"idempotencyKey": msg.IdempotencyKey}).Decode(&existing)
+			if err != nil {
+				return nil, err
+			}
+			return &existing, nil
+		}
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// MemberMessage is a notification for one member. EmailBody, when set, is
+// sent by email in place of Body, for content such as links that suits
+// email better than a text, and HTML is its rich version. Telegram gets
+// Body with Actions as buttons. Key, when not blank, is combined with the
+// channel to form each message's idempotency key.
+type MemberMessage struct {
+	Subject     string
+	Body        string
+	EmailBody   string
+	HTML        string
+	Actions     []MessageAction
+	Attachments []MessageAttachment
+	Key         string
+	BroadcastID string
+}
+
+// EnqueueMemberMessage queues msg for member on each of channels their
+// communication preferences allow. A channel whose body is blank is
+// skipped, and messages queued during the member's quiet hours wait until
+// they end.
+func EnqueueMemberMessage(db *mongo.Database, member *Member, channels []string, msg MemberMessage) error {
+	var errs []error
+	quietUntil := member.QuietUntil(time.Now())
+	for _, channel := range member.Channels(channels) {
+		out := OutboxMessage{
+			Channel:       channel,
+			MemberID:      member.ID,
+			Subject:       msg.Subject,
+			Body:          msg.Body,
+			NextAttemptAt: quietUntil,
+			BroadcastID:   msg.BroadcastID,
+		}
+		switch channel {
+		case ChannelSMS:
+			out.To = member.PhoneNumber
+		case ChannelEmail:
+			out.To = member.Email
+			if msg.EmailBody != "" {
+				out.Body = msg.EmailBody
+			}
+			out.HTML = msg.HTML
+			out.Attachments = msg.Attachments
+		case ChannelTelegram:
+			out.To = member.TelegramChatID
+			if out.Body == "" {
+				out.Body = msg.EmailBody
+			}
+			out.Actions = msg.Actions
+		}
+		if out.To == "" || out.Body == "" {
+			continue
+		}
+		if msg.Key != "" {
+			out.IdempotencyKey = msg.Key + ":" + channel
+		}
+		if _, err := EnqueueMessage(db, out); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ClaimOutboxMessage marks the next message that is due as sending and
+// returns it, or returns nil when nothing is due. A message left sending by
+// a worker that died is claimed again once its lease runs out.
+func ClaimOutboxMessage(db *mongo.Database, now time.Time) (*OutboxMessage, error) {
+	coll := db.Collection(OutboxCollection)
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": OutboxPending, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.M{"status": OutboxSending, "lockedUntil": bson.M{"$lte": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{"status": OutboxSending, "lockedUntil": now.Add(OutboxLease), "updatedAt": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"nextAttemptAt": 1}).
+		SetReturnDocument(options.After)
+
+	var msg OutboxMessage
+	err := coll.FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(&msg)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// MarkOutboxSent records a successful delivery.
+func MarkOutboxSent(db *mongo.Database, id, provider, providerID string) error {
+	now := time.Now()
+	_, err := db.Collection(OutboxCollection).UpdateOne(context.TODO(),
+		bson.M{"_id": id, "status": OutboxSending},
+		bson.M{
+			"$set": bson.M{
+				"status":     OutboxSent,
+				"provider":   provider,
+				"providerId": providerID,
+				"sentAt":     now,
+				"updatedAt":  now,
+			},
+			"$unset": bson.M{"lockedUntil": "", "lastError": ""},
+		})
+	return err
+}
+
+// DeferOutboxMessage puts a claimed message back in the queue until
+// without counting the claim as an attempt.
+func DeferOutboxMessage(db *mongo.Database, id string, until time.Time) error {
+	_, err := db.Collection(OutboxCollection).UpdateOne(context.TODO(),
+		bson.M{"_id": id, "status": OutboxSending},
+		bson.M{
+			"$set":   bson.M{"status": OutboxPending, "nextAttemptAt": until, "updatedAt": time.Now()},
+			"$inc":   bson.M{"attempts": -1},
+			"$unset": bson.M{"lockedUntil": ""},
+		})
+	return err
+}
+
+// SkipOutboxMessage cancels a claimed message that must not be sent,
+// recording why.
+func SkipOutboxMessage(db *mongo.Database, id, reason string) error {
+	_, err := db.Collection(OutboxCollection).UpdateOne(context.TODO(),
+		bson.M{"_id": id, "status": OutboxSending},
+		bson.M{
+			"$set":   bson.M{"status": OutboxCancelled, "lastError": reason, "updatedAt": time.Now()},
+			"$unset": bson.M{"lockedUntil": ""},
+		})
+	return err
+}
+
+// MarkOutboxFailed records a failed delivery. The message is tried again at
+// retryAt, or marked failed for good when retryAt is nil.
+func MarkOutboxFailed(db *mongo.Database, id string, sendErr error, retryAt *time.Time) error {
+	set := bson.M{"lastError": sendErr.Error(), "updatedAt": time.Now()}
+	if retryAt != nil {
+		set["status"] = OutboxPending
+		set["nextAttemptAt"] = *retryAt
+	} else {
+		set["status"] = OutboxFailed
+	}
+	_, err := db.Collection(OutboxCollection).UpdateOne(context.TODO(),
+		bson.M{"_id": id, "status": OutboxSending},
+		bson.M{"$set": set, "$unset": bson.M{"lockedUntil": ""}})
+	return err
+}
+
+// RetryOutboxMessage puts a failed or cancelled message back in the queue
+// with a fresh set of attempts.
+func RetryOutboxMessage(db *mongo.Database, id string) error {
+	now := time.Now()
+	res, err := db.Collection(OutboxCollection).UpdateOne(context.TODO(),
+		bson.M{"_id": id, "status": bson.M{"$in": bson.A{OutboxFailed, OutboxCancelled}}},
+		bson.M{"$set": bson.M{
+			"status":        OutboxPending,
+			"attempts":      0,
+			"nextAttemptAt": now,
+			"updatedAt":     now,
+		}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrOutboxNotFound
+	}
+	return nil
+}
+
+// CancelOutboxMessage stops a pending message from being sent.
+func CancelOutboxMessage(db *mongo.Database, id string) error {
+	res, err := db.Collection(OutboxCollection).UpdateOne(context.TODO(),
+		bson.M{"_id": id, "status": OutboxPending},
+		bson.M{"$set": bson.M{"status": OutboxCancelled, "updatedAt": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrOutboxNotFound
+	}
+	return nil
+}
+
+// GetOutboxMessages returns the newest messages, optionally only those with
+// status.
+func GetOutboxMessages(db *mongo.Database, status string, limit int64) ([]OutboxMessage, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(limit)
+	cursor, err := db.Collection(OutboxCollection).Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var messages []OutboxMessage
+	for cursor.Next(context.TODO()) {
+		var msg OutboxMessage
+		if err := cursor.Decode(&msg); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, cursor.Err()
+}
+
+// CountOutboxMessages returns the number of messages in each status.
+func CountOutboxMessages(db *mongo.Database) (map[string]int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}}},
+	}
+	cursor, err := db.Collection(OutboxCollection).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	counts := map[string]int{}
+	for cursor.Next(context.TODO()) {
+		var row struct {
+			Status string `bson:"_id"`
+			Count  int    `bson:"count"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		counts[row.Status] = row.Count
+	}
+	return counts, cursor.Err()
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// NormalizePhone puts a phone number in E.164 form so numbers typed in
+// different styles can be compared. Ten digit numbers are taken to be North
+// American. It returns "" when phone has no usable digits.
+func NormalizePhone(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	d := digits.String()
+	switch {
+	case d == "":
+		return ""
+	case strings.HasPrefix(strings.TrimSpace(phone), "+"):
+		return "+" + d
+	case len(d) == 10:
+		return "+1" + d
+	case len(d) == 11 && d[0] == '1':
+		return "+" + d
+	}
+	return "+" + d
+}
+
+// MigrateMemberPhones fills in the normalized phone number for members
+// saved before it was stored. It returns the number of members updated.
+func MigrateMemberPhones(db *mongo.Database) (int, error) {
+	collection := db.Collection(MemberCollection)
+	filter := bson.M{"phoneNumber": bson.M{"$nin": bson.A{nil, ""}}, "phoneNormalized": bson.M{"$exists": false}}
+	cursor, err := collection.Find(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.TODO())
+
+	migrated := 0
+	for cursor.Next(context.TODO()) {
+		var member Member
+		if err := cursor.Decode(&member); err != nil {
+			return migrated, err
+		}
+		normalized := NormalizePhone(member.PhoneNumber)
+		if normalized == "" {
+			continue
+		}
+		_, err := collection.UpdateOne(context.TODO(), bson.M{"_id": member.ID},
+			bson.M{"$set": bson.M{"phoneNormalized": normalized}})
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, cursor.Err()
+}
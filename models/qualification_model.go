@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const QualificationCollection = "qualifications"
+
+// Proficiency levels, lowest first.
+const (
+	QualificationTrainee    = "trainee"
+	QualificationProficient = "proficient"
+	QualificationLead       = "lead"
+)
+
+var QualificationLevels = []string{QualificationTrainee, QualificationProficient, QualificationLead}
+
+// Qualification records that a member is trained for a position on a team.
+// Positions are matched by name, the same way event templates copy them
+// onto events.
+type Qualification struct {
+	ID           string    `bson:"_id" json:"_id"`
+	TeamID       string    `bson:"teamId" json:"teamId" query:"teamId" form:"teamId"`
+	MemberID     string    `bson:"memberId" json:"memberId" query:"memberId" form:"memberId"`
+	PositionName string    `bson:"positionName" json:"positionName" query:"positionName" form:"positionName"`
+	Level        string    `bson:"level" json:"level" query:"level" form:"level"`
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// QualificationMatrix indexes a team's qualifications by position name and
+// then member ID.
+type QualificationMatrix map[string]map[string]Qualification
+
+// Restricts reports whether any qualifications are recorded for the
+// position. Positions nobody has been qualified for yet stay open to the
+// whole team, so existing schedules keep working until leaders fill in
+// the matrix.
+func (m QualificationMatrix) Restricts(positionName string) bool {
+	return len(m[positionName]) > 0
+}
+
+// IsQualified reports whether the member may be offered the position.
+func (m QualificationMatrix) IsQualified(positionName, memberID string) bool {
+	if !m.Restricts(positionName) {
+		return true
+	}
+	_, ok := m[positionName][memberID]
+	return ok
+}
+
+// Level returns the member's proficiency for the position, or "".
+func (m QualificationMatrix) Level(positionName, memberID string) string {
+	return m[positionName][memberID].Level
+}
+
+func GetTeamQualifications(db *mongo.Database, teamID string) ([]Qualification, error) {
+	collection := db.Collection(QualificationCollection)
+	cursor, err := collection.Find(context.TODO(), bson.M{"teamId": teamID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var qualifications []Qualification
+	for cursor.Next(context.TODO()) {
+		var q Qualification
+		if err := cursor.Decode(&q); err != nil {
+			return nil, err
+		}
+		qualifications = append(qualifications, q)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return qualifications, nil
+}
+
+// GetQualificationMatrix loads a team's qualifications. Events without a
+// team get an empty matrix, which restricts nothing.
+func GetQualificationMatrix(db *mongo.Database, teamID string) (QualificationMatrix, error) {
+	matrix := QualificationMatrix{}
+	if teamID == "" {
+		return matrix, nil
+	}
+	qualifications, err := GetTeamQualifications(db, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range qualifications {
+		if matrix[q.PositionName] == nil {
+			matrix[q.PositionName] = map[string]Qualification{}
+		}
+		matrix[q.PositionName][q.MemberID] = q
+	}
+	return matrix, nil
+}
+
+// SetQualification records the member's level for a position. An empty
+// level removes the qualification.
+func SetQualification(db *mongo.Database, teamID, memberID, positionName, level string) error {
+	collection := db.Collection(QualificationCollection)
+	filter := bson.M{"teamId": teamID, "memberId": memberID, "positionName": positionName}
+	if level == "" {
+		_, err := collection.DeleteOne(context.TODO(), filter)
+		return err
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"level":     level,
+			"updatedAt": time.Now(),
+		},
+		"$setOnInsert": bson.M{
+			"_id":       uuid.NewString(),
+			"createdAt": time.Now(),
+		},
+	}
+	_, err := collection.UpdateOne(context.TODO(), filter, update, options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+// GetTeamPositionNames lists the positions a team staffs: those on the
+// team's event templates plus any that already have qualifications.
+func GetTeamPositionNames(db *mongo.Database, teamID string) ([]string, error) {
+	seen := map[string]bool{}
+
+	cursor, err := db.Collection(EventTemplateCollection).Find(context.TODO(), bson.M{"teamId": teamID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var tmpl EventTemplate
+		if err := cursor.Decode(&tmpl); err != nil {
+			return nil, err
+		}
+		for _, p := range tmpl.Positions {
+			seen[p.Name] = true
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	qualifications, err := GetTeamQualifications(db, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range qualifications {
+		seen[q.PositionName] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
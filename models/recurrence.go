@@ -0,0 +1,352 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported RRULE frequencies. This is the subset of RFC 5545 we need for
+// church schedules: daily, weekly (optionally every N weeks) and monthly by
+// weekday ("2nd Sunday", "last Sunday") or by day of month.
+const (
+	FrequencyDaily   = "DAILY"
+	FrequencyWeekly  = "WEEKLY"
+	FrequencyMonthly = "MONTHLY"
+)
+
+type RecurrencePreset struct {
+	Label string
+	Rule  string
+}
+
+// RecurrencePresets are offered on the event template page so leaders don't
+// have to write RRULEs by hand for the common cases.
+var RecurrencePresets = []RecurrencePreset{
+	{Label: "Every Sunday", Rule: "FREQ=WEEKLY;BYDAY=SU"},
+	{Label: "Every other Sunday", Rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=SU"},
+	{Label: "2nd Sunday of the month", Rule: "FREQ=MONTHLY;BYDAY=2SU"},
+	{Label: "Last Sunday of the month", Rule: "FREQ=MONTHLY;BYDAY=-1SU"},
+	{Label: "Every Wednesday", Rule: "FREQ=WEEKLY;BYDAY=WE"},
+}
+
+// Upper bound on generated occurrences so a bad rule can't run away.
+const maxRecurrenceOccurrences = 1000
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// RecurrenceDay is a BYDAY entry. Ordinal is only used by monthly rules:
+// 2 means the second such weekday of the month, -1 the last, 0 every one.
+type RecurrenceDay struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+type RecurrenceRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []RecurrenceDay
+	ByMonthDay []int
+	Count      int
+	Until      time.Time
+	WeekStart  time.Weekday
+}
+
+// ParseRecurrenceRule parses an RRULE string such as
+// "FREQ=MONTHLY;BYDAY=2SU" or "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=SU".
+func ParseRecurrenceRule(rule string) (*RecurrenceRule, error) {
+	rule = strings.TrimSpace(rule)
+	rule = strings.TrimPrefix(strings.ToUpper(rule), "RRULE:")
+	if rule == "" {
+		return nil, errors.New("recurrence rule is empty")
+	}
+
+	r := &RecurrenceRule{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+		switch key {
+		case "FREQ":
+			switch value {
+			case FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+				r.Freq = value
+			default:
+				return nil, fmt.Errorf("unsupported recurrence frequency %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT %q", value)
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseRRuleDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %q", value)
+			}
+			r.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				d, err := parseRecurrenceDay(day)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, d)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY %q", day)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "WKST":
+			wd, ok := rruleWeekdays[value]
+			if !ok {
+				return nil, fmt.Errorf("invalid WKST %q", value)
+			}
+			r.WeekStart = wd
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %q", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("recurrence rule is missing FREQ")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, errors.New("recurrence rule cannot have both COUNT and UNTIL")
+	}
+	if r.Freq != FrequencyMonthly {
+		for _, d := range r.ByDay {
+			if d.Ordinal != 0 {
+				return nil, errors.New("BYDAY ordinals are only supported for MONTHLY rules")
+			}
+		}
+		if len(r.ByMonthDay) > 0 {
+			return nil, errors.New("BYMONTHDAY is only supported for MONTHLY rules")
+		}
+	}
+	return r, nil
+}
+
+func parseRecurrenceDay(value string) (RecurrenceDay, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return RecurrenceDay{}, fmt.Errorf("invalid BYDAY %q", value)
+	}
+	wd, ok := rruleWeekdays[value[len(value)-2:]]
+	if !ok {
+		return RecurrenceDay{}, fmt.Errorf("invalid BYDAY %q", value)
+	}
+	day := RecurrenceDay{Weekday: wd}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return RecurrenceDay{}, fmt.Errorf("invalid BYDAY %q", value)
+		}
+		day.Ordinal = n
+	}
+	return day, nil
+}
+
+func parseRRuleDate(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return DateOnly(t), nil
+		}
+	}
+	return time.Time{}, errors.New("invalid date")
+}
+
+// String renders the rule back into RRULE form.
+func (r *RecurrenceRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, d := range r.ByDay {
+			s := weekdayCode(d.Weekday)
+			if d.Ordinal != 0 {
+				s = strconv.Itoa(d.Ordinal) + s
+			}
+			days = append(days, s)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, d := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(d))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCode(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+func weekdayCode(wd time.Weekday) string {
+	for code, day := range rruleWeekdays {
+		if day == wd {
+			return code
+		}
+	}
+	return ""
+}
+
+// Occurrences returns every date the rule produces, starting at dtstart
+// (the first date of the series) up to and including end. Dates are
+// returned as UTC midnights, the same way event dates are stored.
+func (r *RecurrenceRule) Occurrences(dtstart time.Time, end time.Time) []time.Time {
+	dtstart = DateOnly(dtstart)
+	end = DateOnly(end)
+	if !r.Until.IsZero() && r.Until.Before(end) {
+		end = r.Until
+	}
+
+	var dates []time.Time
+	emit := func(candidates []time.Time) bool {
+		for _, d := range candidates {
+			if d.Before(dtstart) {
+				continue
+			}
+			if d.After(end) || (r.Count > 0 && len(dates) >= r.Count) || len(dates) >= maxRecurrenceOccurrences {
+				return false
+			}
+			dates = append(dates, d)
+		}
+		return true
+	}
+
+	switch r.Freq {
+	case FrequencyDaily:
+		for d := dtstart; !d.After(end); d = d.AddDate(0, 0, r.Interval) {
+			if !emit([]time.Time{d}) {
+				break
+			}
+		}
+	case FrequencyWeekly:
+		offset := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		for week := dtstart.AddDate(0, 0, -offset); !week.After(end); week = week.AddDate(0, 0, 7*r.Interval) {
+			if !emit(r.weekCandidates(week, dtstart)) {
+				break
+			}
+		}
+	case FrequencyMonthly:
+		for month := time.Date(dtstart.Year(), dtstart.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(end); month = month.AddDate(0, r.Interval, 0) {
+			if !emit(r.monthCandidates(month, dtstart)) {
+				break
+			}
+		}
+	}
+	return dates
+}
+
+// OccurrencesAfter returns the dates Occurrences gives that fall after
+// `after`, for extending a series that already has events up to it. COUNT
+// still counts from dtstart, so an extended series never grows past it.
+func (r *RecurrenceRule) OccurrencesAfter(dtstart, after, end time.Time) []time.Time {
+	var dates []time.Time
+	for _, date := range r.Occurrences(dtstart, end) {
+		if after.IsZero() || date.After(after) {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+func (r *RecurrenceRule) weekCandidates(weekStart time.Time, dtstart time.Time) []time.Time {
+	days := r.ByDay
+	if len(days) == 0 {
+		days = []RecurrenceDay{{Weekday: dtstart.Weekday()}}
+	}
+	var out []time.Time
+	for _, d := range days {
+		offset := (int(d.Weekday) - int(weekStart.Weekday()) + 7) % 7
+		out = append(out, weekStart.AddDate(0, 0, offset))
+	}
+	sortDates(out)
+	return out
+}
+
+func (r *RecurrenceRule) monthCandidates(month time.Time, dtstart time.Time) []time.Time {
+	daysInMonth := month.AddDate(0, 1, -1).Day()
+	var out []time.Time
+
+	for _, md := range r.ByMonthDay {
+		day := md
+		if md < 0 {
+			day = daysInMonth + md + 1
+		}
+		if day >= 1 && day <= daysInMonth {
+			out = append(out, month.AddDate(0, 0, day-1))
+		}
+	}
+
+	for _, d := range r.ByDay {
+		var matches []time.Time
+		for day := 1; day <= daysInMonth; day++ {
+			date := month.AddDate(0, 0, day-1)
+			if date.Weekday() == d.Weekday {
+				matches = append(matches, date)
+			}
+		}
+		switch {
+		case d.Ordinal == 0:
+			out = append(out, matches...)
+		case d.Ordinal > 0 && d.Ordinal <= len(matches):
+			out = append(out, matches[d.Ordinal-1])
+		case d.Ordinal < 0 && -d.Ordinal <= len(matches):
+			out = append(out, matches[len(matches)+d.Ordinal])
+		}
+	}
+
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && dtstart.Day() <= daysInMonth {
+		out = append(out, month.AddDate(0, 0, dtstart.Day()-1))
+	}
+	sortDates(out)
+	return out
+}
+
+func sortDates(dates []time.Time) {
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+}
+
+// DateOnly truncates t to a UTC midnight on the same calendar date, which is
+// how event dates are stored.
+func DateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dates(t *testing.T, values ...string) []time.Time {
+	t.Helper()
+	var out []time.Time
+	for _, v := range values {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+func formatDates(ds []time.Time) string {
+	var out []string
+	for _, d := range ds {
+		out = append(out, d.Format("2006-01-02"))
+	}
+	return strings.Join(out, " ")
+}
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		end   string
+		want  []string
+	}{
+		{"weekly on the start's weekday", "FREQ=WEEKLY", "2026-01-04", "2026-01-25",
+			[]string{"2026-01-04", "2026-01-11", "2026-01-18", "2026-01-25"}},
+		{"every other Sunday", "FREQ=WEEKLY;INTERVAL=2;BYDAY=SU", "2026-01-04", "2026-02-15",
+			[]string{"2026-01-04", "2026-01-18", "2026-02-01", "2026-02-15"}},
+		// RFC 5545's example of WKST changing which weeks INTERVAL skips
+		{"bi-weekly with weeks from Monday", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO", "1997-08-05", "1997-12-31",
+			[]string{"1997-08-05", "1997-08-10", "1997-08-19", "1997-08-24"}},
+		{"bi-weekly with weeks from Sunday", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU", "1997-08-05", "1997-12-31",
+			[]string{"1997-08-05", "1997-08-17", "1997-08-19", "1997-08-31"}},
+		{"second Sunday", "FREQ=MONTHLY;BYDAY=2SU", "2026-01-01", "2026-03-31",
+			[]string{"2026-01-11", "2026-02-08", "2026-03-08"}},
+		{"last Sunday", "FREQ=MONTHLY;BYDAY=-1SU", "2026-01-01", "2026-04-30",
+			[]string{"2026-01-25", "2026-02-22", "2026-03-29", "2026-04-26"}},
+		{"the 31st skips short months", "FREQ=MONTHLY;BYMONTHDAY=31", "2026-01-01", "2026-07-31",
+			[]string{"2026-01-31", "2026-03-31", "2026-05-31", "2026-07-31"}},
+		{"last day of the month", "FREQ=MONTHLY;BYMONTHDAY=-1", "2026-01-15", "2026-04-30",
+			[]string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"}},
+		{"monthly from the 31st", "FREQ=MONTHLY", "2026-01-31", "2026-05-31",
+			[]string{"2026-01-31", "2026-03-31", "2026-05-31"}},
+		{"nothing before the start", "FREQ=MONTHLY;BYDAY=1SU", "2026-01-05", "2026-02-28",
+			[]string{"2026-02-01"}},
+		{"daily until", "FREQ=DAILY;INTERVAL=3;UNTIL=20260110", "2026-01-01", "2026-12-31",
+			[]string{"2026-01-01", "2026-01-04", "2026-01-07", "2026-01-10"}},
+		{"count", "FREQ=WEEKLY;BYDAY=SU,WE;COUNT=3", "2026-01-04", "2026-12-31",
+			[]string{"2026-01-04", "2026-01-07", "2026-01-11"}},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRecurrenceRule(tt.rule)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := rule.Occurrences(dates(t, tt.start)[0], dates(t, tt.end)[0])
+		if want := dates(t, tt.want...); !slices.Equal(got, want) {
+			t.Errorf("%s: got %s\nwant %s", tt.name, formatDates(got), formatDates(want))
+		}
+	}
+}
+
+func TestRecurrenceOccurrencesCapped(t *testing.T) {
+	rule, err := ParseRecurrenceRule("FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := dates(t, "2026-01-01")[0]
+	if got := rule.Occurrences(start, start.AddDate(10, 0, 0)); len(got) != maxRecurrenceOccurrences {
+		t.Errorf("got %d occurrences, want %d", len(got), maxRecurrenceOccurrences)
+	}
+}
+
+// Extending a series only adds dates after its old end, and COUNT still
+// counts from the start of the series.
+func TestRecurrenceOccurrencesAfterKeepsCount(t *testing.T) {
+	rule, err := ParseRecurrenceRule("FREQ=WEEKLY;BYDAY=SU;COUNT=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := dates(t, "2026-01-04")[0]
+	first := rule.OccurrencesAfter(start, time.Time{}, dates(t, "2026-01-25")[0])
+	if want := dates(t, "2026-01-04", "2026-01-11", "2026-01-18", "2026-01-25"); !slices.Equal(first, want) {
+		t.Fatalf("first part: got %s", formatDates(first))
+	}
+	extended := rule.OccurrencesAfter(start, first[len(first)-1], dates(t, "2026-06-30")[0])
+	if want := dates(t, "2026-02-01"); !slices.Equal(extended, want) {
+		t.Errorf("extension: got %s, want 2026-02-01", formatDates(extended))
+	}
+}
+
+func TestParseRecurrenceRule(t *testing.T) {
+	rule, err := ParseRecurrenceRule(" rrule:freq=weekly;interval=2;byday=su,-1sa;wkst=su ")
+	if err == nil {
+		t.Errorf("ordinal in a weekly rule was accepted: %v", rule)
+	}
+	rule, err = ParseRecurrenceRule(" rrule:freq=monthly;interval=2;byday=su,-1sa;wkst=su ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RecurrenceRule{
+		Freq:      FrequencyMonthly,
+		Interval:  2,
+		ByDay:     []RecurrenceDay{{Weekday: time.Sunday}, {Ordinal: -1, Weekday: time.Saturday}},
+		WeekStart: time.Sunday,
+	}
+	if rule.Freq != want.Freq || rule.Interval != want.Interval || !slices.Equal(rule.ByDay, want.ByDay) || rule.WeekStart != want.WeekStart {
+		t.Errorf("parsed %+v, want %+v", rule, want)
+	}
+	if got := rule.String(); got != "FREQ=MONTHLY;INTERVAL=2;BYDAY=SU,-1SA;WKST=SU" {
+		t.Errorf("String() = %q", got)
+	}
+
+	for _, preset := range RecurrencePresets {
+		rule, err := ParseRecurrenceRule(preset.Rule)
+		if err != nil {
+			t.Errorf("preset %q: %v", preset.Label, err)
+			continue
+		}
+		if rule.String() != preset.Rule {
+			t.Errorf("preset %q round trips as %q", preset.Rule, rule.String())
+		}
+	}
+}
+
+func TestParseRecurrenceRuleErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"RRULE:",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;COUNT=-1",
+		"FREQ=WEEKLY;COUNT=2;UNTIL=20260101",
+		"FREQ=WEEKLY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=2SU",
+		"FREQ=MONTHLY;BYDAY=6SU",
+		"FREQ=MONTHLY;BYDAY=0SU",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=WEEKLY;WKST=XX",
+		"FREQ=WEEKLY;BYSETPOS=1",
+		"FREQ",
+	} {
+		if _, err := ParseRecurrenceRule(rule); err == nil {
+			t.Errorf("ParseRecurrenceRule(%q) succeeded", rule)
+		}
+	}
+}
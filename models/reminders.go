@@ -0,0 +1,95 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type ReminderOption struct {
+	Label    string
+	Interval time.Duration
+}
+
+// ReminderOptions lists the reminder intervals offered in forms.
+var ReminderOptions = []ReminderOption{
+	{"15 minutes before", Reminder15Min},
+	{"30 minutes before", Reminder30Min},
+	{"1 hour before", Reminder1Hr},
+	{"2 hours before", Reminder2Hr},
+	{"3 hours before", Reminder3Hr},
+	{"1 day before", Reminder1Day},
+	{"2 days before", Reminder2Day},
+	{"1 week before", Reminder1Week},
+}
+
+// GetEventsAwaitingReminder returns events with reminders enabled that have
+// not had one sent and start soon enough that a reminder could be due. The
+// caller checks ReminderAt against the current time.
+func GetEventsAwaitingReminder(db *mongo.Database, now time.Time) ([]Event, error) {
+	collection := db.Collection(EventCollection)
+	today := DateOnly(now.In(location))
+	filter := bson.M{
+		"reminderEnabled": true,
+		"reminderSentAt":  bson.M{"$exists": false},
+		"date": bson.M{
+			"$gte": today.AddDate(0, 0, -1),
+			"$lte": today.Add(Reminder1Week + 48*time.Hour),
+		},
+	}
+	cursor, err := collection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var events []Event
+	for cursor.Next(context.TODO()) {
+		var event Event
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ClaimEventReminder marks the event's reminder as sent. Only one caller
+// can claim a reminder, so concurrent workers and app instances never send
+// it twice. It reports whether this caller won the claim.
+func ClaimEventReminder(db *mongo.Database, eventID string, now time.Time) (bool, error) {
+	collection := db.Collection(EventCollection)
+	filter := bson.M{
+		"_id":            eventID,
+		"reminderSentAt": bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"reminderSentAt": now,
+		},
+	}
+	res, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// ResetEventReminder clears the sent marker so the reminder goes out again,
+// for example after the event is moved.
+func ResetEventReminder(db *mongo.Database, eventID string) (*mongo.UpdateResult, error) {
+	collection := db.Collection(EventCollection)
+	filter := bson.M{"_id": eventID}
+	update := bson.M{
+		"$unset": bson.M{
+			"reminderSentAt": "",
+		},
+	}
+	res, err := collection.UpdateOne(context.TODO(), filter, update)
+	return res, err
+}
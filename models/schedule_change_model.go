@@ -0,0 +1,253 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const ScheduleChangeCollection = "schedule_changes"
+
+// Schedule change kinds.
+const (
+	ChangeAssigned = "assigned"
+	ChangeRemoved  = "removed"
+	ChangeMoved    = "moved"
+)
+
+// ScheduleChange is one change to a member's schedule waiting to be sent.
+// Changes are collected for a while and sent to the member as one message;
+// BatchID is set once a change has been claimed for sending and SentAt once
+// that message has been queued.
+type ScheduleChange struct {
+	ID           string `bson:"_id" json:"id" query:"id" form:"id"`
+	MemberID     string `bson:"memberId" json:"memberId" query:"memberId" form:"memberId"`
+	EventID      string `bson:"eventId" json:"eventId" query:"eventId" form:"eventId"`
+	EventName    string `bson:"eventName" json:"eventName" query:"eventName" form:"eventName"`
+	PositionID   string `bson:"positionId,omitempty" json:"positionId" query:"positionId" form:"positionId"`
+	PositionName string `bson:"positionName,omitempty" json:"positionName" query:"positionName" form:"positionName"`
+	Kind         string `bson:"kind" json:"kind" query:"kind" form:"kind"`
+	// PreviousWhen is the event's day and time before it moved.
+	PreviousWhen string     `bson:"previousWhen,omitempty" json:"previousWhen" query:"previousWhen" form:"previousWhen"`
+	BatchID      string     `bson:"batchId,omitempty" json:"batchId" query:"batchId" form:"batchId"`
+	SentAt       *time.Time `bson:"sentAt,omitempty" json:"sentAt" query:"sentAt" form:"sentAt"`
+	CreatedAt    time.Time  `bson:"createdAt" json:"createdAt" query:"createdAt" form:"createdAt"`
+}
+
+// eventOver reports whether the event has already started, so changes to
+// it aren't worth telling anyone about.
+func eventOver(event *Event, now time.Time) bool {
+	if !event.StartAt.IsZero() {
+		return event.StartAt.Before(now)
+	}
+	return event.Date.Before(DateOnly(now.In(location)))
+}
+
+func insertScheduleChanges(db *mongo.Database, changes []ScheduleChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	now := time.Now()
+	docs := make([]interface{}, len(changes))
+	for i := range changes {
+		changes[i].ID = uuid.NewString()
+		changes[i].CreatedAt = now
+		docs[i] = changes[i]
+	}
+	_, err := db.Collection(ScheduleChangeCollection).InsertMany(context.TODO(), docs)
+	return err
+}
+
+// recordAssignmentChange records the effect of putting memberID in a
+// position of before, the event as it was.
+func recordAssignmentChange(db *mongo.Database, before *Event, positionID, memberID string) error {
+	position := before.FindPosition(positionID)
+	if position == nil || position.MemberID == memberID || eventOver(before, time.Now()) {
+		return nil
+	}
+	change := ScheduleChange{
+		EventID:      before.ID,
+		EventName:    before.Name,
+		PositionID:   positionID,
+		PositionName: position.PositionName,
+	}
+	var changes []ScheduleChange
+	if position.MemberID != "" {
+		removed := change
+		removed.MemberID = position.MemberID
+		removed.Kind = ChangeRemoved
+		changes = append(changes, removed)
+	}
+	if memberID != "" {
+		assigned := change
+		assigned.MemberID = memberID
+		assigned.Kind = ChangeAssigned
+		changes = append(changes, assigned)
+	}
+	return insertScheduleChanges(db, changes)
+}
+
+// recordEventMoved records a change of day or time for every member
+// assigned to the event.
+func recordEventMoved(db *mongo.Database, before, after *Event) error {
+	if eventOver(before, time.Now()) && eventOver(after, time.Now()) {
+		return nil
+	}
+	seen := map[string]bool{}
+	var changes []ScheduleChange
+	for _, pa := range before.PositionAssignments {
+		if pa.MemberID == "" || seen[pa.MemberID] {
+			continue
+		}
+		seen[pa.MemberID] = true
+		changes = append(changes, ScheduleChange{
+			MemberID:     pa.MemberID,
+			EventID:      before.ID,
+			EventName:    after.Name,
+			Kind:         ChangeMoved,
+			PreviousWhen: before.When(),
+		})
+	}
+	return insertScheduleChanges(db, changes)
+}
+
+// GetMembersWithSettledChanges returns the members whose unsent changes are
+// ready to go: nothing new has been recorded for them since quietSince, or
+// their oldest change was recorded before waitedSince. Changes claimed by a
+// batch that was never sent count as unsent.
+func GetMembersWithSettledChanges(db *mongo.Database, quietSince, waitedSince time.Time) ([]string, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"sentAt": bson.M{"$exists": false}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    "$memberId",
+			"first":  bson.M{"$min": "$createdAt"},
+			"latest": bson.M{"$max": "$createdAt"},
+		}}},
+		{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"latest": bson.M{"$lte": quietSince}},
+			bson.M{"first": bson.M{"$lte": waitedSince}},
+		}}}},
+	}
+	cursor, err := db.Collection(ScheduleChangeCollection).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var memberIDs []string
+	for cursor.Next(context.TODO()) {
+		var row struct {
+			MemberID string `bson:"_id"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		memberIDs = append(memberIDs, row.MemberID)
+	}
+	return memberIDs, cursor.Err()
+}
+
+// ClaimScheduleChanges marks a member's unsent changes as one batch and
+// returns the batch ID and its changes in the order they were made. A batch
+// claimed earlier but never sent is returned again, unchanged, so retrying
+// it reuses its ID. When another worker got there first the changes are
+// empty.
+func ClaimScheduleChanges(db *mongo.Database, memberID string) (string, []ScheduleChange, error) {
+	coll := db.Collection(ScheduleChangeCollection)
+	var unsent ScheduleChange
+	err := coll.FindOne(context.TODO(), bson.M{
+		"memberId": memberID,
+		"batchId":  bson.M{"$exists": true},
+		"sentAt":   bson.M{"$exists": false},
+	}).Decode(&unsent)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", nil, err
+	}
+
+	batchID := unsent.BatchID
+	if batchID == "" {
+		batchID = uuid.NewString()
+		_, err = coll.UpdateMany(context.TODO(),
+			bson.M{"memberId": memberID, "batchId": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"batchId": batchID}})
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := coll.Find(context.TODO(), bson.M{"batchId": batchID}, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var changes []ScheduleChange
+	for cursor.Next(context.TODO()) {
+		var change ScheduleChange
+		if err := cursor.Decode(&change); err != nil {
+			return "", nil, err
+		}
+		changes = append(changes, change)
+	}
+	return batchID, changes, cursor.Err()
+}
+
+// MarkScheduleChangesSent records that the message for a batch has been
+// queued, so it isn't sent again.
+func MarkScheduleChangesSent(db *mongo.Database, batchID string, now time.Time) error {
+	_, err := db.Collection(ScheduleChangeCollection).UpdateMany(context.TODO(),
+		bson.M{"batchId": batchID},
+		bson.M{"$set": bson.M{"sentAt": now}})
+	return err
+}
+
+// NetScheduleChanges drops changes that cancel each other out within a
+// batch: a slot assigned then removed again, or removed and given back. A
+// move is dropped for events the member was added to or removed from in
+// the same batch, since that message already gives the new time.
+func NetScheduleChanges(changes []ScheduleChange) []ScheduleChange {
+	type slot struct{ eventID, positionID string }
+	first := map[slot]ScheduleChange{}
+	last := map[slot]ScheduleChange{}
+	var order []slot
+	var moves []ScheduleChange
+	movedSeen := map[string]bool{}
+
+	for _, c := range changes {
+		if c.Kind == ChangeMoved {
+			// Keep the earliest, which has the time the member last heard
+			if !movedSeen[c.EventID] {
+				movedSeen[c.EventID] = true
+				moves = append(moves, c)
+			}
+			continue
+		}
+		key := slot{c.EventID, c.PositionID}
+		if _, ok := first[key]; !ok {
+			first[key] = c
+			order = append(order, key)
+		}
+		last[key] = c
+	}
+
+	var net []ScheduleChange
+	touched := map[string]bool{}
+	for _, key := range order {
+		if first[key].Kind == last[key].Kind {
+			net = append(net, last[key])
+			touched[key.eventID] = true
+		}
+	}
+	for _, m := range moves {
+		if !touched[m.EventID] {
+			net = append(net, m)
+		}
+	}
+	return net
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNetScheduleChanges(t *testing.T) {
+	assigned := func(id, event, position string) ScheduleChange {
+		return ScheduleChange{ID: id, EventID: event, PositionID: position, Kind: ChangeAssigned}
+	}
+	removed := func(id, event, position string) ScheduleChange {
+		return ScheduleChange{ID: id, EventID: event, PositionID: position, Kind: ChangeRemoved}
+	}
+	moved := func(id, event string) ScheduleChange {
+		return ScheduleChange{ID: id, EventID: event, Kind: ChangeMoved}
+	}
+
+	tests := []struct {
+		name    string
+		changes []ScheduleChange
+		want    string
+	}{
+		{"nothing", nil, ""},
+		{"one of each", []ScheduleChange{assigned("a", "e1", "cam"), removed("r", "e2", "cam"), moved("m", "e3")}, "a r m"},
+		{"assigned then removed", []ScheduleChange{assigned("a", "e1", "cam"), removed("r", "e1", "cam")}, ""},
+		{"removed then given back", []ScheduleChange{removed("r", "e1", "cam"), assigned("a", "e1", "cam")}, ""},
+		{"assigned, removed and assigned again", []ScheduleChange{assigned("a1", "e1", "cam"), removed("r", "e1", "cam"), assigned("a2", "e1", "cam")}, "a2"},
+		{"other slots are kept", []ScheduleChange{assigned("a", "e1", "cam"), assigned("b", "e1", "lights"), removed("r", "e1", "cam")}, "b"},
+		{"earliest move is kept", []ScheduleChange{moved("m1", "e1"), moved("m2", "e1"), moved("m3", "e2")}, "m1 m3"},
+		{"move dropped for an added slot", []ScheduleChange{moved("m", "e1"), assigned("a", "e1", "cam")}, "a"},
+		{"move dropped for a removed slot", []ScheduleChange{removed("r", "e1", "cam"), moved("m", "e1")}, "r"},
+		{"move kept when the slot changes cancel out", []ScheduleChange{assigned("a", "e1", "cam"), moved("m", "e1"), removed("r", "e1", "cam")}, "m"},
+	}
+	for _, tt := range tests {
+		var ids []string
+		for _, c := range NetScheduleChanges(tt.changes) {
+			ids = append(ids, c.ID)
+		}
+		if got := strings.Join(ids, " "); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
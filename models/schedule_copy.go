@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ScheduleCopyItem is one line of a schedule copy plan: the source event and
+// the date its copy will land on. Skipped items have no matching weekday in
+// the target range and are not copied.
+type ScheduleCopyItem struct {
+	Source     Event
+	TargetDate time.Time
+	Skipped    bool
+	Reason     string
+}
+
+// GetEventsInDateRange returns the events dated between start and end,
+// inclusive, sorted by date.
+func GetEventsInDateRange(db *mongo.Database, start, end time.Time) ([]Event, error) {
+	collection := db.Collection(EventCollection)
+	filter := bson.M{"date": bson.M{"$gte": start, "$lte": end}}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "startAt", Value: 1}})
+	cursor, err := collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var events []Event
+	for cursor.Next(context.TODO()) {
+		var event Event
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// PlanScheduleCopy works out where each event in the source range lands in
+// the target range. Weekday alignment is preserved: the first Sunday of the
+// source range maps to the first Sunday of the target range, the second to
+// the second and so on. Nothing is written to the database.
+func PlanScheduleCopy(db *mongo.Database, sourceStart, sourceEnd, targetStart, targetEnd time.Time) ([]ScheduleCopyItem, error) {
+	sourceStart, sourceEnd = DateOnly(sourceStart), DateOnly(sourceEnd)
+	targetStart, targetEnd = DateOnly(targetStart), DateOnly(targetEnd)
+	if sourceEnd.Before(sourceStart) || targetEnd.Before(targetStart) {
+		return nil, errors.New("date range end is before its start")
+	}
+
+	events, err := GetEventsInDateRange(db, sourceStart, sourceEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make([]ScheduleCopyItem, 0, len(events))
+	for _, event := range events {
+		target := AlignDateByWeekday(sourceStart, targetStart, DateOnly(event.Date))
+		item := ScheduleCopyItem{Source: event, TargetDate: target}
+		if target.After(targetEnd) {
+			item.Skipped = true
+			item.Reason = "No matching " + event.Date.Weekday().String() + " in the target range"
+		}
+		plan = append(plan, item)
+	}
+	return plan, nil
+}
+
+// ApplyScheduleCopy inserts a copy of every non-skipped event in the plan.
+// When copyAssignments is set the copies keep the source's member
+// assignments, otherwise their positions are left open.
+func ApplyScheduleCopy(db *mongo.Database, plan []ScheduleCopyItem, copyAssignments bool) (int, error) {
+	var events []Event
+	for _, item := range plan {
+		if item.Skipped {
+			continue
+		}
+		events = append(events, copyEventToDate(item.Source, item.TargetDate, copyAssignments))
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	if _, err := InsertEvents(db, events); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// AlignDateByWeekday maps date, which falls in a range starting at
+// sourceStart, onto the same weekday occurrence of a range starting at
+// targetStart.
+func AlignDateByWeekday(sourceStart, targetStart, date time.Time) time.Time {
+	firstSource := nextWeekday(sourceStart, date.Weekday())
+	weeks := int(date.Sub(firstSource).Hours()/24) / 7
+	return nextWeekday(targetStart, date.Weekday()).AddDate(0, 0, weeks*7)
+}
+
+// nextWeekday returns the first date on or after from that falls on wd.
+func nextWeekday(from time.Time, wd time.Weekday) time.Time {
+	offset := (int(wd) - int(from.Weekday()) + 7) % 7
+	return from.AddDate(0, 0, offset)
+}
+
+func copyEventToDate(source Event, date time.Time, copyAssignments bool) Event {
+	event := Event{
+		Name:                source.Name,
+		Description:         source.Description,
+		Template:            source.Template,
+		StartAt:             shiftToDate(source.StartAt, source.Date, date),
+		EndAt:               shiftToDate(source.EndAt, source.Date, date),
+		Date:                date,
+		ReminderInterval:    source.ReminderInterval,
+		ReminderEnabled:     source.ReminderEnabled,
+		TeamID:              source.TeamID,
+		PositionAssignments: make([]PositionAssignment, 0, len(source.PositionAssignments)),
+	}
+	for _, pa := range source.PositionAssignments {
+		assignment := PositionAssignment{
+			PositionName: pa.PositionName,
+			Description:  pa.Description,
+		}
+		if copyAssignments {
+			assignment.MemberID = pa.MemberID
+		}
+		event.PositionAssignments = append(event.PositionAssignments, assignment)
+	}
+	return event
+}
+
+// shiftToDate moves t, which falls on or after the calendar day from, by
+// the same number of days to fall relative to the day to. Wall clock time is
+// kept in the organization time zone, so a 9:00 AM service stays at 9:00 AM
+// across a DST change.
+func shiftToDate(t, from, to time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	days := int(DateOnly(to).Sub(DateOnly(from)).Hours() / 24)
+	local := t.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day()+days, local.Hour(), local.Minute(), local.Second(), 0, location)
+}
@@ -0,0 +1,298 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Swap request statuses.
+const (
+	SwapOpen      = "open"
+	SwapAccepted  = "accepted"
+	SwapCancelled = "cancelled"
+)
+
+var (
+	ErrPositionUnassigned = errors.New("position has no assigned member")
+	ErrSwapAlreadyOpen    = errors.New("position already has an open swap request")
+	ErrSwapNotFound       = errors.New("swap request not found")
+	ErrSwapClosed         = errors.New("swap request is no longer open")
+	ErrSwapNotOffered     = errors.New("swap was not offered to this member")
+	ErrSwapNotEligible    = errors.New("member can no longer take this slot")
+)
+
+// SwapRequest records an attempt to hand a position slot to a teammate.
+// Swap requests are kept on the PositionAssignment as its swap history.
+type SwapRequest struct {
+	ID           string    `bson:"_id" json:"_id"`
+	FromMemberID string    `bson:"fromMemberId" json:"fromMemberId"`
+	RequestedBy  string    `bson:"requestedBy" json:"requestedBy"`
+	Status       string    `bson:"status" json:"status"`
+	OfferedTo    []string  `bson:"offeredTo" json:"offeredTo"`
+	AcceptedBy   string    `bson:"acceptedBy,omitempty" json:"acceptedBy"`
+	CreatedAt    time.Time `bson:"createdAt" json:"createdAt"`
+	ResolvedAt   time.Time `bson:"resolvedAt,omitempty" json:"resolvedAt"`
+}
+
+// IsOfferedTo reports whether memberID may accept the swap.
+func (s *SwapRequest) IsOfferedTo(memberID string) bool {
+	for _, id := range s.OfferedTo {
+		if id == memberID {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenSwap returns the position's open swap request, if any.
+func (pa *PositionAssignment) OpenSwap() *SwapRequest {
+	for i := range pa.SwapRequests {
+		if pa.SwapRequests[i].Status == SwapOpen {
+			return &pa.SwapRequests[i]
+		}
+	}
+	return nil
+}
+
+// FindPosition returns the event's position assignment with the given ID.
+func (e *Event) FindPosition(positionID string) *PositionAssignment {
+	for i := range e.PositionAssignments {
+		if e.PositionAssignments[i].ID == positionID {
+			return &e.PositionAssignments[i]
+		}
+	}
+	return nil
+}
+
+// EligibleSwapMembers returns the teammates who could take over a slot:
+// on the event's team, qualified for the position, not the current holder,
+// available that day and without a hard conflict.
+func EligibleSwapMembers(db *mongo.Database, event *Event, position *PositionAssignment) ([]Member, error) {
+	var members []Member
+	var err error
+	if event.TeamID == "" {
+		members, err = GetAllMembers(db)
+	} else {
+		members, err = GetTeamMembers(db, event.TeamID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	qualifications, err := GetQualificationMatrix(db, event.TeamID)
+	if err != nil {
+		return nil, err
+	}
+
+	var eligible []Member
+	for _, member := range members {
+		if member.ID == position.MemberID || !qualifications.IsQualified(position.PositionName, member.ID) {
+			continue
+		}
+		if _, unavailable := member.UnavailableOn(event.Date); unavailable {
+			continue
+		}
+		conflicts, err := CheckAssignmentConflicts(db, event.ID, position.ID, member.ID)
+		if err != nil {
+			return nil, err
+		}
+		hard := false
+		for _, c := range conflicts {
+			if c.Severity == ConflictHard {
+				hard = true
+			}
+		}
+		if !hard {
+			eligible = append(eligible, member)
+		}
+	}
+	return eligible, nil
+}
+
+// OpenSwapRequest starts a swap for a position and offers it to every
+// eligible teammate. requestedBy is a display name for the history.
+func OpenSwapRequest(db *mongo.Database, eventID, positionID, requestedBy string) (*SwapRequest, []Member, error) {
+	event, err := GetEventByID(db, eventID)
+	if err != nil {
+		return nil, nil, err
+	}
+	position := event.FindPosition(positionID)
+	if position == nil {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+	if position.MemberID == "" {
+		return nil, nil, ErrPositionUnassigned
+	}
+	if position.OpenSwap() != nil {
+		return nil, nil, ErrSwapAlreadyOpen
+	}
+
+	eligible, err := EligibleSwapMembers(db, event, position)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	swap := SwapRequest{
+		ID:           uuid.NewString(),
+		FromMemberID: position.MemberID,
+		RequestedBy:  requestedBy,
+		Status:       SwapOpen,
+		OfferedTo:    make([]string, 0, len(eligible)),
+		CreatedAt:    time.Now(),
+	}
+	for _, member := range eligible {
+		swap.OfferedTo = append(swap.OfferedTo, member.ID)
+	}
+
+	collection := db.Collection(EventCollection)
+	filter := bson.M{"_id": eventID, "positionAssignments._id": positionID}
+	update := bson.M{
+		"$push": bson.M{
+			"positionAssignments.$.swapRequests": swap,
+		},
+	}
+	if _, err := collection.UpdateOne(context.TODO(), filter, update); err != nil {
+		return nil, nil, err
+	}
+	return &swap, eligible, nil
+}
+
+// AcceptSwapRequest reassigns the slot to memberID. Only the first
+// acceptance wins; later ones get ErrSwapClosed. The member is checked
+// again as for a manual assignment, since they may have become unavailable
+// or been scheduled elsewhere since the swap was offered; if so the error
+// wraps ErrSwapNotEligible with the reason.
+func AcceptSwapRequest(db *mongo.Database, eventID, positionID, swapID, memberID string) error {
+	event, err := GetEventByID(db, eventID)
+	if err != nil {
+		return err
+	}
+	position := event.FindPosition(positionID)
+	if position == nil {
+		return ErrSwapNotFound
+	}
+	var swap *SwapRequest
+	for i := range position.SwapRequests {
+		if position.SwapRequests[i].ID == swapID {
+			swap = &position.SwapRequests[i]
+		}
+	}
+	if swap == nil {
+		return ErrSwapNotFound
+	}
+	if swap.Status != SwapOpen {
+		return ErrSwapClosed
+	}
+	if !swap.IsOfferedTo(memberID) {
+		return ErrSwapNotOffered
+	}
+	// The slot was given to someone else while the swap was open
+	if position.MemberID != swap.FromMemberID {
+		return ErrSwapClosed
+	}
+	member, err := GetMemberByID(db, memberID)
+	if err != nil {
+		return err
+	}
+	reason, err := CheckAssignment(db, event, positionID, member, false)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("%w: %s", ErrSwapNotEligible, reason)
+	}
+
+	set := bson.M{
+		"positionAssignments.$[pa].memberId":                      memberID,
+		"positionAssignments.$[pa].swapRequests.$[sr].status":     SwapAccepted,
+		"positionAssignments.$[pa].swapRequests.$[sr].acceptedBy": memberID,
+		"positionAssignments.$[pa].swapRequests.$[sr].resolvedAt": time.Now(),
+		"updatedAt": time.Now(),
+	}
+	if position.OriginalMemberID == "" {
+		set["positionAssignments.$[pa].originalMemberId"] = swap.FromMemberID
+	}
+
+	collection := db.Collection(EventCollection)
+	// Matching on the open status makes the first acceptance win even when
+	// two members accept at the same moment, and matching on the member
+	// keeps it from overwriting a reassignment made since.
+	filter := bson.M{
+		"_id": eventID,
+		"positionAssignments": bson.M{"$elemMatch": bson.M{
+			"_id":          positionID,
+			"memberId":     swap.FromMemberID,
+			"swapRequests": bson.M{"$elemMatch": bson.M{"_id": swapID, "status": SwapOpen}},
+		}},
+	}
+	opts := options.UpdateOne().SetArrayFilters([]any{
+		bson.M{"pa._id": positionID, "pa.memberId": swap.FromMemberID},
+		bson.M{"sr._id": swapID},
+	})
+	update := bson.M{
+		"$set": set,
+		"$unset": bson.M{
+			"positionAssignments.$[pa].status":      "",
+			"positionAssignments.$[pa].respondedAt": "",
+		},
+	}
+	res, err := collection.UpdateOne(context.TODO(), filter, update, opts)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return ErrSwapClosed
+	}
+	return nil
+}
+
+// CancelSwapRequest closes an open swap without changing the assignment.
+func CancelSwapRequest(db *mongo.Database, eventID, positionID, swapID string) error {
+	collection := db.Collection(EventCollection)
+	filter := bson.M{
+		"_id": eventID,
+		"positionAssignments": bson.M{"$elemMatch": bson.M{
+			"_id":          positionID,
+			"swapRequests": bson.M{"$elemMatch": bson.M{"_id": swapID, "status": SwapOpen}},
+		}},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"positionAssignments.$[pa].swapRequests.$[sr].status":     SwapCancelled,
+			"positionAssignments.$[pa].swapRequests.$[sr].resolvedAt": time.Now(),
+		},
+	}
+	opts := options.UpdateOne().SetArrayFilters([]any{
+		bson.M{"pa._id": positionID},
+		bson.M{"sr._id": swapID},
+	})
+	res, err := collection.UpdateOne(context.TODO(), filter, update, opts)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return ErrSwapClosed
+	}
+	return nil
+}
+
+// cancelOpenSwapRequests closes the open swaps on a position whose member
+// has changed, since they were offering a slot that is no longer theirs.
+func cancelOpenSwapRequests(db *mongo.Database, eventID string, position *PositionAssignment) error {
+	var errs []error
+	for _, swap := range position.SwapRequests {
+		if swap.Status != SwapOpen {
+			continue
+		}
+		if err := CancelSwapRequest(db, eventID, position.ID, swap.ID); err != nil && !errors.Is(err, ErrSwapClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -16,6 +16,7 @@ type Team struct {
 	Name        string    `json:"name" query:"name" form:"name"`
 	Description string    `json:"description" query:"description" form:"description"`
 	Members     []string  `json:"members" query:"members" form:"members"`
+	LeaderID    string    `json:"leaderId" bson:"leaderId,omitempty" query:"leaderId" form:"leaderId"`
 	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
 }
@@ -24,11 +25,22 @@ type TeamView struct {
 	ID          string    `bson:"_id,omitempty" json:"_id" query:"_id" form:"_id"`
 	Name        string    `json:"name" query:"name" form:"name"`
 	Description string    `json:"description" query:"description" form:"description"`
+	LeaderID    string    `json:"leaderId" bson:"leaderId,omitempty" query:"leaderId" form:"leaderId"`
 	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
 	Members     []Member  `json:"members" query:"members" form:"members"`
 }
 
+// Leader returns the team's leader from its members, if one is set.
+func (t *TeamView) Leader() *Member {
+	for i := range t.Members {
+		if t.Members[i].ID == t.LeaderID {
+			return &t.Members[i]
+		}
+	}
+	return nil
+}
+
 func GetAllTeams(db *mongo.Database) ([]TeamView, error) {
 	collection := db.Collection(TeamCollection)
 
@@ -97,6 +109,7 @@ func UpdateTeam(db *mongo.Database, id string, team *Team) (*mongo.UpdateResult,
 		"$set": bson.M{
 			"name":        team.Name,
 			"description": team.Description,
+			"leaderId":    team.LeaderID,
 			"updatedAt":   time.Now(),
 		},
 	}
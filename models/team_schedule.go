@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// TeamScheduleRow is one position across a team's upcoming events. Slots
+// line up with TeamScheduleGrid.Events; a nil slot means that event has no
+// such position.
+type TeamScheduleRow struct {
+	Label string
+	Slots []*PositionAssignment
+}
+
+// TeamScheduleGrid lays a team's events out with dates as columns and
+// positions as rows.
+type TeamScheduleGrid struct {
+	Events []Event
+	Rows   []TeamScheduleRow
+}
+
+// GetUpcomingTeamEvents returns the team's events dated on or after from,
+// in date and start time order.
+func GetUpcomingTeamEvents(db *mongo.Database, teamID string, from time.Time) ([]Event, error) {
+	collection := db.Collection(EventCollection)
+	filter := bson.M{"teamId": teamID, "date": bson.M{"$gte": from}}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "startAt", Value: 1}})
+	cursor, err := collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var events []Event
+	for cursor.Next(context.TODO()) {
+		var event Event
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// NewTeamScheduleGrid builds the grid for events. Rows keep the order
+// positions first appear in; an event with two slots of the same name,
+// such as two vocalists, gets a row for each ("Vocals", "Vocals 2").
+func NewTeamScheduleGrid(events []Event) TeamScheduleGrid {
+	grid := TeamScheduleGrid{Events: events}
+	rowIndex := map[string]int{}
+	for col := range events {
+		seen := map[string]int{}
+		for i := range events[col].PositionAssignments {
+			pa := &events[col].PositionAssignments[i]
+			seen[pa.PositionName]++
+			label := pa.PositionName
+			if n := seen[pa.PositionName]; n > 1 {
+				label += " " + strconv.Itoa(n)
+			}
+			idx, ok := rowIndex[label]
+			if !ok {
+				idx = len(grid.Rows)
+				rowIndex[label] = idx
+				grid.Rows = append(grid.Rows, TeamScheduleRow{Label: label, Slots: make([]*PositionAssignment, len(events))})
+			}
+			grid.Rows[idx].Slots[col] = pa
+		}
+	}
+	return grid
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// TelegramLinkLifetime is how long a member's Telegram link code works.
+const TelegramLinkLifetime = 7 * 24 * time.Hour
+
+// Prefixes of the data carried by assignment reply buttons. The rest is the
+// position ID, keeping the data inside Telegram's 64 byte limit.
+const (
+	ActionConfirmPrefix = "confirm:"
+	ActionDeclinePrefix = "decline:"
+)
+
+var ErrTelegramLinkInvalid = errors.New("telegram link code is invalid or has expired")
+
+// AssignmentActions offers confirm and decline buttons for each of
+// positions.
+func AssignmentActions(positions []PositionAssignment) []MessageAction {
+	var actions []MessageAction
+	for _, pa := range positions {
+		confirm, decline := "Confirm", "Can't make it"
+		if len(positions) > 1 {
+			confirm += " " + pa.PositionName
+			decline += ": " + pa.PositionName
+		}
+		actions = append(actions,
+			MessageAction{Label: confirm, Data: ActionConfirmPrefix + pa.ID},
+			MessageAction{Label: decline, Data: ActionDeclinePrefix + pa.ID})
+	}
+	return actions
+}
+
+// ParseAssignmentAction reads the data of an assignment reply button.
+func ParseAssignmentAction(data string) (status, positionID string, ok bool) {
+	if id, found := strings.CutPrefix(data, ActionConfirmPrefix); found && id != "" {
+		return AssignmentConfirmed, id, true
+	}
+	if id, found := strings.CutPrefix(data, ActionDeclinePrefix); found && id != "" {
+		return AssignmentDeclined, id, true
+	}
+	return "", "", false
+}
+
+// CreateTelegramLinkCode gives a member a fresh one-time code for linking
+// their Telegram account, replacing any earlier one.
+func CreateTelegramLinkCode(db *mongo.Database, memberID string) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(raw)
+	res, err := db.Collection(MemberCollection).UpdateOne(context.TODO(), bson.M{"_id": memberID},
+		bson.M{"$set": bson.M{"telegramLinkCode": code, "telegramLinkExpiresAt": time.Now().Add(TelegramLinkLifetime)}})
+	if err != nil {
+		return "", err
+	}
+	if res.MatchedCount == 0 {
+		return "", mongo.ErrNoDocuments
+	}
+	return code, nil
+}
+
+// LinkTelegramChat connects the member holding code to a Telegram chat and
+// records their consent to messages there. The code can't be used again.
+// A chat belongs to one member at a time.
+func LinkTelegramChat(db *mongo.Database, code, chatID, username string, now time.Time) (*Member, error) {
+	collection := db.Collection(MemberCollection)
+	if code == "" {
+		return nil, ErrTelegramLinkInvalid
+	}
+	filter := bson.M{"telegramLinkCode": code, "telegramLinkExpiresAt": bson.M{"$gt": now}}
+	update := bson.M{
+		"$set": bson.M{
+			"telegramChatId":   chatID,
+			"telegramUsername": username,
+			"telegramOptInAt":  now,
+		},
+		"$unset": bson.M{"telegramLinkCode": "", "telegramLinkExpiresAt": ""},
+		"$push": bson.M{"consentHistory": ConsentEvent{
+			At: now, Action: ConsentOptIn, Channel: ChannelTelegram, Source: ConsentSourceTelegram, Note: "Linked Telegram account",
+		}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var member Member
+	err := collection.FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(&member)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrTelegramLinkInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Unlink anyone else the chat was linked to before
+	_, err = collection.UpdateMany(context.TODO(),
+		bson.M{"telegramChatId": chatID, "_id": bson.M{"$ne": member.ID}},
+		bson.M{
+			"$unset": bson.M{"telegramChatId": "", "telegramUsername": "", "telegramOptInAt": ""},
+			"$push": bson.M{"consentHistory": ConsentEvent{
+				At: now, Action: ConsentOptOut, Channel: ChannelTelegram, Source: ConsentSourceTelegram, Note: "Chat linked to another member",
+			}},
+		})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// UnlinkTelegram disconnects a member's Telegram chat.
+func UnlinkTelegram(db *mongo.Database, memberID, source, note string) error {
+	_, err := db.Collection(MemberCollection).UpdateOne(context.TODO(),
+		bson.M{"_id": memberID, "telegramChatId": bson.M{"$exists": true}},
+		bson.M{
+			"$unset": bson.M{"telegramChatId": "", "telegramUsername": "", "telegramOptInAt": ""},
+			"$push": bson.M{"consentHistory": ConsentEvent{
+				At: time.Now(), Action: ConsentOptOut, Channel: ChannelTelegram, Source: source, Note: note,
+			}},
+		})
+	return err
+}
+
+// GetMemberByTelegramChat finds the member linked to a Telegram chat.
+func GetMemberByTelegramChat(db *mongo.Database, chatID string) (*Member, error) {
+	var member Member
+	err := db.Collection(MemberCollection).FindOne(context.TODO(), bson.M{"telegramChatId": chatID}).Decode(&member)
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetEventByPositionID finds the event a position belongs to.
+func GetEventByPositionID(db *mongo.Database, positionID string) (*Event, error) {
+	var event Event
+	err := db.Collection(EventCollection).FindOne(context.TODO(), bson.M{"positionAssignments._id": positionID}).Decode(&event)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// GetUpcomingAssignments returns the member's next events from today on,
+// soonest first.
+func GetUpcomingAssignments(db *mongo.Database, memberID string, now time.Time, limit int64) ([]Event, error) {
+	filter := bson.M{
+		"positionAssignments.memberId": memberID,
+		"date":                         bson.M{"$gte": DateOnly(now.In(location))},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "startAt", Value: 1}}).SetLimit(limit)
+	cursor, err := db.Collection(EventCollection).Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var events []Event
+	for cursor.Next(context.TODO()) {
+		var event Event
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, cursor.Err()
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// location is the organization's time zone. Event dates are calendar days
+// stored as UTC midnight; start and end instants are real moments in time,
+// and wall clock times entered in forms and templates are read in this zone.
+var location = time.Local
+
+// SetTimeZone sets the organization time zone by IANA name, such as
+// "America/New_York".
+func SetTimeZone(name string) error {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	location = loc
+	return nil
+}
+
+// Location returns the organization time zone.
+func Location() *time.Location {
+	return location
+}
+
+// AtClock returns the instant a wall clock time ("15:04") occurs on the
+// given calendar day in the organization time zone. The result is correct
+// across DST changes. ok is false if clock can't be parsed.
+func AtClock(date time.Time, clock string) (time.Time, bool) {
+	c, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), c.Hour(), c.Minute(), 0, 0, location), true
+}
+
+// EventInstants works out an event's start and end from its calendar day and
+// wall clock times. An end at or before the start is taken to be the next
+// day; a missing end leaves EndAt zero.
+func EventInstants(date time.Time, startClock, endClock string) (startAt, endAt time.Time) {
+	startAt, ok := AtClock(date, startClock)
+	if !ok {
+		return time.Time{}, time.Time{}
+	}
+	if end, ok := AtClock(date, endClock); ok {
+		if !end.After(startAt) {
+			end, _ = AtClock(date.AddDate(0, 0, 1), endClock)
+		}
+		endAt = end
+	}
+	return startAt, endAt
+}
+
+// ClockString formats an instant as a wall clock time in the organization
+// time zone, for time inputs. The zero time gives "".
+func ClockString(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(location).Format("15:04")
+}
+
+// FormatTimeRange formats start and end for display, e.g. "11:00 AM - 1:00 PM".
+func FormatTimeRange(start, end time.Time) string {
+	if start.IsZero() {
+		return "All day"
+	}
+	s := start.In(location).Format("3:04 PM")
+	if end.IsZero() {
+		return s
+	}
+	return s + " - " + end.In(location).Format("3:04 PM")
+}
+
+// legacyClockLayouts are the ways clock times were typed before events
+// stored instants.
+var legacyClockLayouts = []string{"15:04", "15:04:05", "3:04PM", "3:04 PM", "3PM", "3 PM", "3.04PM", "3.04 PM"}
+
+// parseLegacyClock reads a clock time saved before events stored instants,
+// such as "09:00", "9:00 AM" or "11am", as a "15:04" clock. ok is false if
+// it can't be read.
+func parseLegacyClock(clock string) (string, bool) {
+	clock = strings.ToUpper(strings.TrimSpace(clock))
+	clock = strings.NewReplacer("A.M.", "AM", "P.M.", "PM").Replace(clock)
+	for _, layout := range legacyClockLayouts {
+		if t, err := time.Parse(layout, clock); err == nil {
+			return t.Format("15:04"), true
+		}
+	}
+	return "", false
+}
+
+// MigrateEventTimes converts events saved with "startTime" and "endTime"
+// clock strings to startAt and endAt instants, reading the clock times in
+// the organization time zone. It only touches documents that still have the
+// old fields, so it is safe to run on every start. An event whose times
+// can't be read keeps them and is logged once, then marked with
+// timesMigrated: false so later starts pass over it; saving the event from
+// the edit page clears the old fields. It returns the number of events
+// converted.
+func MigrateEventTimes(db *mongo.Database) (int, error) {
+	collection := db.Collection(EventCollection)
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"startTime": bson.M{"$exists": true}},
+			bson.M{"endTime": bson.M{"$exists": true}},
+		},
+		"timesMigrated": bson.M{"$ne": false},
+	}
+	cursor, err := collection.Find(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.TODO())
+
+	type legacyEvent struct {
+		ID        string    `bson:"_id"`
+		Date      time.Time `bson:"date"`
+		StartTime string    `bson:"startTime"`
+		EndTime   string    `bson:"endTime"`
+	}
+
+	migrated := 0
+	for cursor.Next(context.TODO()) {
+		var legacy legacyEvent
+		if err := cursor.Decode(&legacy); err != nil {
+			return migrated, err
+		}
+		startClock, startOK := parseLegacyClock(legacy.StartTime)
+		endClock, endOK := parseLegacyClock(legacy.EndTime)
+		if (!startOK && strings.TrimSpace(legacy.StartTime) != "") || (!endOK && strings.TrimSpace(legacy.EndTime) != "") {
+			log.Printf("Can't read the times of event %s (%q to %q); leaving them to fix by hand", legacy.ID, legacy.StartTime, legacy.EndTime)
+			skipped := bson.M{"$set": bson.M{"timesMigrated": false}}
+			if _, err := collection.UpdateOne(context.TODO(), bson.M{"_id": legacy.ID}, skipped); err != nil {
+				return migrated, err
+			}
+			continue
+		}
+		startAt, endAt := EventInstants(legacy.Date, startClock, endClock)
+		update := bson.M{
+			"$set": bson.M{
+				"startAt": startAt,
+				"endAt":   endAt,
+			},
+			"$unset": bson.M{
+				"startTime": "",
+				"endTime":   "",
+			},
+		}
+		if _, err := collection.UpdateOne(context.TODO(), bson.M{"_id": legacy.ID}, update); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, cursor.Err()
+}
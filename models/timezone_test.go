@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLegacyClock(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"09:00", "09:00", true},
+		{"18:30", "18:30", true},
+		{"18:30:00", "18:30", true},
+		{"9:00 AM", "09:00", true},
+		{"9:00am", "09:00", true},
+		{"7:15 p.m.", "19:15", true},
+		{"11am", "11:00", true},
+		{"12 PM", "12:00", true},
+		{"12am", "00:00", true},
+		{" 3 pm ", "15:00", true},
+		{"noon", "", false},
+		{"25:00", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseLegacyClock(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseLegacyClock(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLinkExpiryInOrganizationTimeZone(t *testing.T) {
+	defer func(loc *time.Location) { location = loc }(location)
+	if err := SetTimeZone("America/Los_Angeles"); err != nil {
+		t.Skip(err)
+	}
+
+	event := Event{Date: time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)}
+	// Midnight at the end of Sunday in Los Angeles, after the switch to
+	// daylight saving time
+	want := time.Date(2026, 3, 9, 7, 0, 0, 0, time.UTC)
+	if got := event.LinkExpiry(); !got.Equal(want) {
+		t.Errorf("LinkExpiry() = %v, want %v", got.UTC(), want)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "github.com/bcrowe306/nltst_scheduler.git/services"
+
+// newNotifier assembles the notifier from the providers named in config,
+// keeping their configured failover order. Telegram is used whenever its
+// bot is configured.
+func newNotifier(config *Config, twilio *services.TwilioService, clicksend *services.ClickSendService, textInChurch *services.TextInChurchService, sendgrid *services.SendGridService, smtp *services.SMTPService, telegram *services.TelegramService) *services.Notifier {
+	sms := map[string]services.Provider{
+		"twilio":       twilio,
+		"clicksend":    clicksend,
+		"textinchurch": textInChurch,
+		"fake":         services.NewFakeProvider(services.ChannelSMS, config.FakeNotifyFile),
+	}
+	email := map[string]services.Provider{
+		"sendgrid": sendgrid,
+		"smtp":     smtp,
+		"fake":     services.NewFakeProvider(services.ChannelEmail, config.FakeNotifyFile),
+	}
+
+	var providers []services.Provider
+	for _, name := range config.SMSProviders {
+		providers = append(providers, sms[name])
+	}
+	for _, name := range config.EmailProviders {
+		providers = append(providers, email[name])
+	}
+	providers = append(providers, telegram)
+	return services.NewNotifier(providers...)
+}
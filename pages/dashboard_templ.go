@@ -43,12 +43,17 @@ func DashboardPage(data fiber.Map) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
+		conflicts := data["Conflicts"].([]models.Conflict)
+		templ_7745c5c3_Err = components.ConflictList(conflicts).Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
 		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"grid lg:grid-cols-3 gap-4 md:grid-cols-2 sm:grid-cols-1\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		for _, event := range data["Events"].([]models.EventWithMemberDetails) {
-			templ_7745c5c3_Err = components.EventCard(event).Render(ctx, templ_7745c5c3_Buffer)
+			templ_7745c5c3_Err = components.EventCard(event, models.ConflictsForEvent(conflicts, event.ID)).Render(ctx, templ_7745c5c3_Buffer)
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
@@ -22,8 +22,16 @@ var RoutePathMap = map[string]string{
 	"settings":        "Settings",
 	"users":           "Users",
 	"schedule":        "Schedule",
+	"series":          "Series",
+	"copy":            "Copy",
+	"autofill":        "Auto-fill",
+	"availability":    "Availability",
+	"conflicts":       "Conflicts",
 	"new":             "New",
 	"edit":            "Edit",
+	"outbox":          "Outbox",
+	"messages":        "Messages",
+	"broadcasts":      "Broadcasts",
 }
 
 func BreadcrumbMiddleware(c fiber.Ctx) error {
@@ -168,9 +176,17 @@ func CreateAllRoutes(app *fiber.App) {
 	CreateMembersRoutes(app, "/members")
 	CreateTeamsRoutes(app, "/teams")
 	CreateEventTemplatesRoutes(app, "/event_templates")
+	CreateSeriesRoutes(app, "/schedule/series")
 	CreateScheduleRoutes(app, "/schedule")
 	CreateIntegrationsRoutes(app, "/integrations")
 	CreateUsersRoutes(app, "/users")
 	CreateSettingsRoutes(app, "/settings")
 	CreateAuthRoutes(app, "/auth")
+	CreateSwapRoutes(app, "/swaps")
+	CreateOutboxRoutes(app, "/outbox")
+	CreateSMSRoutes(app, "/sms")
+	CreateEmailRoutes(app, "/email")
+	CreateAssignmentRoutes(app, "/assignments")
+	CreateTelegramRoutes(app, "/telegram")
+	CreateBroadcastRoutes(app, "/broadcasts")
 }
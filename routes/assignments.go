@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/pages"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+)
+
+func CreateAssignmentRoutes(app *fiber.App, BaseRoute string) {
+	// loadAssignmentLink verifies the token and loads what it points at. The
+	// returned message is shown to the member when the link can't be used.
+	loadAssignmentLink := func(c fiber.Ctx) (fiber.Map, *services.LinkClaims, *models.Event, *models.PositionAssignment, string) {
+		data := fiber.Map{"Title": "Your assignment", "Token": c.Params("token")}
+
+		signer, err := GetLinkSignerFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return data, nil, nil, nil, "Something went wrong. Please contact your team leader."
+		}
+		claims, err := signer.Verify(c.Params("token"))
+		if errors.Is(err, services.ErrExpiredLink) {
+			return data, nil, nil, nil, "This link has expired."
+		}
+		if err != nil || (claims.Action != services.LinkAssignmentConfirm && claims.Action != services.LinkAssignmentDecline) {
+			return data, nil, nil, nil, "This link is not valid."
+		}
+		data["Action"] = claims.Action
+
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return data, nil, nil, nil, "Something went wrong. Please contact your team leader."
+		}
+		event, err := models.GetEventByID(db, claims.EventID)
+		if err != nil {
+			return data, nil, nil, nil, "This event no longer exists."
+		}
+		position := event.FindPosition(claims.PositionID)
+		if position == nil {
+			return data, nil, nil, nil, "This position is no longer on the schedule."
+		}
+		data["Event"] = event
+		data["Position"] = position
+		if position.MemberID != claims.MemberID {
+			return data, nil, nil, nil, "You are no longer scheduled for this slot."
+		}
+		member, err := models.GetMemberByID(db, claims.MemberID)
+		if err != nil {
+			return data, nil, nil, nil, "This link is not valid."
+		}
+		data["Member"] = member
+
+		switch {
+		case claims.Action == services.LinkAssignmentConfirm && position.Status == models.AssignmentConfirmed:
+			return data, nil, nil, nil, "You've already confirmed. See you there!"
+		case claims.Action == services.LinkAssignmentDecline && position.Status == models.AssignmentDeclined:
+			return data, nil, nil, nil, "You've already let us know you can't make it."
+		}
+		return data, claims, event, position, ""
+	}
+
+	renderAssignmentPage := func(c fiber.Ctx, data fiber.Map) error {
+		err := RenderFullPage(c, pages.AssignmentResponsePage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	}
+
+	// Assignment link landing page. Members follow these links from email
+	// without logging in; the signed token is their authorization. The
+	// reply is only recorded on POST so link scanners can't trigger it.
+	app.Get(BaseRoute+"/:token", func(c fiber.Ctx) error {
+		data, _, _, _, message := loadAssignmentLink(c)
+		data["Message"] = message
+		return renderAssignmentPage(c, data)
+	})
+
+	// Record the reply
+	app.Post(BaseRoute+"/:token", func(c fiber.Ctx) error {
+		data, claims, event, position, message := loadAssignmentLink(c)
+		if claims == nil {
+			data["Message"] = message
+			return renderAssignmentPage(c, data)
+		}
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		member := data["Member"].(*models.Member)
+
+		status := models.AssignmentConfirmed
+		if claims.Action == services.LinkAssignmentDecline {
+			status = models.AssignmentDeclined
+		}
+		err = models.RespondToAssignment(db, event.ID, position.ID, member.ID, status, time.Now())
+		if errors.Is(err, models.ErrAssignmentChanged) {
+			data["Message"] = "You are no longer scheduled for this slot."
+			return renderAssignmentPage(c, data)
+		}
+		if err != nil {
+			log.Print(err)
+			data["Message"] = "We couldn't record your reply."
+			return renderAssignmentPage(c, data)
+		}
+
+		if status == models.AssignmentConfirmed {
+			data["Done"] = "Thanks! You're confirmed."
+		} else {
+			alertLeaderOfDecline(c, db, event, member, []string{position.PositionName}, "declined:"+position.ID+":"+member.ID+":"+time.Now().Format(time.RFC3339))
+			data["Done"] = "Thanks for letting us know. We've told your team leader."
+		}
+		return renderAssignmentPage(c, data)
+	})
+}
@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// newAssignmentTestApp serves the assignment links, signed with the
+// returned signer. db may be nil for tests that never reach the database.
+func newAssignmentTestApp(db *mongo.Database) (*fiber.App, *services.LinkSigner) {
+	signer := services.NewLinkSigner("link-secret", "")
+	app := fiber.New()
+	app.State().Set("linkSigner", signer)
+	if db != nil {
+		app.State().Set("db", db)
+	}
+	CreateAssignmentRoutes(app, "/assignments")
+	return app, signer
+}
+
+// followLink sends a request for the token and returns the page.
+func followLink(t *testing.T, app *fiber.App, method, token string) string {
+	t.Helper()
+	res, err := app.Test(httptest.NewRequest(method, "/assignments/"+token, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", method, res.StatusCode, body)
+	}
+	return string(body)
+}
+
+func TestAssignmentLinkRejectsBadTokens(t *testing.T) {
+	app, signer := newAssignmentTestApp(nil)
+	claims := services.LinkClaims{Action: services.LinkAssignmentConfirm, EventID: "service", PositionID: "cam", MemberID: "m1", ExpiresAt: time.Now().Add(time.Hour)}
+	token := signer.Sign(claims)
+	body, sig, _ := strings.Cut(token, ".")
+	// The signature is random looking, so its first character may be anything
+	otherSig := "A" + sig[1:]
+	if sig[0] == 'A' {
+		otherSig = "B" + sig[1:]
+	}
+
+	expired := claims
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	swap := claims
+	swap.Action = LinkSwapAccept
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"tampered body", "f" + body[1:] + "." + sig, "This link is not valid."},
+		{"tampered signature", body + "." + otherSig, "This link is not valid."},
+		{"expired", signer.Sign(expired), "This link has expired."},
+		{"wrong action", signer.Sign(swap), "This link is not valid."},
+	}
+	for _, tt := range tests {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			if page := followLink(t, app, method, tt.token); !strings.Contains(page, tt.want) {
+				t.Errorf("%s %s: page does not say %q:\n%s", tt.name, method, tt.want, page)
+			}
+		}
+	}
+}
+
+func TestAssignmentLinkReplies(t *testing.T) {
+	db := testDatabase(t)
+	app, signer := newAssignmentTestApp(db)
+
+	jo := models.Member{FirstName: "Jo", LastName: "Smith"}
+	if _, err := models.InsertMember(db, &jo); err != nil {
+		t.Fatal(err)
+	}
+	day := models.Today().AddDate(0, 0, 3)
+	event := models.Event{ID: "service", Name: "Sunday Service", Date: day,
+		PositionAssignments: []models.PositionAssignment{
+			{ID: "cam", PositionName: "Camera", MemberID: jo.ID},
+			{ID: "lights", PositionName: "Lights", MemberID: "someone-else"},
+		}}
+	if _, err := db.Collection(models.EventCollection).InsertOne(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	confirm, _ := signer.AssignmentURLs("service", "cam", jo.ID, event.LinkExpiry())
+	token := confirm[strings.LastIndex(confirm, "/")+1:]
+
+	// Opening the link only shows the page, so link scanners can't reply
+	followLink(t, app, http.MethodGet, token)
+	assertPositionStatus(t, db, "service", "cam", "")
+
+	if page := followLink(t, app, http.MethodPost, token); !strings.Contains(page, "You&#39;re confirmed.") {
+		t.Errorf("confirm: page does not thank the member:\n%s", page)
+	}
+	assertPositionStatus(t, db, "service", "cam", models.AssignmentConfirmed)
+
+	// Jo was moved off Lights before following its link
+	confirm, _ = signer.AssignmentURLs("service", "lights", jo.ID, event.LinkExpiry())
+	if page := followLink(t, app, http.MethodPost, confirm[strings.LastIndex(confirm, "/")+1:]); !strings.Contains(page, "You are no longer scheduled for this slot.") {
+		t.Errorf("reassigned: page does not say so:\n%s", page)
+	}
+	assertPositionStatus(t, db, "service", "lights", "")
+}
@@ -0,0 +1,230 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/pages"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// broadcastPageSize limits how many broadcasts the history lists.
+const broadcastPageSize = 100
+
+// broadcastForm reads the audience and channels chosen on the compose
+// form. Channels without a provider are dropped.
+func broadcastForm(c fiber.Ctx) (models.BroadcastAudience, []string) {
+	audience := models.BroadcastAudience{
+		Kind:    c.FormValue("audience"),
+		TeamID:  c.FormValue("teamId"),
+		EventID: c.FormValue("eventId"),
+	}
+	if date, err := time.Parse("2006-01-02", c.FormValue("date")); err == nil {
+		audience.Date = date
+	}
+	args := c.Request().PostArgs()
+	for _, id := range args.PeekMulti("memberIds") {
+		audience.MemberIDs = append(audience.MemberIDs, string(id))
+	}
+
+	var channels []string
+	available := broadcastChannels(c)
+	for _, channel := range args.PeekMulti("channels") {
+		if slices.Contains(available, string(channel)) {
+			channels = append(channels, string(channel))
+		}
+	}
+	return audience, channels
+}
+
+// broadcastChannels lists the channels broadcasts can be sent on.
+func broadcastChannels(c fiber.Ctx) []string {
+	notifier, ok := fiber.GetState[*services.Notifier](c.App().State(), "notifier")
+	if !ok {
+		return nil
+	}
+	return notifier.ChannelNames()
+}
+
+// planBroadcast resolves the audience on the form into the recipients the
+// broadcast would reach.
+func planBroadcast(c fiber.Ctx, db *mongo.Database) (models.BroadcastAudience, []string, []models.Member, error) {
+	audience, channels := broadcastForm(c)
+	members, err := models.ResolveBroadcastAudience(db, &audience)
+	return audience, channels, members, err
+}
+
+func CreateBroadcastRoutes(app *fiber.App, BaseRoute string) {
+	renderBroadcast := func(c fiber.Ctx, id string) error {
+		data := GetDefaultTemplateData(c, "Broadcast", BaseRoute)
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		broadcast, err := models.GetBroadcastByID(db, id)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(fiber.StatusNotFound).SendString("Broadcast not found")
+		}
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching broadcast")
+		}
+		messages, err := models.GetBroadcastMessages(db, broadcast.ID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching broadcast messages")
+		}
+
+		data["Broadcast"] = broadcast
+		data["Messages"] = messages
+		if err := RenderHTMXPage(c, pages.BroadcastPage(data)); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	}
+
+	// Broadcast history
+	app.Get(BaseRoute, Protected, func(c fiber.Ctx) error {
+		data := GetDefaultTemplateData(c, "Broadcasts", BaseRoute)
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		broadcasts, err := models.GetBroadcasts(db, broadcastPageSize)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching broadcasts")
+		}
+		data["Broadcasts"] = broadcasts
+
+		if err := RenderHTMXPage(c, pages.BroadcastsPage(data)); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
+	// Compose a broadcast
+	app.Get(BaseRoute+"/new", Protected, func(c fiber.Ctx) error {
+		data := GetDefaultTemplateData(c, "New Broadcast", BaseRoute)
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		teams, err := models.GetAllTeams(db)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving teams")
+		}
+		today := models.Today()
+		events, err := models.GetEventsInDateRange(db, today, today.AddDate(0, 0, 60))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving events")
+		}
+		members, err := models.GetAllMembers(db)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving members")
+		}
+
+		data["Teams"] = teams
+		data["Events"] = events
+		data["Members"] = members
+		data["Channels"] = broadcastChannels(c)
+		data["Today"] = today.Format("2006-01-02")
+
+		if err := RenderHTMXPage(c, pages.BroadcastComposePage(data)); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
+	// Recipients the form currently picks, with the channels each would get
+	app.Post(BaseRoute+"/recipients", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		// A team or event that's gone, or one not chosen yet, matches nobody
+		var recipients []models.BroadcastRecipient
+		_, channels, members, err := planBroadcast(c, db)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) && !errors.Is(err, models.ErrAudienceIncomplete) {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error finding recipients")
+		}
+		for i := range members {
+			recipients = append(recipients, models.PlanBroadcastRecipient(&members[i], channels))
+		}
+		return RenderFullPage(c, pages.BroadcastRecipients(recipients, len(channels) > 0))
+	})
+
+	// Send a broadcast
+	app.Post(BaseRoute, Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		audience, channels, members, err := planBroadcast(c, db)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(fiber.StatusBadRequest).SendString("The chosen team or event no longer exists")
+		}
+		if errors.Is(err, models.ErrAudienceIncomplete) {
+			return c.Status(fiber.StatusBadRequest).SendString("Choose who to send to")
+		}
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error finding recipients")
+		}
+		if len(channels) == 0 {
+			return c.Status(fiber.StatusBadRequest).SendString("Choose at least one channel")
+		}
+
+		broadcast := models.Broadcast{
+			Audience: audience,
+			Channels: channels,
+			Subject:  strings.TrimSpace(c.FormValue("subject")),
+			Body:     strings.TrimSpace(c.FormValue("body")),
+		}
+		if broadcast.Body == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("Write a message to send")
+		}
+		if broadcast.Subject == "" {
+			broadcast.Subject = "Message for " + audience.Label
+		}
+		if user, err := GetUserFromSession(c); err == nil {
+			broadcast.SentBy = user.Name
+		}
+
+		err = models.SendBroadcast(db, &broadcast, members)
+		if errors.Is(err, models.ErrNoRecipients) {
+			return c.Status(fiber.StatusBadRequest).SendString("Nobody matches the chosen recipients")
+		}
+		if err != nil {
+			log.Print(err)
+			if broadcast.ID == "" {
+				return c.Status(fiber.StatusBadRequest).SendString("Message could not be sent: " + err.Error())
+			}
+		}
+		c.Set("HX-Push-Url", BaseRoute+"/"+broadcast.ID)
+		return renderBroadcast(c, broadcast.ID)
+	})
+
+	// What a broadcast said and who it went to
+	app.Get(BaseRoute+"/:id", Protected, func(c fiber.Ctx) error {
+		return renderBroadcast(c, c.Params("id"))
+	})
+}
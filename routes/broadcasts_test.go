@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// newSignedInApp returns an app whose requests come from a signed in user.
+// Routes are added by the caller.
+func newSignedInApp(db *mongo.Database) *fiber.App {
+	app := fiber.New()
+	app.State().Set("db", db)
+	app.Use(session.New())
+	app.Use(func(c fiber.Ctx) error {
+		session.FromContext(c).Set("authenticated", true)
+		return c.Next()
+	})
+	return app
+}
+
+func postRecipients(t *testing.T, app *fiber.App, form url.Values) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/broadcasts/recipients", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return res.StatusCode, string(body)
+}
+
+func TestBroadcastRecipientsDatabaseError(t *testing.T) {
+	// Nothing listens on port 1, so every query fails
+	client, err := mongo.Connect(options.Client().ApplyURI("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=50"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := newSignedInApp(client.Database("unreachable"))
+	CreateBroadcastRoutes(app, "/broadcasts")
+
+	status, body := postRecipients(t, app, url.Values{"audience": {models.AudienceTeam}})
+	if status != http.StatusOK || !strings.Contains(body, "Nobody matches yet") {
+		t.Errorf("no team chosen: status %d: %s", status, body)
+	}
+
+	status, body = postRecipients(t, app, url.Values{"audience": {models.AudienceTeam}, "teamId": {"worship"}})
+	if status != http.StatusInternalServerError {
+		t.Errorf("database down: status %d, want 500: %s", status, body)
+	}
+}
@@ -1,11 +1,17 @@
 package routes
 
 import (
+	"log"
+
 	"github.com/bcrowe306/nltst_scheduler.git/models"
 	"github.com/bcrowe306/nltst_scheduler.git/pages"
 	"github.com/gofiber/fiber/v3"
 )
 
+// dashboardConflictWeeks is how far ahead the dashboard looks for
+// scheduling conflicts.
+const dashboardConflictWeeks = 8
+
 func CreateDashboardRoutes(app *fiber.App, BaseRoute string) {
 	app.Get("/", Protected, func(c fiber.Ctx) error {
 
@@ -28,7 +34,15 @@ func CreateDashboardRoutes(app *fiber.App, BaseRoute string) {
 			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching events")
 		}
 
+		today := models.Today()
+		conflicts, err := models.GetScheduleConflicts(db, today, today.AddDate(0, 0, 7*dashboardConflictWeeks))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error checking conflicts")
+		}
+
 		data["Events"] = events
+		data["Conflicts"] = conflicts
 
 		data["Positions"] = eventsByPosition
 		RenderHTMXPage(c, pages.DashboardPage(data))
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"log"
+
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+)
+
+func CreateEmailRoutes(app *fiber.App, BaseRoute string) {
+	// SendGrid event webhook. Requests must be signed with the key set in
+	// SendGrid's signed event webhook settings.
+	app.Post(BaseRoute+"/events", func(c fiber.Ctx) error {
+		sendgrid, ok := fiber.GetState[*services.SendGridService](c.App().State(), "sendgridService")
+		if !ok || sendgrid.WebhookKey == nil {
+			return c.Status(fiber.StatusNotFound).SendString("Email events are not configured")
+		}
+		signature := c.Get("X-Twilio-Email-Event-Webhook-Signature")
+		timestamp := c.Get("X-Twilio-Email-Event-Webhook-Timestamp")
+		if !sendgrid.ValidateWebhook(signature, timestamp, c.Body()) {
+			return c.Status(fiber.StatusForbidden).SendString("Invalid signature")
+		}
+		events, err := services.ParseSendGridEvents(c.Body())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid events")
+		}
+
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		for _, e := range events {
+			event, ok := e.DeliveryEvent()
+			if !ok {
+				continue
+			}
+			if err := recordDelivery(db, event); err != nil {
+				log.Print(err)
+				return c.Status(fiber.StatusInternalServerError).SendString("Error recording events")
+			}
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
@@ -99,9 +99,17 @@ func CreateEventTemplatesRoutes(app *fiber.App, BaseRoute string) {
 			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching teams in event template edit")
 		}
 
+		series, err := models.GetEventSeriesByTemplate(db, eventTemplateID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching event series")
+		}
+
 		data := GetDefaultTemplateData(c, "Edit Event Template", BaseRoute)
 		data["EventTemplate"] = eventTemplate
 		data["Teams"] = teams
+		data["Series"] = series
+		data["RecurrencePresets"] = models.RecurrencePresets
 		err = c.Render("pages/event_templates/edit", data, "layouts/main")
 		if err != nil {
 			log.Print(err)
@@ -125,6 +133,13 @@ func CreateEventTemplatesRoutes(app *fiber.App, BaseRoute string) {
 			return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
 		}
 		eventTemplate.ID = eventTemplateID
+		if eventTemplate.Recurrence != "" {
+			rule, err := models.ParseRecurrenceRule(eventTemplate.Recurrence)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString("Invalid recurrence rule: " + err.Error())
+			}
+			eventTemplate.Recurrence = rule.String()
+		}
 		if _, err := models.UpdateEventTemplate(db, &eventTemplate); err != nil {
 			log.Print(err)
 			return c.Status(fiber.StatusInternalServerError).SendString("Error updating event template")
@@ -1,13 +1,33 @@
 package routes
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/bcrowe306/nltst_scheduler.git/models"
 	"github.com/bcrowe306/nltst_scheduler.git/pages"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
 	"github.com/gofiber/fiber/v3"
 
 	"log"
 )
 
+// consentFromForm reads the consent checkboxes on the member forms.
+func consentFromForm(c fiber.Ctx) models.ConsentChoice {
+	return models.ConsentChoice{
+		SMS:      c.FormValue("smsConsent") != "",
+		Email:    c.FormValue("emailConsent") != "",
+		OptedOut: c.FormValue("optedOut") != "",
+	}
+}
+
+// textInChurchConfigured reports whether members can be synced to
+// TextInChurch.
+func textInChurchConfigured(c fiber.Ctx) bool {
+	tic, ok := fiber.GetState[*services.TextInChurchService](c.App().State(), "textInChurchService")
+	return ok && tic.Configured()
+}
+
 func CreateMembersRoutes(app *fiber.App, BaseRoute string) {
 
 	// Members Index
@@ -25,6 +45,7 @@ func CreateMembersRoutes(app *fiber.App, BaseRoute string) {
 		}
 
 		data["Members"] = members
+		data["TextInChurch"] = textInChurchConfigured(c)
 
 		err = RenderHTMXPage(c, pages.MembersPage(data))
 		if err != nil {
@@ -65,6 +86,11 @@ func CreateMembersRoutes(app *fiber.App, BaseRoute string) {
 			log.Print(err)
 			return c.Status(fiber.StatusInternalServerError).SendString("Error creating member")
 		}
+		if err := models.ApplyMemberConsent(db, &new_member, consentFromForm(c), models.ConsentSourceAdmin); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error recording consent")
+		}
+		syncMemberToTextInChurch(c, db, new_member.ID)
 
 		data := GetDefaultTemplateData(c, "Members", BaseRoute)
 		members, err := models.GetAllMembers(db)
@@ -77,14 +103,117 @@ func CreateMembersRoutes(app *fiber.App, BaseRoute string) {
 		return Render(c, pages.MembersPage(data))
 	})
 
-	// Edit Member Form
-	app.Get(BaseRoute+"/:id", Protected, func(c fiber.Ctx) error {
+	// Push every member's contact details to TextInChurch
+	app.Post(BaseRoute+"/textinchurch/sync", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection not found")
+		}
+		tic, ok := fiber.GetState[*services.TextInChurchService](c.App().State(), "textInChurchService")
+		if !ok || !tic.Configured() {
+			return c.Status(fiber.StatusNotFound).SendString("TextInChurch is not configured")
+		}
+
+		synced, failed, err := syncAllToTextInChurch(db, tic)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error syncing members")
+		}
+
+		data := GetDefaultTemplateData(c, "Members", BaseRoute)
+		members, err := models.GetAllMembers(db)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving members")
+		}
+		data["Members"] = members
+		data["TextInChurch"] = true
+		data["Notice"] = fmt.Sprintf("Synced %d members to TextInChurch.", synced)
+		if failed > 0 {
+			data["Notice"] = fmt.Sprintf("Synced %d members to TextInChurch. %d could not be synced; see the server log.", synced, failed)
+		}
+		return Render(c, pages.MembersPage(data))
+	})
+
+	// scheduleRange reads the start and end query values, defaulting to the
+	// next eight weeks.
+	scheduleRange := func(c fiber.Ctx) (time.Time, time.Time) {
+		start, err := time.Parse("2006-01-02", c.Query("start"))
+		if err != nil {
+			start = models.Today()
+		}
+		end, err := time.Parse("2006-01-02", c.Query("end"))
+		if err != nil || end.Before(start) {
+			end = start.AddDate(0, 0, 56)
+		}
+		return start, end
+	}
+
+	// All members roster
+	app.Get(BaseRoute+"/schedule", Protected, func(c fiber.Ctx) error {
+		data := GetDefaultTemplateData(c, "Member Schedules", BaseRoute)
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		start, end := scheduleRange(c)
+		schedules, err := models.GetMembersSchedules(db, start, end.Add(24*time.Hour-time.Nanosecond))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching schedules")
+		}
+
+		data["Schedules"] = schedules
+		data["Start"] = start.Format("2006-01-02")
+		data["End"] = end.Format("2006-01-02")
+
+		err = RenderHTMXPage(c, pages.MembersSchedulePage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
+	// Single member schedule
+	app.Get(BaseRoute+"/:id/schedule", Protected, func(c fiber.Ctx) error {
+		data := GetDefaultTemplateData(c, "Member Schedule", BaseRoute)
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		start, end := scheduleRange(c)
+		schedule, err := models.GetMemberSchedule(db, c.Params("id"), start, end.Add(24*time.Hour-time.Nanosecond))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching schedule")
+		}
+		if schedule == nil {
+			return c.Status(fiber.StatusNotFound).SendString("Member not found")
+		}
+
+		data["Schedule"] = schedule
+		data["Start"] = start.Format("2006-01-02")
+		data["End"] = end.Format("2006-01-02")
+
+		err = RenderHTMXPage(c, pages.MemberSchedulePage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
+	renderMemberEdit := func(c fiber.Ctx, memberID string) error {
 		db, err := GetDatabaseFromContext(c)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("Database connection not found")
 		}
 
-		memberID := c.Params("id")
 		member, err := models.GetMemberByID(db, memberID)
 		if err != nil {
 			log.Print(err)
@@ -93,6 +222,9 @@ func CreateMembersRoutes(app *fiber.App, BaseRoute string) {
 
 		data := GetDefaultTemplateData(c, "Edit Member", BaseRoute)
 		data["Member"] = member
+		if tg, ok := fiber.GetState[*services.TelegramService](c.App().State(), "telegramService"); ok && tg.Configured() {
+			data["Telegram"] = tg
+		}
 
 		err = RenderHTMXPage(c, pages.MembersEditPage(data))
 		if err != nil {
@@ -100,7 +232,37 @@ func CreateMembersRoutes(app *fiber.App, BaseRoute string) {
 			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
 		}
 		return nil
+	}
+
+	// Edit Member Form
+	app.Get(BaseRoute+"/:id", Protected, func(c fiber.Ctx) error {
+		return renderMemberEdit(c, c.Params("id"))
+	})
 
+	// Make a Telegram link for the member to open
+	app.Post(BaseRoute+"/:id/telegram", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection not found")
+		}
+		if _, err := models.CreateTelegramLinkCode(db, c.Params("id")); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error creating Telegram link")
+		}
+		return renderMemberEdit(c, c.Params("id"))
+	})
+
+	// Disconnect the member's Telegram chat
+	app.Post(BaseRoute+"/:id/telegram/unlink", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection not found")
+		}
+		if err := models.UnlinkTelegram(db, c.Params("id"), models.ConsentSourceAdmin, ""); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error unlinking Telegram")
+		}
+		return renderMemberEdit(c, c.Params("id"))
 	})
 
 	// Update Member
@@ -111,6 +273,11 @@ func CreateMembersRoutes(app *fiber.App, BaseRoute string) {
 		}
 
 		memberID := c.Params("id")
+		before, err := models.GetMemberByID(db, memberID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusNotFound).SendString("Member not found")
+		}
 		var member = &models.Member{}
 
 		err = c.Bind().Form(member)
@@ -124,6 +291,23 @@ func CreateMembersRoutes(app *fiber.App, BaseRoute string) {
 			log.Print(err)
 			return c.Status(fiber.StatusInternalServerError).SendString("Error updating member")
 		}
+		if err := models.ApplyMemberConsent(db, before, consentFromForm(c), models.ConsentSourceAdmin); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error recording consent")
+		}
+		// A problem reaching the old number or address says nothing about
+		// the new one
+		if models.NormalizePhone(member.PhoneNumber) != models.NormalizePhone(before.PhoneNumber) {
+			if err := models.ClearDeliveryProblem(db, memberID, models.ChannelSMS); err != nil {
+				log.Print(err)
+			}
+		}
+		if member.Email != before.Email {
+			if err := models.ClearDeliveryProblem(db, memberID, models.ChannelEmail); err != nil {
+				log.Print(err)
+			}
+		}
+		syncMemberToTextInChurch(c, db, memberID)
 
 		return c.Redirect().To(BaseRoute)
 	})
@@ -152,4 +336,94 @@ func CreateMembersRoutes(app *fiber.App, BaseRoute string) {
 		data["Members"] = members
 		return Render(c, pages.MembersPage(data))
 	})
+
+	renderAvailability := func(c fiber.Ctx, memberID string) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection not found")
+		}
+
+		member, err := models.GetMemberByID(db, memberID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving member")
+		}
+
+		data := GetDefaultTemplateData(c, "Member Availability", BaseRoute)
+		data["Member"] = member
+
+		err = RenderHTMXPage(c, pages.MemberAvailabilityPage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	}
+
+	// Member Availability
+	app.Get(BaseRoute+"/:id/availability", Protected, func(c fiber.Ctx) error {
+		return renderAvailability(c, c.Params("id"))
+	})
+
+	// Add Availability Rule
+	app.Post(BaseRoute+"/:id/availability", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection not found")
+		}
+
+		memberID := c.Params("id")
+		var rule models.AvailabilityRule
+		err = c.Bind().Form(&rule)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid form data")
+		}
+
+		switch rule.Kind {
+		case models.AvailabilityBlackout:
+			rule.StartDate, err = time.Parse("2006-01-02", c.FormValue("startDate"))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString("Invalid start date")
+			}
+			rule.EndDate, err = time.Parse("2006-01-02", c.FormValue("endDate"))
+			if err != nil {
+				rule.EndDate = rule.StartDate
+			}
+			if rule.EndDate.Before(rule.StartDate) {
+				return c.Status(fiber.StatusBadRequest).SendString("End date is before start date")
+			}
+		case models.AvailabilityRecurring:
+			if rule.Weekday < time.Sunday || rule.Weekday > time.Saturday || rule.WeekOfMonth < -1 || rule.WeekOfMonth > 5 {
+				return c.Status(fiber.StatusBadRequest).SendString("Invalid recurring day")
+			}
+		default:
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid availability kind")
+		}
+
+		_, err = models.AddAvailabilityRule(db, memberID, rule)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error saving availability")
+		}
+
+		return renderAvailability(c, memberID)
+	})
+
+	// Remove Availability Rule
+	app.Delete(BaseRoute+"/:id/availability/:rule_id", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection not found")
+		}
+
+		memberID := c.Params("id")
+		_, err = models.RemoveAvailabilityRule(db, memberID, c.Params("rule_id"))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error removing availability")
+		}
+
+		return renderAvailability(c, memberID)
+	})
 }
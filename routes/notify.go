@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"log"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// notifyMember renders the message template stored under templateKey for
+// member and queues it by SMS and email, using whichever contact details
+// they have. The recipient placeholders in data are filled in from member.
+// key, when not blank, stops the same notification being queued twice.
+// Failures are logged rather than returned so a notification problem never
+// undoes the action that triggered it.
+func notifyMember(c fiber.Ctx, member *models.Member, templateKey string, data models.MessageData, key string) {
+	if member == nil {
+		return
+	}
+	notifier, ok := fiber.GetState[*services.Notifier](c.App().State(), "notifier")
+	if !ok {
+		log.Print("Notifier is not configured")
+		return
+	}
+	db, err := GetDatabaseFromContext(c)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	data.FirstName, data.MemberName = member.FirstName, member.FullName()
+	msg, err := models.RenderMemberMessage(db, templateKey, data, key)
+	if err != nil {
+		log.Print("Error rendering ", templateKey, " message: ", err)
+		return
+	}
+	if err := models.EnqueueMemberMessage(db, member, notifier.ChannelNames(), msg); err != nil {
+		log.Print(err)
+	}
+}
+
+func GetLinkSignerFromContext(c fiber.Ctx) (*services.LinkSigner, error) {
+	signer, ok := fiber.GetState[*services.LinkSigner](c.App().State(), "linkSigner")
+	if !ok {
+		return nil, fiber.ErrInternalServerError
+	}
+	return signer, nil
+}
+
+// alertLeaderOfDecline tells the event's team leader that member can't
+// make positions at event.
+func alertLeaderOfDecline(c fiber.Ctx, db *mongo.Database, event *models.Event, member *models.Member, positions []string, key string) {
+	if event.TeamID == "" {
+		return
+	}
+	team, err := models.GetTeamByID(db, event.TeamID)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if leader := team.Leader(); leader != nil {
+		data := models.NewMessageData(nil, event, positions...)
+		data.FromName = member.FullName()
+		notifyMember(c, leader, models.TemplateDeclineAlert, data, key)
+	}
+}
+
+// recordDelivery stores a provider's delivery report against the message it
+// is about.
+func recordDelivery(db *mongo.Database, event services.DeliveryEvent) error {
+	msg, err := models.RecordDelivery(db, event.Provider, event.ProviderID, event.Status, event.Detail, event.At)
+	if err != nil {
+		return err
+	}
+	if msg != nil && event.Status != services.DeliveryDelivered {
+		log.Print("Message ", msg.ID, " to ", msg.To, " was ", event.Status, ": ", event.Detail)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"slices"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/pages"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// outboxPageSize limits how many messages the outbox page lists.
+const outboxPageSize = 200
+
+func CreateOutboxRoutes(app *fiber.App, BaseRoute string) {
+	renderOutbox := func(c fiber.Ctx, status string) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		if !slices.Contains(models.OutboxStatuses, status) {
+			status = ""
+		}
+		messages, err := models.GetOutboxMessages(db, status, outboxPageSize)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching outbox")
+		}
+		counts, err := models.CountOutboxMessages(db)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching outbox")
+		}
+
+		data := GetDefaultTemplateData(c, "Outbox", BaseRoute)
+		data["Messages"] = messages
+		data["Counts"] = counts
+		data["Status"] = status
+		if err := RenderHTMXPage(c, pages.OutboxPage(data)); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	}
+
+	app.Get(BaseRoute, Protected, func(c fiber.Ctx) error {
+		return renderOutbox(c, c.Query("status"))
+	})
+
+	// action applies fn to the message and re-renders the outbox with the
+	// filter the admin was looking at.
+	action := func(fn func(db *mongo.Database, id string) error) fiber.Handler {
+		return func(c fiber.Ctx) error {
+			db, err := GetDatabaseFromContext(c)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+			}
+			if err := fn(db, c.Params("id")); err != nil {
+				if errors.Is(err, models.ErrOutboxNotFound) {
+					return c.Status(fiber.StatusConflict).SendString(err.Error())
+				}
+				log.Print(err)
+				return c.Status(fiber.StatusInternalServerError).SendString("Error updating message")
+			}
+			return renderOutbox(c, c.FormValue("status"))
+		}
+	}
+
+	app.Post(BaseRoute+"/:id/retry", Protected, action(models.RetryOutboxMessage))
+	app.Post(BaseRoute+"/:id/cancel", Protected, action(models.CancelOutboxMessage))
+}
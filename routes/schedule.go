@@ -1,11 +1,16 @@
 package routes
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/bcrowe306/nltst_scheduler.git/models"
 	"github.com/bcrowe306/nltst_scheduler.git/pages"
 	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 
 	"log"
 )
@@ -19,19 +24,37 @@ func CreateScheduleRoutes(app *fiber.App, BaseRoute string) {
 			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
 		}
 
-		events, err := models.GetEventsWithMemberDetails(db)
+		anchor := models.Today()
+		if date, err := time.Parse("2006-01-02", c.Query("date")); err == nil {
+			anchor = date
+		}
+		view := models.NewCalendarView(c.Query("view"), anchor)
+
+		events, err := models.GetEventsWithMemberDetailsInRange(db, view.Start, view.WindowEnd())
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching events")
 		}
+		eventsByDay := map[string][]models.EventWithMemberDetails{}
+		for _, event := range events {
+			day := event.Date.Format("2006-01-02")
+			eventsByDay[day] = append(eventsByDay[day], event)
+		}
 
 		event_templates, err := models.GetAllEventTemplates(db)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching event templates")
 		}
 
+		conflicts, err := models.GetScheduleConflicts(db, view.Start, view.WindowEnd())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Error checking conflicts")
+		}
+
 		data := GetDefaultTemplateData(c, "Schedule", BaseRoute)
-		data["Events"] = events
+		data["Calendar"] = view
+		data["EventsByDay"] = eventsByDay
 		data["EventTemplates"] = event_templates
+		data["Conflicts"] = conflicts
 
 		err = RenderHTMXPage(c, pages.SchedulePage(data))
 		if err != nil {
@@ -41,6 +64,246 @@ func CreateScheduleRoutes(app *fiber.App, BaseRoute string) {
 		return nil
 	})
 
+	// Conflicts report
+	app.Get(BaseRoute+"/conflicts", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		start, err := time.Parse("2006-01-02", c.Query("start"))
+		if err != nil {
+			start = models.Today()
+		}
+		end, err := time.Parse("2006-01-02", c.Query("end"))
+		if err != nil {
+			end = start.AddDate(0, 3, 0)
+		}
+
+		conflicts, err := models.GetScheduleConflicts(db, start, end)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error checking conflicts")
+		}
+
+		data := GetDefaultTemplateData(c, "Conflicts", BaseRoute)
+		data["Conflicts"] = conflicts
+		data["Start"] = start.Format("2006-01-02")
+		data["End"] = end.Format("2006-01-02")
+
+		err = RenderHTMXPage(c, pages.ConflictsPage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
+	// Copy schedule form
+	app.Get(BaseRoute+"/copy", Protected, func(c fiber.Ctx) error {
+		now := time.Now()
+		thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		nextMonth := thisMonth.AddDate(0, 1, 0)
+
+		data := GetDefaultTemplateData(c, "Copy Schedule", BaseRoute)
+		data["SourceStart"] = thisMonth.Format("2006-01-02")
+		data["SourceEnd"] = nextMonth.AddDate(0, 0, -1).Format("2006-01-02")
+		data["TargetStart"] = nextMonth.Format("2006-01-02")
+		data["TargetEnd"] = nextMonth.AddDate(0, 1, -1).Format("2006-01-02")
+
+		err := RenderHTMXPage(c, pages.ScheduleCopyPage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
+	// Copy schedule, or preview the copy when dryRun is set
+	app.Post(BaseRoute+"/copy", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		var dates [4]time.Time
+		for i, field := range []string{"sourceStart", "sourceEnd", "targetStart", "targetEnd"} {
+			dates[i], err = time.Parse("2006-01-02", c.FormValue(field))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString("Invalid date for " + field)
+			}
+		}
+		copyAssignments := c.FormValue("copyAssignments") == "on"
+		dryRun := c.FormValue("dryRun") != "false"
+
+		plan, err := models.PlanScheduleCopy(db, dates[0], dates[1], dates[2], dates[3])
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusBadRequest).SendString("Error planning schedule copy: " + err.Error())
+		}
+
+		created := 0
+		if !dryRun {
+			created, err = models.ApplyScheduleCopy(db, plan, copyAssignments)
+			if err != nil {
+				log.Print(err)
+				return c.Status(fiber.StatusInternalServerError).SendString("Error copying schedule")
+			}
+		}
+
+		return RenderFullPage(c, pages.ScheduleCopyPreview(plan, copyAssignments, created, dryRun))
+	})
+
+	// Auto-fill proposal for a date range
+	app.Get(BaseRoute+"/autofill", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		start, err := time.Parse("2006-01-02", c.Query("start"))
+		if err != nil {
+			start = models.Today()
+		}
+		end, err := time.Parse("2006-01-02", c.Query("end"))
+		if err != nil {
+			end = start.AddDate(0, 1, 0)
+		}
+
+		events, err := models.GetEventsInDateRange(db, start, end)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching events")
+		}
+
+		proposals, err := models.ProposeRosterFill(db, events)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error building auto-fill proposal")
+		}
+
+		data := GetDefaultTemplateData(c, "Auto-fill", BaseRoute)
+		data["Proposals"] = proposals
+		data["EventID"] = ""
+		data["Start"] = start.Format("2006-01-02")
+		data["End"] = end.Format("2006-01-02")
+		data["ReturnTo"] = BaseRoute
+
+		err = RenderHTMXPage(c, pages.AutoFillPage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
+	// Auto-fill proposal for a single event
+	app.Get(BaseRoute+"/:event_id/autofill", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		eventID := c.Params("event_id")
+		event, err := models.GetEventByID(db, eventID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching event")
+		}
+
+		proposals, err := models.ProposeRosterFill(db, []models.Event{*event})
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error building auto-fill proposal")
+		}
+
+		data := GetDefaultTemplateData(c, "Auto-fill", BaseRoute)
+		data["Proposals"] = proposals
+		data["EventID"] = eventID
+		data["ReturnTo"] = BaseRoute + "/" + eventID
+
+		err = RenderHTMXPage(c, pages.AutoFillPage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
+	// Accept an auto-fill proposal. Each slot is posted as
+	// slot:<event_id>:<position_id> = <member_id>. Slots are checked again
+	// as they are filled, since the proposal may have been edited or the
+	// schedule changed since; any that can't be filled are listed with the
+	// open slots that remain.
+	app.Post(BaseRoute+"/autofill", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		var eventIDs []string
+		var rejected []string
+		filled := 0
+		for key, value := range c.Request().PostArgs().All() {
+			parts := strings.Split(string(key), ":")
+			if len(parts) != 3 || parts[0] != "slot" || len(value) == 0 {
+				continue
+			}
+			if !slices.Contains(eventIDs, parts[1]) {
+				eventIDs = append(eventIDs, parts[1])
+			}
+			problem, err := fillProposedSlot(db, parts[1], parts[2], string(value))
+			if err != nil {
+				log.Print(err)
+				problem = "A slot could not be saved; try again"
+			}
+			if problem != "" {
+				rejected = append(rejected, problem)
+				continue
+			}
+			filled++
+		}
+
+		returnTo := c.FormValue("returnTo")
+		if !strings.HasPrefix(returnTo, BaseRoute) {
+			returnTo = BaseRoute
+		}
+		if len(rejected) == 0 {
+			if isHTMXRequest(c) {
+				c.Set("HX-Redirect", returnTo)
+				return c.SendStatus(fiber.StatusOK)
+			}
+			return c.Redirect().To(returnTo)
+		}
+
+		// Show what couldn't be filled with a fresh proposal for the
+		// slots still open
+		var events []models.Event
+		for _, eventID := range eventIDs {
+			if event, err := models.GetEventByID(db, eventID); err == nil {
+				events = append(events, *event)
+			}
+		}
+		proposals, err := models.ProposeRosterFill(db, events)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error building auto-fill proposal")
+		}
+		data := GetDefaultTemplateData(c, "Auto-fill", BaseRoute)
+		data["Proposals"] = proposals
+		data["EventID"] = c.FormValue("eventId")
+		data["Start"] = c.FormValue("start")
+		data["End"] = c.FormValue("end")
+		data["ReturnTo"] = returnTo
+		data["Notice"] = fmt.Sprintf("Filled %d of %d slots.", filled, filled+len(rejected))
+		data["Rejected"] = rejected
+		err = RenderHTMXPage(c, pages.AutoFillPage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
 	// Remove Position from Event
 	app.Get(BaseRoute+"/:event_id/positions/delete/:position_name", Protected, func(c fiber.Ctx) error {
 		db, err := GetDatabaseFromContext(c)
@@ -100,9 +363,69 @@ func CreateScheduleRoutes(app *fiber.App, BaseRoute string) {
 			}
 		}
 
+		unavailable := map[string]string{}
+		for _, member := range teamMembers {
+			if reason := member.UnavailableReason(event.Date); reason != "" {
+				unavailable[member.ID] = reason
+			}
+		}
+
+		day := models.DateOnly(event.Date)
+		sameDayEvents, err := models.GetEventsInDateRange(db, day, day.Add(24*time.Hour-time.Nanosecond))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching events")
+		}
+		conflicts := models.FindConflicts(sameDayEvents)
+		positionConflicts := map[string]*models.Conflict{}
+		for _, pa := range event.PositionAssignments {
+			if conflict, ok := models.MemberConflict(conflicts, event.ID, pa.MemberID); ok && pa.MemberID != "" {
+				positionConflicts[pa.ID] = conflict
+			}
+		}
+
+		// Names for everyone in the swap history, who may have left the team
+		var historyIDs []string
+		for _, pa := range event.PositionAssignments {
+			historyIDs = append(historyIDs, pa.OriginalMemberID)
+			for _, swap := range pa.SwapRequests {
+				historyIDs = append(historyIDs, swap.FromMemberID, swap.AcceptedBy)
+			}
+		}
+		memberNames := map[string]string{}
+		if len(historyIDs) > 0 {
+			historyMembers, err := models.GetMembersByIDs(db, historyIDs)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).SendString("Error fetching members")
+			}
+			for _, member := range historyMembers {
+				memberNames[member.ID] = member.FullName()
+			}
+		}
+
+		swapLinks := map[string]string{}
+		if signer, err := GetLinkSignerFromContext(c); err == nil {
+			for i := range event.PositionAssignments {
+				pa := &event.PositionAssignments[i]
+				if pa.MemberID != "" && pa.OpenSwap() == nil {
+					swapLinks[pa.ID] = SwapRequestURL(signer, event, pa)
+				}
+			}
+		}
+
+		qualifications, err := models.GetQualificationMatrix(db, event.TeamID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching qualifications")
+		}
+
 		data := GetDefaultTemplateData(c, "Edit Event", BaseRoute)
 		data["Event"] = event
 		data["TeamMembers"] = teamMembers
+		data["Unavailable"] = unavailable
+		data["PositionConflicts"] = positionConflicts
+		data["MemberNames"] = memberNames
+		data["SwapLinks"] = swapLinks
+		data["Qualifications"] = qualifications
+		data["ReminderOptions"] = models.ReminderOptions
 
 		err = c.Render("pages/schedule/edit", data, "layouts/main")
 		if err != nil {
@@ -141,8 +464,7 @@ func CreateScheduleRoutes(app *fiber.App, BaseRoute string) {
 
 		// If an event template was selected, copy its details to the new event
 		if eventTemplate != nil {
-			temp_event.StartTime = eventTemplate.StartTime
-			temp_event.EndTime = eventTemplate.EndTime
+			temp_event.StartAt, temp_event.EndAt = models.EventInstants(parsed_date, eventTemplate.StartTime, eventTemplate.EndTime)
 			temp_event.Name = eventTemplate.Name
 			temp_event.Description = eventTemplate.Description
 			temp_event.TeamID = eventTemplate.TeamID
@@ -199,14 +521,26 @@ func CreateScheduleRoutes(app *fiber.App, BaseRoute string) {
 			return c.Status(fiber.StatusInternalServerError).SendString("Error parsing date")
 		}
 		event.Date = date
-		event.StartTime = c.FormValue("startTime")
-		event.EndTime = c.FormValue("endTime")
+		previousStart := event.StartAt
+		event.StartAt, event.EndAt = models.EventInstants(date, c.FormValue("startTime"), c.FormValue("endTime"))
+		event.ReminderEnabled = c.FormValue("reminderEnabled") == "on"
+		if interval, err := time.ParseDuration(c.FormValue("reminderInterval")); err == nil {
+			event.ReminderInterval = interval
+		}
 
 		_, err = models.UpdateEvent(db, event)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("Error updating event")
 		}
 
+		// A moved event gets a fresh reminder
+		if !event.StartAt.Equal(previousStart) {
+			_, err = models.ResetEventReminder(db, eventID)
+			if err != nil {
+				log.Print(err)
+			}
+		}
+
 		return c.Redirect().To(BaseRoute + "/" + eventID)
 	})
 
@@ -242,6 +576,25 @@ func CreateScheduleRoutes(app *fiber.App, BaseRoute string) {
 		positionID := c.FormValue("positionID")
 		memberID := c.FormValue("member_id")
 
+		if memberID != "" {
+			event, err := models.GetEventByID(db, eventID)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).SendString("Error fetching event")
+			}
+			member, err := models.GetMemberByID(db, memberID)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).SendString("Error fetching member")
+			}
+			problem, err := models.CheckAssignment(db, event, positionID, member, c.FormValue("override") == "on")
+			if err != nil {
+				log.Print(err)
+				return c.Status(fiber.StatusInternalServerError).SendString("Error checking assignment")
+			}
+			if problem != "" {
+				return c.Status(fiber.StatusConflict).SendString(problem)
+			}
+		}
+
 		_, err = models.AssignPositionToMember(db, eventID, positionID, memberID)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("Error assigning position to member")
@@ -250,6 +603,62 @@ func CreateScheduleRoutes(app *fiber.App, BaseRoute string) {
 		return c.Redirect().To(BaseRoute + "/" + eventID)
 	})
 
+	// Open a swap request for a position on the member's behalf
+	app.Post(BaseRoute+"/:event_id/positions/swap", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		eventID := c.Params("event_id")
+		positionID := c.FormValue("positionID")
+
+		requestedBy := "A team leader"
+		if user, err := GetUserFromSession(c); err == nil {
+			requestedBy = user.Name
+		}
+
+		swap, eligible, err := models.OpenSwapRequest(db, eventID, positionID, requestedBy)
+		if errors.Is(err, models.ErrSwapAlreadyOpen) || errors.Is(err, models.ErrPositionUnassigned) {
+			return c.Status(fiber.StatusConflict).SendString(err.Error())
+		}
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error opening swap request")
+		}
+
+		event, err := models.GetEventByID(db, eventID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching event")
+		}
+		holder, err := models.GetMemberByID(db, swap.FromMemberID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching member")
+		}
+		offerSwap(c, event, event.FindPosition(positionID), swap, eligible, holder.FullName())
+
+		return c.Redirect().To(BaseRoute + "/" + eventID)
+	})
+
+	// Cancel an open swap request
+	app.Post(BaseRoute+"/:event_id/positions/swap/cancel", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		eventID := c.Params("event_id")
+
+		err = models.CancelSwapRequest(db, eventID, c.FormValue("positionID"), c.FormValue("swapID"))
+		if errors.Is(err, models.ErrSwapClosed) {
+			return c.Status(fiber.StatusConflict).SendString(err.Error())
+		}
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error cancelling swap request")
+		}
+
+		return c.Redirect().To(BaseRoute + "/" + eventID)
+	})
+
 	// Unassign Position from Member
 	app.Post(BaseRoute+"/:event_id/positions/unassign", Protected, func(c fiber.Ctx) error {
 		db, err := GetDatabaseFromContext(c)
@@ -268,3 +677,46 @@ func CreateScheduleRoutes(app *fiber.App, BaseRoute string) {
 	})
 
 }
+
+// fillProposedSlot puts memberID in an open slot of an auto-fill proposal
+// after the checks a manual assignment gets. It returns why the slot wasn't
+// filled, or "" when it was.
+func fillProposedSlot(db *mongo.Database, eventID, positionID, memberID string) (string, error) {
+	event, err := models.GetEventByID(db, eventID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "An event in the proposal has been deleted", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	position := event.FindPosition(positionID)
+	if position == nil {
+		return "A position in " + event.Name + " has been removed", nil
+	}
+	slot := position.PositionName + " for " + event.Name + " on " + event.When()
+	if position.MemberID != "" {
+		return slot + " was filled since the proposal was made", nil
+	}
+	member, err := models.GetMemberByID(db, memberID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return slot + ": the proposed member no longer exists", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	problem, err := models.CheckAssignment(db, event, positionID, member, false)
+	if err != nil {
+		return "", err
+	}
+	if problem != "" {
+		return slot + ": " + problem, nil
+	}
+	filled, err := models.FillEmptyPosition(db, eventID, positionID, memberID)
+	if err != nil {
+		return "", err
+	}
+	if !filled {
+		return slot + " was filled since the proposal was made", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+)
+
+func TestAutoFillRechecksSlots(t *testing.T) {
+	db := testDatabase(t)
+	app := newSignedInApp(db)
+	CreateScheduleRoutes(app, "/schedule")
+
+	jo := models.Member{FirstName: "Jo", LastName: "Smith"}
+	if _, err := models.InsertMember(db, &jo); err != nil {
+		t.Fatal(err)
+	}
+	day := models.Today().AddDate(0, 0, 3)
+	event := models.Event{ID: "service", Name: "Sunday Service", Date: day,
+		PositionAssignments: []models.PositionAssignment{
+			{ID: "cam", PositionName: "Camera"},
+			{ID: "lights", PositionName: "Lights", MemberID: "taken"},
+			{ID: "sound", PositionName: "Sound"},
+		}}
+	if _, err := db.Collection(models.EventCollection).InsertOne(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{
+		"returnTo":            {"/schedule/service"},
+		"eventId":             {"service"},
+		"slot:service:cam":    {jo.ID},
+		"slot:service:lights": {jo.ID},
+		"slot:service:sound":  {"gone"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/schedule/autofill", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", res.StatusCode, body)
+	}
+	for _, want := range []string{"Filled 1 of 3 slots.", "Lights for Sunday Service", "was filled since the proposal was made", "no longer exists"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("response is missing %q:\n%s", want, body)
+		}
+	}
+
+	saved, err := models.GetEventByID(db, "service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for positionID, want := range map[string]string{"cam": jo.ID, "lights": "taken", "sound": ""} {
+		if got := saved.FindPosition(positionID).MemberID; got != want {
+			t.Errorf("%s is held by %q, want %q", positionID, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/gofiber/fiber/v3"
+
+	"log"
+)
+
+func CreateSeriesRoutes(app *fiber.App, BaseRoute string) {
+
+	// Create a recurring series from an event template
+	app.Post(BaseRoute, Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		eventTemplateID := c.FormValue("eventTemplateID")
+		eventTemplate, err := models.GetEventTemplateByID(db, eventTemplateID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching event template")
+		}
+
+		startDate, err := time.Parse("2006-01-02", c.FormValue("startDate"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid start date")
+		}
+		endDate, err := time.Parse("2006-01-02", c.FormValue("endDate"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid end date")
+		}
+
+		_, _, err = models.CreateEventSeries(db, eventTemplate, startDate, endDate)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusBadRequest).SendString("Error creating series: " + err.Error())
+		}
+
+		return c.Redirect().To("/event_templates/" + eventTemplateID)
+	})
+
+	// Extend a series to a later end date
+	app.Post(BaseRoute+"/:series_id/extend", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		seriesID := c.Params("series_id")
+		endDate, err := time.Parse("2006-01-02", c.FormValue("endDate"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid end date")
+		}
+
+		series, err := models.GetEventSeriesByID(db, seriesID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString("Series not found")
+		}
+
+		_, err = models.ExtendEventSeries(db, seriesID, endDate)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusBadRequest).SendString("Error extending series: " + err.Error())
+		}
+
+		return c.Redirect().To("/event_templates/" + series.TemplateID)
+	})
+
+	// Shorten a series, deleting events after the new end date
+	app.Post(BaseRoute+"/:series_id/shorten", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		seriesID := c.Params("series_id")
+		endDate, err := time.Parse("2006-01-02", c.FormValue("endDate"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid end date")
+		}
+
+		series, err := models.GetEventSeriesByID(db, seriesID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString("Series not found")
+		}
+
+		_, err = models.ShortenEventSeries(db, seriesID, endDate)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusBadRequest).SendString("Error shortening series: " + err.Error())
+		}
+
+		return c.Redirect().To("/event_templates/" + series.TemplateID)
+	})
+
+	// Delete a series and all of its events
+	app.Get(BaseRoute+"/:series_id/delete", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+
+		seriesID := c.Params("series_id")
+		series, err := models.GetEventSeriesByID(db, seriesID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString("Series not found")
+		}
+
+		_, err = models.DeleteEventSeriesWithEvents(db, seriesID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error deleting series")
+		}
+
+		return c.Redirect().To("/event_templates/" + series.TemplateID)
+	})
+}
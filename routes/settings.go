@@ -1,19 +1,235 @@
 package routes
 
 import (
-	"github.com/gofiber/fiber/v3"
-
+	"errors"
 	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 
+	"github.com/bcrowe306/nltst_scheduler.git/models"
 	"github.com/bcrowe306/nltst_scheduler.git/pages"
 )
 
+// previewMessageData builds placeholder values from the next upcoming
+// event that has someone assigned, so template previews look like real
+// messages. The returned note says what the preview is based on.
+func previewMessageData(c fiber.Ctx, db *mongo.Database) (models.MessageData, string) {
+	sample := models.MessageData{
+		FirstName:   "Jordan",
+		MemberName:  "Jordan Smith",
+		EventName:   "Sunday Service",
+		Date:        "Oct 18",
+		Time:        "9:00 AM",
+		When:        "Sun, Oct 18 at 9:00 AM",
+		Position:    "Camera 1",
+		ConfirmLink: "https://example.com/assignments/confirm",
+		DeclineLink: "https://example.com/assignments/decline",
+		SwapLink:    "https://example.com/swaps/take",
+		FromName:    "Alex Jones",
+		ToName:      "Sam Lee",
+	}
+	sample.Assignments = []models.AssignmentLinks{{Position: sample.Position, ConfirmLink: sample.ConfirmLink, DeclineLink: sample.DeclineLink}}
+	sample.Changes = []models.ChangeLine{
+		{Text: "Added: Camera 1 for Sunday Service on " + sample.When, ConfirmLink: sample.ConfirmLink, DeclineLink: sample.DeclineLink},
+		{Text: "Removed: Lights for Wednesday Night on Wed, Oct 21 at 7:00 PM"},
+	}
+	sample.Period = "Oct 18 - Oct 24"
+	sample.Schedule = []models.ScheduleLine{
+		{Date: "Sun Oct 18", Time: "9:00 AM - 11:00 AM", When: sample.When, EventName: sample.EventName, Team: "Media", Position: sample.Position},
+		{Date: "Wed Oct 21", Time: "7:00 PM - 8:30 PM", When: "Wed, Oct 21 at 7:00 PM", EventName: "Wednesday Night", Team: "Media", Position: "Lights"},
+	}
+	sampleNote := "There is no upcoming event with members assigned, so the preview uses sample data."
+
+	event, err := models.GetNextStaffedEvent(db, time.Now())
+	if err != nil {
+		log.Print(err)
+		return sample, sampleNote
+	}
+	if event == nil {
+		return sample, sampleNote
+	}
+	signer, err := GetLinkSignerFromContext(c)
+	if err != nil {
+		log.Print(err)
+		return sample, sampleNote
+	}
+
+	// Preview as the first assigned member, with whoever else is serving
+	// standing in for a teammate
+	var member, other *models.Member
+	var positions []models.PositionAssignment
+	for _, pa := range event.PositionAssignments {
+		if pa.MemberID == "" {
+			continue
+		}
+		if member == nil {
+			if member, err = models.GetMemberByID(db, pa.MemberID); err != nil {
+				log.Print(err)
+				return sample, sampleNote
+			}
+		}
+		if pa.MemberID == member.ID {
+			positions = append(positions, pa)
+		} else if other == nil {
+			other, _ = models.GetMemberByID(db, pa.MemberID)
+		}
+	}
+	if other == nil {
+		other = member
+	}
+
+	var names []string
+	for _, pa := range positions {
+		names = append(names, pa.PositionName)
+	}
+	data := models.NewMessageData(member, event, names...)
+	for _, pa := range positions {
+		confirm, decline := signer.AssignmentURLs(event.ID, pa.ID, pa.MemberID, event.LinkExpiry())
+		data.Assignments = append(data.Assignments, models.AssignmentLinks{Position: pa.PositionName, ConfirmLink: confirm, DeclineLink: decline})
+	}
+	data.ConfirmLink, data.DeclineLink = data.Assignments[0].ConfirmLink, data.Assignments[0].DeclineLink
+	data.SwapLink = SwapRequestURL(signer, event, &positions[0])
+	data.FromName, data.ToName = member.FullName(), other.FullName()
+	data.Changes = []models.ChangeLine{{
+		Text:        "Added: " + data.Position + " for " + event.Name + " on " + event.When(),
+		ConfirmLink: data.ConfirmLink,
+		DeclineLink: data.DeclineLink,
+	}}
+	data.Period = models.FormatPeriod(models.DateOnly(event.Date), models.DateOnly(event.Date).AddDate(0, 0, 6))
+	data.Schedule = []models.ScheduleLine{{
+		Date:      event.Date.Format("Mon Jan 2"),
+		Time:      event.TimeRange(),
+		When:      event.When(),
+		EventName: event.Name,
+		Position:  data.Position,
+	}}
+	if team, err := models.GetTeamByID(db, event.TeamID); err == nil {
+		data.Schedule[0].Team = team.Name
+	}
+	return data, "Previewing as " + member.FullName() + " for " + event.Name + " on " + event.When() + "."
+}
+
 func CreateSettingsRoutes(app *fiber.App, BaseRoute string) {
 	app.Get(BaseRoute, Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		templates, err := models.GetMessageTemplates(db)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching message templates")
+		}
 		data := GetDefaultTemplateData(c, "Settings", BaseRoute)
-		err := RenderHTMXPage(c, pages.SettingsPage(data))
+		data["MessageTemplates"] = templates
+		err = RenderHTMXPage(c, pages.SettingsPage(data))
+
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
+	// renderMessageTemplate shows the editor for t, previewing its current
+	// wording. notice is shown above the form after a save or reset.
+	renderMessageTemplate := func(c fiber.Ctx, db *mongo.Database, t *models.MessageTemplate, notice string, saveErr error) error {
+		preview, note := previewMessageData(c, db)
+		rendered, renderErr := t.Render(preview)
+
+		data := GetDefaultTemplateData(c, "Settings", BaseRoute)
+		data["Template"] = t
+		data["Notice"] = notice
+		data["SaveError"] = saveErr
+		data["Preview"] = pages.MessagePreviewData{Message: rendered, Err: renderErr, Note: note}
+		if err := RenderHTMXPage(c, pages.MessageTemplatePage(data)); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	}
 
+	// templateFromForm reads edited wording for the template in the path.
+	templateFromForm := func(c fiber.Ctx) (*models.MessageTemplate, error) {
+		t, err := models.DefaultMessageTemplate(c.Params("key"))
 		if err != nil {
+			return nil, err
+		}
+		t.SMS = c.FormValue("sms")
+		t.EmailSubject = c.FormValue("emailSubject")
+		t.EmailText = c.FormValue("emailText")
+		t.EmailHTML = c.FormValue("emailHtml")
+		return t, nil
+	}
+
+	app.Get(BaseRoute+"/messages/:key", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		t, err := models.GetMessageTemplate(db, c.Params("key"))
+		if errors.Is(err, models.ErrUnknownTemplate) {
+			return c.Status(fiber.StatusNotFound).SendString("Message template not found")
+		}
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching message template")
+		}
+		return renderMessageTemplate(c, db, t, "", nil)
+	})
+
+	// Save a template. Wording that doesn't parse is shown back with the
+	// error rather than saved.
+	app.Post(BaseRoute+"/messages/:key", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		t, err := templateFromForm(c)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString("Message template not found")
+		}
+		if err := t.Validate(); err != nil {
+			return renderMessageTemplate(c, db, t, "", err)
+		}
+		if err := models.UpdateMessageTemplate(db, t); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error saving message template")
+		}
+		return renderMessageTemplate(c, db, t, "Template saved.", nil)
+	})
+
+	app.Post(BaseRoute+"/messages/:key/reset", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		t, err := models.ResetMessageTemplate(db, c.Params("key"))
+		if errors.Is(err, models.ErrUnknownTemplate) {
+			return c.Status(fiber.StatusNotFound).SendString("Message template not found")
+		}
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error resetting message template")
+		}
+		return renderMessageTemplate(c, db, t, "Template reset to the default wording.", nil)
+	})
+
+	// Live preview of unsaved wording, refreshed as the admin types
+	app.Post(BaseRoute+"/messages/:key/preview", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		t, err := templateFromForm(c)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString("Message template not found")
+		}
+		preview, note := previewMessageData(c, db)
+		rendered, renderErr := t.Render(preview)
+		if err := RenderFullPage(c, pages.MessagePreview(pages.MessagePreviewData{Message: rendered, Err: renderErr, Note: note})); err != nil {
 			log.Print(err)
 			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
 		}
@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// formParams returns the posted form fields as url.Values.
+func formParams(c fiber.Ctx) url.Values {
+	params := url.Values{}
+	for key, value := range c.Request().PostArgs().All() {
+		params.Add(string(key), string(value))
+	}
+	return params
+}
+
+// handleInboundSMS acts on a text sent to us and returns the reply to send
+// back. STOP, START and HELP manage the sender's messages. A YES or NO
+// confirms or declines the sender's next assignment; a decline is passed on
+// to the team leader.
+func handleInboundSMS(c fiber.Ctx, db *mongo.Database, msg services.InboundSMS) (string, error) {
+	member, err := models.GetMemberByPhone(db, msg.From)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "Sorry, we don't recognize this number.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch models.ParseSMSKeyword(msg.Body) {
+	case models.KeywordStop:
+		if err := models.OptOutMember(db, member.ID, models.ConsentSourceSMS, "Texted "+strings.TrimSpace(msg.Body)); err != nil {
+			return "", err
+		}
+		syncMemberToTextInChurch(c, db, member.ID)
+		return "You've been unsubscribed and won't get any more messages from us. Reply START to resubscribe.", nil
+	case models.KeywordStart:
+		if err := models.OptInMemberSMS(db, member.ID, models.ConsentSourceSMS, "Texted "+strings.TrimSpace(msg.Body)); err != nil {
+			return "", err
+		}
+		syncMemberToTextInChurch(c, db, member.ID)
+		return "You're subscribed again and will get schedule messages by text. Reply STOP to unsubscribe.", nil
+	case models.KeywordHelp:
+		return "Schedule messages for your team. Reply YES or NO to answer a reminder, STOP to unsubscribe or START to resubscribe.", nil
+	}
+
+	status, ok := models.ParseConfirmationReply(msg.Body)
+	if !ok {
+		return "Reply YES to confirm your next assignment or NO if you can't make it.", nil
+	}
+	return respondToNextAssignment(c, db, member, status, "declined:"+msg.MessageSID)
+}
+
+// respondToNextAssignment records a YES or NO reply against the member's
+// next assignment waiting for one and returns the reply to send back. key
+// identifies the decline alert sent to the team leader.
+func respondToNextAssignment(c fiber.Ctx, db *mongo.Database, member *models.Member, status, key string) (string, error) {
+	event, positions, err := models.RespondToNextAssignment(db, member.ID, status, time.Now())
+	if errors.Is(err, models.ErrNoPendingAssignment) {
+		return "Thanks! You have no upcoming assignments waiting for a reply.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, pa := range positions {
+		names = append(names, pa.PositionName)
+	}
+	what := strings.Join(names, " and ") + " for " + event.Name + " on " + event.When()
+	if status == models.AssignmentConfirmed {
+		return "Thanks " + member.FirstName + ", you're confirmed for " + what + ".", nil
+	}
+
+	alertLeaderOfDecline(c, db, event, member, names, key)
+	return "Thanks for letting us know. We've told your team leader you can't make " + what + ".", nil
+}
+
+func CreateSMSRoutes(app *fiber.App, BaseRoute string) {
+	// Twilio webhook for incoming texts. Requests must carry a valid
+	// X-Twilio-Signature for the public URL of this endpoint.
+	app.Post(BaseRoute+"/inbound", func(c fiber.Ctx) error {
+		twilio, ok := fiber.GetState[*services.TwilioService](c.App().State(), "twilioService")
+		if !ok {
+			return c.Status(fiber.StatusInternalServerError).SendString("SMS is not configured")
+		}
+		baseURL, _ := fiber.GetState[string](c.App().State(), "baseURL")
+		params := formParams(c)
+		if !twilio.ValidateRequest(baseURL+c.OriginalURL(), params, c.Get("X-Twilio-Signature")) {
+			return c.Status(fiber.StatusForbidden).SendString("Invalid signature")
+		}
+
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		reply, err := handleInboundSMS(c, db, services.ParseInboundSMS(params))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error handling message")
+		}
+		c.Set("Content-Type", "text/xml")
+		return c.SendString(services.TwiMLMessage(reply))
+	})
+
+	// Twilio status callback for texts we sent. Signed like the inbound
+	// webhook.
+	app.Post(BaseRoute+"/status", func(c fiber.Ctx) error {
+		twilio, ok := fiber.GetState[*services.TwilioService](c.App().State(), "twilioService")
+		if !ok {
+			return c.Status(fiber.StatusInternalServerError).SendString("SMS is not configured")
+		}
+		baseURL, _ := fiber.GetState[string](c.App().State(), "baseURL")
+		params := formParams(c)
+		if !twilio.ValidateRequest(baseURL+c.OriginalURL(), params, c.Get("X-Twilio-Signature")) {
+			return c.Status(fiber.StatusForbidden).SendString("Invalid signature")
+		}
+
+		event, ok := services.ParseTwilioStatus(params).DeliveryEvent(time.Now())
+		if !ok {
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		if err := recordDelivery(db, event); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error recording status")
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
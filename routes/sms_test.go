@@ -0,0 +1,143 @@
+package routes
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	testAuthToken = "test-auth-token"
+	testBaseURL   = "https://scheduler.example.com"
+)
+
+// newSMSTestApp serves the SMS routes with a Twilio account whose webhooks
+// are signed with testAuthToken. db may be nil for tests that never reach
+// the database.
+func newSMSTestApp(db *mongo.Database) *fiber.App {
+	app := fiber.New()
+	app.State().Set("twilioService", services.NewTwilioService("ACtest", testAuthToken, "+15555550000"))
+	app.State().Set("baseURL", testBaseURL)
+	if db != nil {
+		app.State().Set("db", db)
+	}
+	CreateSMSRoutes(app, "/sms")
+	return app
+}
+
+// postInbound posts params to /sms/inbound as Twilio would, with the
+// signature header when given, and returns the status and body of the
+// response.
+func postInbound(t *testing.T, app *fiber.App, params url.Values, signature string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/sms/inbound", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if signature != "" {
+		req.Header.Set("X-Twilio-Signature", signature)
+	}
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return res.StatusCode, string(body)
+}
+
+// textIn sends body from the number from through the inbound webhook and
+// returns the TwiML reply.
+func textIn(t *testing.T, app *fiber.App, from, body string) string {
+	t.Helper()
+	params, signature := services.FakeInboundSMS(testAuthToken, testBaseURL+"/sms/inbound", from, "+15555550000", body)
+	status, reply := postInbound(t, app, params, signature)
+	if status != http.StatusOK {
+		t.Fatalf("texting %q: status %d: %s", body, status, reply)
+	}
+	return reply
+}
+
+func TestInboundSMSRejectsBadSignature(t *testing.T) {
+	app := newSMSTestApp(nil)
+
+	params, _ := services.FakeInboundSMS(testAuthToken, testBaseURL+"/sms/inbound", "+15555550100", "", "YES")
+	if status, _ := postInbound(t, app, params, ""); status != http.StatusForbidden {
+		t.Errorf("unsigned request: status %d, want 403", status)
+	}
+
+	// Signed with another account's token
+	_, signature := services.FakeInboundSMS("other-token", testBaseURL+"/sms/inbound", "+15555550100", "", "YES")
+	if status, _ := postInbound(t, app, params, signature); status != http.StatusForbidden {
+		t.Errorf("wrong token: status %d, want 403", status)
+	}
+
+	// Signed for another URL
+	_, signature = services.FakeInboundSMS(testAuthToken, "https://elsewhere.example.com/sms/inbound", "+15555550100", "", "YES")
+	if status, _ := postInbound(t, app, params, signature); status != http.StatusForbidden {
+		t.Errorf("wrong URL: status %d, want 403", status)
+	}
+}
+
+func TestInboundSMSReplies(t *testing.T) {
+	db := testDatabase(t)
+	app := newSMSTestApp(db)
+
+	member := models.Member{FirstName: "Jo", LastName: "Smith", PhoneNumber: "(555) 555-0100"}
+	if _, err := models.InsertMember(db, &member); err != nil {
+		t.Fatal(err)
+	}
+	tomorrow := models.Today().AddDate(0, 0, 1)
+	events := []any{
+		models.Event{ID: "first", Name: "Sunday Service", Date: tomorrow, StartAt: tomorrow.Add(9 * time.Hour),
+			PositionAssignments: []models.PositionAssignment{{ID: "cam", PositionName: "Camera", MemberID: member.ID}}},
+		models.Event{ID: "second", Name: "Sunday Service", Date: tomorrow.AddDate(0, 0, 7), StartAt: tomorrow.AddDate(0, 0, 7).Add(9 * time.Hour),
+			PositionAssignments: []models.PositionAssignment{{ID: "lights", PositionName: "Lights", MemberID: member.ID}}},
+	}
+	if _, err := db.Collection(models.EventCollection).InsertMany(context.TODO(), events); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply := textIn(t, app, "+15555550199", "YES"); !strings.Contains(reply, "don't recognize this number") {
+		t.Errorf("unknown number: reply %q", reply)
+	}
+
+	if reply := textIn(t, app, "+15555550100", "yes"); !strings.Contains(reply, "you're confirmed for Camera") {
+		t.Errorf("YES: reply %q", reply)
+	}
+	if reply := textIn(t, app, "+15555550100", "No"); !strings.Contains(reply, "can't make Lights") {
+		t.Errorf("NO: reply %q", reply)
+	}
+	assertPositionStatus(t, db, "first", "cam", models.AssignmentConfirmed)
+	assertPositionStatus(t, db, "second", "lights", models.AssignmentDeclined)
+
+	if reply := textIn(t, app, "+15555550100", "STOP"); !strings.Contains(reply, "unsubscribed") {
+		t.Errorf("STOP: reply %q", reply)
+	}
+	stopped, err := models.GetMemberByID(db, member.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stopped.OptedOut {
+		t.Error("member is not opted out after STOP")
+	}
+}
+
+func assertPositionStatus(t *testing.T, db *mongo.Database, eventID, positionID, want string) {
+	t.Helper()
+	event, err := models.GetEventByID(db, eventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pa := event.FindPosition(positionID); pa == nil || pa.Status != want {
+		t.Errorf("event %s position %s: status %+v, want %q", eventID, positionID, pa, want)
+	}
+}
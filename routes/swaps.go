@@ -0,0 +1,220 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/pages"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Signed link actions for swaps.
+const (
+	LinkSwapRequest = "swap_request"
+	LinkSwapAccept  = "swap_accept"
+)
+
+// SwapRequestURL returns the link a member uses to ask teammates to cover
+// their slot.
+func SwapRequestURL(signer *services.LinkSigner, event *models.Event, position *models.PositionAssignment) string {
+	return signer.URL("/swaps", services.LinkClaims{
+		Action:     LinkSwapRequest,
+		EventID:    event.ID,
+		PositionID: position.ID,
+		MemberID:   position.MemberID,
+		ExpiresAt:  event.LinkExpiry(),
+	})
+}
+
+// offerSwap sends each eligible teammate a link to take the slot.
+func offerSwap(c fiber.Ctx, event *models.Event, position *models.PositionAssignment, swap *models.SwapRequest, eligible []models.Member, fromName string) {
+	signer, err := GetLinkSignerFromContext(c)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	data := models.NewMessageData(nil, event, position.PositionName)
+	data.FromName = fromName
+	for i := range eligible {
+		link := signer.URL("/swaps", services.LinkClaims{
+			Action:     LinkSwapAccept,
+			EventID:    event.ID,
+			PositionID: position.ID,
+			MemberID:   eligible[i].ID,
+			RefID:      swap.ID,
+			ExpiresAt:  event.LinkExpiry(),
+		})
+		data.SwapLink = link
+		notifyMember(c, &eligible[i], models.TemplateSwapOffer, data, "swap-offer:"+swap.ID+":"+eligible[i].ID)
+	}
+}
+
+// notifySwapAccepted tells the previous holder, the new holder and the team
+// leader that a slot changed hands.
+func notifySwapAccepted(c fiber.Ctx, db *mongo.Database, event *models.Event, position *models.PositionAssignment, fromMemberID, toMemberID string) {
+	from, err := models.GetMemberByID(db, fromMemberID)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	to, err := models.GetMemberByID(db, toMemberID)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	key := "swap-accepted:" + event.ID + ":" + position.ID + ":" + to.ID + ":"
+	data := models.NewMessageData(nil, event, position.PositionName)
+	data.FromName, data.ToName = from.FullName(), to.FullName()
+	notifyMember(c, from, models.TemplateSwapCovered, data, key+from.ID)
+	notifyMember(c, to, models.TemplateSwapTaken, data, key+to.ID)
+
+	if event.TeamID == "" {
+		return
+	}
+	team, err := models.GetTeamByID(db, event.TeamID)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if leader := team.Leader(); leader != nil && leader.ID != from.ID && leader.ID != to.ID {
+		notifyMember(c, leader, models.TemplateSwapLeader, data, key+leader.ID)
+	}
+}
+
+func CreateSwapRoutes(app *fiber.App, BaseRoute string) {
+	// loadSwapLink verifies the token and loads what it points at. The
+	// returned message is shown to the member when the link can't be used.
+	loadSwapLink := func(c fiber.Ctx) (fiber.Map, *services.LinkClaims, *models.Event, *models.PositionAssignment, string) {
+		data := fiber.Map{"Title": "Swap request", "Token": c.Params("token")}
+
+		signer, err := GetLinkSignerFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return data, nil, nil, nil, "Something went wrong. Please contact your team leader."
+		}
+		claims, err := signer.Verify(c.Params("token"))
+		if errors.Is(err, services.ErrExpiredLink) {
+			return data, nil, nil, nil, "This link has expired."
+		}
+		if err != nil || (claims.Action != LinkSwapRequest && claims.Action != LinkSwapAccept) {
+			return data, nil, nil, nil, "This link is not valid."
+		}
+		data["Action"] = claims.Action
+
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return data, nil, nil, nil, "Something went wrong. Please contact your team leader."
+		}
+		event, err := models.GetEventByID(db, claims.EventID)
+		if err != nil {
+			return data, nil, nil, nil, "This event no longer exists."
+		}
+		position := event.FindPosition(claims.PositionID)
+		if position == nil {
+			return data, nil, nil, nil, "This position is no longer on the schedule."
+		}
+		member, err := models.GetMemberByID(db, claims.MemberID)
+		if err != nil {
+			return data, nil, nil, nil, "This link is not valid."
+		}
+		data["Event"] = event
+		data["Position"] = position
+		data["Member"] = member
+
+		switch claims.Action {
+		case LinkSwapRequest:
+			if position.MemberID != member.ID {
+				return data, nil, nil, nil, "You are no longer scheduled for this slot."
+			}
+			if position.OpenSwap() != nil {
+				return data, nil, nil, nil, "Your teammates have already been asked to cover this slot."
+			}
+		case LinkSwapAccept:
+			for _, swap := range position.SwapRequests {
+				if swap.ID == claims.RefID && swap.Status == models.SwapAccepted {
+					if swap.AcceptedBy == member.ID {
+						return data, nil, nil, nil, "You are already scheduled for this slot."
+					}
+					return data, nil, nil, nil, "Someone else has already taken this slot. Thanks for offering!"
+				}
+				if swap.ID == claims.RefID && swap.Status == models.SwapCancelled {
+					return data, nil, nil, nil, "This swap request was cancelled."
+				}
+			}
+		}
+		return data, claims, event, position, ""
+	}
+
+	renderSwapPage := func(c fiber.Ctx, data fiber.Map) error {
+		err := RenderFullPage(c, pages.SwapPage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	}
+
+	// Swap link landing page. Members follow these links from SMS or email
+	// without logging in; the signed token is their authorization.
+	app.Get(BaseRoute+"/:token", func(c fiber.Ctx) error {
+		data, _, _, _, message := loadSwapLink(c)
+		data["Message"] = message
+		return renderSwapPage(c, data)
+	})
+
+	// Act on a swap link
+	app.Post(BaseRoute+"/:token", func(c fiber.Ctx) error {
+		data, claims, event, position, message := loadSwapLink(c)
+		if claims == nil {
+			data["Message"] = message
+			return renderSwapPage(c, data)
+		}
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		member := data["Member"].(*models.Member)
+
+		switch claims.Action {
+		case LinkSwapRequest:
+			swap, eligible, err := models.OpenSwapRequest(db, event.ID, position.ID, member.FullName())
+			if err != nil {
+				log.Print(err)
+				data["Message"] = "We couldn't open a swap for this slot."
+				return renderSwapPage(c, data)
+			}
+			offerSwap(c, event, position, swap, eligible, member.FullName())
+			if len(eligible) == 0 {
+				data["Done"] = "Nobody on your team is free to cover this slot. Please contact your team leader."
+			} else {
+				data["Done"] = "We've asked your teammates to cover this slot. You'll hear back once someone accepts."
+			}
+		case LinkSwapAccept:
+			err := models.AcceptSwapRequest(db, event.ID, position.ID, claims.RefID, member.ID)
+			if errors.Is(err, models.ErrSwapClosed) {
+				data["Message"] = "Someone else has already taken this slot. Thanks for offering!"
+				return renderSwapPage(c, data)
+			}
+			if errors.Is(err, models.ErrSwapNotEligible) {
+				reason := strings.TrimPrefix(err.Error(), models.ErrSwapNotEligible.Error()+": ")
+				data["Message"] = "We can't give you this slot: " + reason
+				return renderSwapPage(c, data)
+			}
+			if err != nil {
+				log.Print(err)
+				data["Message"] = "We couldn't complete this swap."
+				return renderSwapPage(c, data)
+			}
+			notifySwapAccepted(c, db, event, position, position.MemberID, member.ID)
+			data["Done"] = "Thanks! You're now scheduled for this slot."
+		}
+		return renderSwapPage(c, data)
+	})
+}
@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestSwapAcceptRechecksMember(t *testing.T) {
+	db := testDatabase(t)
+	signer := services.NewLinkSigner("link-secret", "")
+	app := fiber.New()
+	app.State().Set("db", db)
+	app.State().Set("linkSigner", signer)
+	CreateSwapRoutes(app, "/swaps")
+
+	jo := models.Member{FirstName: "Jo", LastName: "Smith"}
+	sam := models.Member{FirstName: "Sam", LastName: "Lee"}
+	for _, m := range []*models.Member{&jo, &sam} {
+		if _, err := models.InsertMember(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := models.Today().AddDate(0, 0, 3)
+	event := models.Event{ID: "service", Name: "Sunday Service", Date: day,
+		PositionAssignments: []models.PositionAssignment{{
+			ID: "cam", PositionName: "Camera", MemberID: jo.ID,
+			SwapRequests: []models.SwapRequest{{ID: "swap", FromMemberID: jo.ID, Status: models.SwapOpen, OfferedTo: []string{sam.ID}, CreatedAt: time.Now()}},
+		}}}
+	if _, err := db.Collection(models.EventCollection).InsertOne(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	// Sam blocked the day out after the swap was offered
+	if _, err := models.AddAvailabilityRule(db, sam.ID, models.AvailabilityRule{Kind: models.AvailabilityBlackout, StartDate: day, EndDate: day, Reason: "Out of town"}); err != nil {
+		t.Fatal(err)
+	}
+
+	token := signer.Sign(services.LinkClaims{Action: LinkSwapAccept, EventID: "service", PositionID: "cam", MemberID: sam.ID, RefID: "swap", ExpiresAt: time.Now().Add(time.Hour)})
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/swaps/"+token, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if !strings.Contains(string(body), "We can&#39;t give you this slot: Sam Lee is unavailable") || !strings.Contains(string(body), "Out of town") {
+		t.Errorf("response does not give the reason:\n%s", body)
+	}
+
+	saved, err := models.GetEventByID(db, "service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	position := saved.FindPosition("cam")
+	if position.MemberID != jo.ID || position.OpenSwap() == nil {
+		t.Errorf("slot is held by %q with open swap %v, want Jo's and still open", position.MemberID, position.OpenSwap())
+	}
+}
@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"slices"
+
 	"github.com/bcrowe306/nltst_scheduler.git/models"
 	"github.com/bcrowe306/nltst_scheduler.git/pages"
 	"github.com/gofiber/fiber/v3"
@@ -119,8 +121,22 @@ func CreateTeamsRoutes(app *fiber.App, BaseRoute string) {
 			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching members")
 		}
 
+		positions, err := models.GetTeamPositionNames(db, teamID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching positions")
+		}
+
+		qualifications, err := models.GetQualificationMatrix(db, teamID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching qualifications")
+		}
+
 		data["Team"] = team
 		data["Members"] = members
+		data["Positions"] = positions
+		data["Qualifications"] = qualifications
 
 		err = Render(c, pages.TeamEditPage(data))
 		if err != nil {
@@ -149,6 +165,159 @@ func CreateTeamsRoutes(app *fiber.App, BaseRoute string) {
 		return c.Redirect().To(BaseRoute + "/" + teamID)
 	})
 
+	// Team schedule grid
+	app.Get(BaseRoute+"/:id/schedule", Protected, func(c fiber.Ctx) error {
+		data := GetDefaultTemplateData(c, "Team Schedule", BaseRoute)
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		teamID := c.Params("id")
+
+		team, err := models.GetTeamByID(db, teamID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching team")
+		}
+		events, err := models.GetUpcomingTeamEvents(db, teamID, models.Today())
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching events")
+		}
+		qualifications, err := models.GetQualificationMatrix(db, teamID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching qualifications")
+		}
+
+		// Leaders can narrow the grid to events still waiting on replies
+		unconfirmed := c.Query("unconfirmed") != ""
+		if unconfirmed {
+			events = slices.DeleteFunc(events, func(e models.Event) bool { return e.UnconfirmedCount() == 0 })
+		}
+
+		data["Team"] = team
+		data["Grid"] = models.NewTeamScheduleGrid(events)
+		data["Unconfirmed"] = unconfirmed
+		data["Qualifications"] = qualifications
+
+		err = RenderHTMXPage(c, pages.TeamSchedulePage(data))
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error rendering template")
+		}
+		return nil
+	})
+
+	// Assign a member from the team schedule grid. Responds with the
+	// updated grid cell.
+	app.Post(BaseRoute+"/:id/schedule/assign", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		teamID := c.Params("id")
+		eventID := c.FormValue("eventId")
+		positionID := c.FormValue("positionId")
+		memberID := c.FormValue("memberId")
+
+		team, err := models.GetTeamByID(db, teamID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching team")
+		}
+		event, err := models.GetEventByID(db, eventID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching event")
+		}
+		slot := event.FindPosition(positionID)
+		if event.TeamID != teamID || slot == nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Position is not on this team's schedule")
+		}
+		qualifications, err := models.GetQualificationMatrix(db, teamID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching qualifications")
+		}
+
+		problem := ""
+		if memberID != "" {
+			member, err := models.GetMemberByID(db, memberID)
+			if err != nil {
+				log.Print(err)
+				return c.Status(fiber.StatusInternalServerError).SendString("Error fetching member")
+			}
+			problem, err = models.CheckAssignment(db, event, positionID, member, c.FormValue("override") == "on")
+			if err != nil {
+				log.Print(err)
+				return c.Status(fiber.StatusInternalServerError).SendString("Error checking assignment")
+			}
+		}
+
+		if problem == "" {
+			_, err = models.AssignPositionToMember(db, eventID, positionID, memberID)
+			if err != nil {
+				log.Print(err)
+				return c.Status(fiber.StatusInternalServerError).SendString("Error assigning position to member")
+			}
+			if slot.MemberID != memberID {
+				slot.Status = ""
+			}
+			slot.MemberID = memberID
+		}
+
+		return RenderFullPage(c, pages.TeamScheduleCell(team, *event, *slot, qualifications, problem))
+	})
+
+	// Set a member's qualification for a position
+	app.Post(BaseRoute+"/:id/qualifications", Protected, func(c fiber.Ctx) error {
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		teamID := c.Params("id")
+
+		var form models.Qualification
+		if err := c.Bind().Form(&form); err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid form data")
+		}
+		if form.MemberID == "" || form.PositionName == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("Member and position are required")
+		}
+		if form.Level != "" && !slices.Contains(models.QualificationLevels, form.Level) {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid qualification level")
+		}
+
+		err = models.SetQualification(db, teamID, form.MemberID, form.PositionName, form.Level)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error saving qualification")
+		}
+
+		team, err := models.GetTeamByID(db, teamID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching team")
+		}
+		positions, err := models.GetTeamPositionNames(db, teamID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching positions")
+		}
+		qualifications, err := models.GetQualificationMatrix(db, teamID)
+		if err != nil {
+			log.Print(err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error fetching qualifications")
+		}
+
+		return RenderFullPage(c, pages.QualificationMatrix(team, positions, qualifications))
+	})
+
 	// Edit Team details route
 	app.Post(BaseRoute+"/:id/edit", Protected, func(c fiber.Ctx) error {
 		db, err := GetDatabaseFromContext(c)
@@ -161,6 +330,7 @@ func CreateTeamsRoutes(app *fiber.App, BaseRoute string) {
 		type EditTeamForm struct {
 			Name        string `form:"name"`
 			Description string `form:"description"`
+			LeaderID    string `form:"leaderId"`
 		}
 		var form EditTeamForm
 		if err := c.Bind().Form(&form); err != nil {
@@ -172,6 +342,7 @@ func CreateTeamsRoutes(app *fiber.App, BaseRoute string) {
 			ID:          teamID,
 			Name:        form.Name,
 			Description: form.Description,
+			LeaderID:    form.LeaderID,
 		}
 
 		_, err = models.UpdateTeam(db, teamID, team)
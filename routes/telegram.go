@@ -0,0 +1,207 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const telegramHelp = "Send /next to see your upcoming assignments, or YES or NO to answer your next reminder. " +
+	"Send /stop to stop getting messages here."
+
+const telegramNotLinked = "This chat isn't linked to a member yet. Open the Telegram link from your member page to connect it."
+
+// handleTelegramUpdate acts on a message or button press sent to the bot.
+func handleTelegramUpdate(c fiber.Ctx, db *mongo.Database, tg *services.TelegramService, update services.TelegramUpdate) error {
+	if q := update.CallbackQuery; q != nil {
+		answer, reply, err := handleTelegramButton(c, db, q)
+		if err != nil {
+			return err
+		}
+		if err := tg.AnswerCallback(q.ID, answer); err != nil {
+			log.Print(err)
+		}
+		if reply != "" {
+			_, err = tg.SendMessage(q.ChatID(), reply, nil)
+		}
+		return err
+	}
+	if msg := update.Message; msg != nil && msg.Text != "" {
+		chatID := strconv.FormatInt(msg.Chat.ID, 10)
+		reply, actions, err := handleTelegramMessage(c, db, chatID, msg)
+		if err != nil {
+			return err
+		}
+		if reply != "" {
+			_, err = tg.SendMessage(chatID, reply, actions)
+		}
+		return err
+	}
+	return nil
+}
+
+// handleTelegramMessage answers a text sent to the bot. "/start code" links
+// the chat to the member the code was made for.
+func handleTelegramMessage(c fiber.Ctx, db *mongo.Database, chatID string, msg *services.TelegramMessage) (string, []services.Action, error) {
+	command, arg, _ := strings.Cut(strings.TrimSpace(msg.Text), " ")
+	// Commands in groups carry the bot's name, e.g. /next@our_bot
+	command, _, _ = strings.Cut(strings.ToLower(command), "@")
+	arg = strings.TrimSpace(arg)
+
+	if command == "/start" && arg != "" {
+		username := ""
+		if msg.From != nil {
+			username = msg.From.Username
+		}
+		member, err := models.LinkTelegramChat(db, arg, chatID, username, time.Now())
+		if errors.Is(err, models.ErrTelegramLinkInvalid) {
+			return "This link has expired or was already used. Ask your team leader for a new one.", nil, nil
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return "Hi " + member.FirstName + ", your Telegram account is linked. You'll get schedule messages here.\n\n" + telegramHelp, nil, nil
+	}
+
+	member, err := models.GetMemberByTelegramChat(db, chatID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return telegramNotLinked, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch {
+	case command == "/next" || strings.Contains(strings.ToLower(msg.Text), "next assignment"):
+		return nextAssignmentsReply(db, member)
+	case command == "/stop":
+		if err := models.UnlinkTelegram(db, member.ID, models.ConsentSourceTelegram, "Sent /stop"); err != nil {
+			return "", nil, err
+		}
+		return "You won't get messages here any more. Ask your team leader for a new link to reconnect.", nil, nil
+	case command == "/start" || command == "/help":
+		return "Hi " + member.FirstName + ". " + telegramHelp, nil, nil
+	}
+
+	if status, ok := models.ParseConfirmationReply(msg.Text); ok {
+		key := "declined:telegram:" + chatID + ":" + strconv.FormatInt(msg.MessageID, 10)
+		reply, err := respondToNextAssignment(c, db, member, status, key)
+		return reply, nil, err
+	}
+	return telegramHelp, nil, nil
+}
+
+// nextAssignmentsReply lists the member's upcoming assignments, with
+// buttons for any still waiting for a reply.
+func nextAssignmentsReply(db *mongo.Database, member *models.Member) (string, []services.Action, error) {
+	events, err := models.GetUpcomingAssignments(db, member.ID, time.Now(), 5)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(events) == 0 {
+		return "You have no upcoming assignments.", nil, nil
+	}
+
+	lines := []string{"Your next assignments:"}
+	var actions []services.Action
+	for _, event := range events {
+		for _, pa := range event.PositionAssignments {
+			if pa.MemberID != member.ID {
+				continue
+			}
+			status := "waiting for your reply"
+			switch pa.Status {
+			case models.AssignmentConfirmed:
+				status = "confirmed"
+			case models.AssignmentDeclined:
+				status = "declined"
+			default:
+				label := pa.PositionName + " " + event.Date.Format("Jan 2")
+				actions = append(actions,
+					services.Action{Label: "Confirm " + label, Data: models.ActionConfirmPrefix + pa.ID},
+					services.Action{Label: "Can't make " + label, Data: models.ActionDeclinePrefix + pa.ID})
+			}
+			lines = append(lines, "- "+event.When()+": "+pa.PositionName+" for "+event.Name+" ("+status+")")
+		}
+	}
+	return strings.Join(lines, "\n"), actions, nil
+}
+
+// handleTelegramButton records a confirm or decline button press. It
+// returns the short answer shown on the button and a fuller reply for the
+// chat.
+func handleTelegramButton(c fiber.Ctx, db *mongo.Database, q *services.TelegramCallbackQuery) (string, string, error) {
+	status, positionID, ok := models.ParseAssignmentAction(q.Data)
+	if !ok {
+		return "This button no longer works.", "", nil
+	}
+	member, err := models.GetMemberByTelegramChat(db, q.ChatID())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "Not linked", telegramNotLinked, nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	event, err := models.GetEventByPositionID(db, positionID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "This slot is no longer on the schedule.", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	position := event.FindPosition(positionID)
+	if position == nil {
+		return "This slot is no longer on the schedule.", "", nil
+	}
+
+	err = models.RespondToAssignment(db, event.ID, positionID, member.ID, status, time.Now())
+	if errors.Is(err, models.ErrAssignmentChanged) {
+		return "You're no longer scheduled for this slot.", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	what := position.PositionName + " for " + event.Name + " on " + event.When()
+	if status == models.AssignmentConfirmed {
+		return "Confirmed", "Thanks " + member.FirstName + ", you're confirmed for " + what + ".", nil
+	}
+	alertLeaderOfDecline(c, db, event, member, []string{position.PositionName}, "declined:"+position.ID+":"+member.ID+":"+q.ID)
+	return "Declined", "Thanks for letting us know. We've told your team leader you can't make " + what + ".", nil
+}
+
+func CreateTelegramRoutes(app *fiber.App, BaseRoute string) {
+	// Telegram bot webhook. Requests must carry the secret token the
+	// webhook was registered with.
+	app.Post(BaseRoute+"/webhook", func(c fiber.Ctx) error {
+		tg, ok := fiber.GetState[*services.TelegramService](c.App().State(), "telegramService")
+		if !ok || !tg.Configured() {
+			return c.Status(fiber.StatusNotFound).SendString("Telegram is not configured")
+		}
+		if !tg.ValidateRequest(c.Get("X-Telegram-Bot-Api-Secret-Token")) {
+			return c.Status(fiber.StatusForbidden).SendString("Invalid secret token")
+		}
+		update, err := services.ParseTelegramUpdate(c.Body())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid update")
+		}
+
+		db, err := GetDatabaseFromContext(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Database connection error")
+		}
+		// Telegram retries updates that fail, which would repeat replies
+		// already sent; log problems and acknowledge the update instead
+		if err := handleTelegramUpdate(c, db, tg, update); err != nil {
+			log.Print("Error handling Telegram update ", update.UpdateID, ": ", err)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+}
@@ -0,0 +1,218 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const testWebhookSecret = "hook-secret"
+
+// botAPI is an httptest stand-in for the Telegram Bot API that records
+// the methods called and their parameters.
+type botAPI struct {
+	calls []botAPICall
+}
+
+type botAPICall struct {
+	Method string
+	Params map[string]any
+}
+
+// find returns the params of the first call to method, or nil.
+func (b *botAPI) find(method string) map[string]any {
+	for _, call := range b.calls {
+		if call.Method == method {
+			return call.Params
+		}
+	}
+	return nil
+}
+
+// newTelegramTestApp serves the Telegram routes with a bot whose API calls
+// go to the returned stub. db may be nil for tests that never reach the
+// database.
+func newTelegramTestApp(t *testing.T, db *mongo.Database) (*fiber.App, *botAPI) {
+	bot := &botAPI{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]any
+		json.NewDecoder(r.Body).Decode(&params)
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		bot.calls = append(bot.calls, botAPICall{Method: method, Params: params})
+		result := any(true)
+		if method == "sendMessage" {
+			result = map[string]any{"message_id": len(bot.calls)}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+	}))
+	t.Cleanup(server.Close)
+
+	app := fiber.New()
+	app.State().Set("telegramService", services.NewTelegramService("123:test", server.URL, "church_bot", testWebhookSecret))
+	if db != nil {
+		app.State().Set("db", db)
+	}
+	CreateTelegramRoutes(app, "/telegram")
+	return app, bot
+}
+
+// postUpdate posts a webhook update with secret and returns the status.
+func postUpdate(t *testing.T, app *fiber.App, update, secret string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(update))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+// sendText posts a text message from chatID to the bot and returns the
+// bot's reply.
+func sendText(t *testing.T, app *fiber.App, bot *botAPI, chatID int64, text string) string {
+	t.Helper()
+	bot.calls = nil
+	update := fmt.Sprintf(`{"update_id":1,"message":{"message_id":1,"from":{"id":%d,"username":"jo"},"chat":{"id":%d},"text":%q}}`, chatID, chatID, text)
+	if status := postUpdate(t, app, update, testWebhookSecret); status != http.StatusOK {
+		t.Fatalf("sending %q: status %d", text, status)
+	}
+	sent := bot.find("sendMessage")
+	if sent == nil {
+		t.Fatalf("sending %q: bot did not reply", text)
+	}
+	if sent["chat_id"] != fmt.Sprint(chatID) {
+		t.Errorf("sending %q: reply went to chat %v", text, sent["chat_id"])
+	}
+	return sent["text"].(string)
+}
+
+// pressButton posts a press of a button carrying data in chatID and
+// returns the callback answer and the reply sent to the chat, if any.
+func pressButton(t *testing.T, app *fiber.App, bot *botAPI, chatID int64, data string) (string, string) {
+	t.Helper()
+	bot.calls = nil
+	update := fmt.Sprintf(`{"update_id":2,"callback_query":{"id":"cb-1","from":{"id":%d},"message":{"message_id":9,"chat":{"id":%d}},"data":%q}}`, chatID, chatID, data)
+	if status := postUpdate(t, app, update, testWebhookSecret); status != http.StatusOK {
+		t.Fatalf("pressing %q: status %d", data, status)
+	}
+	answer := bot.find("answerCallbackQuery")
+	if answer == nil || answer["callback_query_id"] != "cb-1" {
+		t.Fatalf("pressing %q: callback not answered: %+v", data, bot.calls)
+	}
+	reply := ""
+	if sent := bot.find("sendMessage"); sent != nil {
+		reply = sent["text"].(string)
+	}
+	return answer["text"].(string), reply
+}
+
+func TestTelegramWebhookRejectsBadSecret(t *testing.T) {
+	app, bot := newTelegramTestApp(t, nil)
+	update := `{"update_id":1,"message":{"message_id":1,"chat":{"id":4242},"text":"/next"}}`
+
+	if status := postUpdate(t, app, update, ""); status != http.StatusForbidden {
+		t.Errorf("no secret: status %d, want 403", status)
+	}
+	if status := postUpdate(t, app, update, "wrong"); status != http.StatusForbidden {
+		t.Errorf("wrong secret: status %d, want 403", status)
+	}
+	if len(bot.calls) != 0 {
+		t.Errorf("bot was called for a rejected update: %+v", bot.calls)
+	}
+}
+
+func TestTelegramStartLinksChat(t *testing.T) {
+	db := testDatabase(t)
+	app, bot := newTelegramTestApp(t, db)
+
+	member := models.Member{FirstName: "Jo", LastName: "Smith"}
+	if _, err := models.InsertMember(db, &member); err != nil {
+		t.Fatal(err)
+	}
+	code, err := models.CreateTelegramLinkCode(db, member.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reply := sendText(t, app, bot, 4242, "/next"); reply != telegramNotLinked {
+		t.Errorf("before linking: reply %q", reply)
+	}
+	if reply := sendText(t, app, bot, 4242, "/start "+code); !strings.Contains(reply, "Hi Jo, your Telegram account is linked") {
+		t.Errorf("/start: reply %q", reply)
+	}
+	linked, err := models.GetMemberByTelegramChat(db, "4242")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked.ID != member.ID || linked.TelegramUsername != "jo" {
+		t.Errorf("chat linked to %s (%q), want %s", linked.ID, linked.TelegramUsername, member.ID)
+	}
+
+	// The code only works once
+	if reply := sendText(t, app, bot, 5151, "/start "+code); !strings.Contains(reply, "expired or was already used") {
+		t.Errorf("reused code: reply %q", reply)
+	}
+	if reply := sendText(t, app, bot, 4242, "/next"); reply != "You have no upcoming assignments." {
+		t.Errorf("/next: reply %q", reply)
+	}
+}
+
+func TestTelegramButtonsAnswerAssignments(t *testing.T) {
+	db := testDatabase(t)
+	app, bot := newTelegramTestApp(t, db)
+
+	member := models.Member{FirstName: "Jo", LastName: "Smith"}
+	if _, err := models.InsertMember(db, &member); err != nil {
+		t.Fatal(err)
+	}
+	code, err := models.CreateTelegramLinkCode(db, member.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.LinkTelegramChat(db, code, "4242", "jo", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	tomorrow := models.Today().AddDate(0, 0, 1)
+	event := models.Event{ID: "service", Name: "Sunday Service", Date: tomorrow, StartAt: tomorrow.Add(9 * time.Hour),
+		PositionAssignments: []models.PositionAssignment{
+			{ID: "cam", PositionName: "Camera", MemberID: member.ID},
+			{ID: "lights", PositionName: "Lights", MemberID: member.ID},
+		}}
+	if _, err := db.Collection(models.EventCollection).InsertOne(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	answer, reply := pressButton(t, app, bot, 4242, models.ActionConfirmPrefix+"cam")
+	if answer != "Confirmed" || !strings.Contains(reply, "you're confirmed for Camera for Sunday Service") {
+		t.Errorf("confirm: answer %q, reply %q", answer, reply)
+	}
+	answer, reply = pressButton(t, app, bot, 4242, models.ActionDeclinePrefix+"lights")
+	if answer != "Declined" || !strings.Contains(reply, "can't make Lights for Sunday Service") {
+		t.Errorf("decline: answer %q, reply %q", answer, reply)
+	}
+	assertPositionStatus(t, db, "service", "cam", models.AssignmentConfirmed)
+	assertPositionStatus(t, db, "service", "lights", models.AssignmentDeclined)
+
+	if answer, reply := pressButton(t, app, bot, 7777, models.ActionConfirmPrefix+"cam"); answer != "Not linked" || reply != telegramNotLinked {
+		t.Errorf("unlinked chat: answer %q, reply %q", answer, reply)
+	}
+	if answer, reply := pressButton(t, app, bot, 4242, models.ActionConfirmPrefix+"gone"); answer != "This slot is no longer on the schedule." || reply != "" {
+		t.Errorf("removed slot: answer %q, reply %q", answer, reply)
+	}
+	if answer, _ := pressButton(t, app, bot, 4242, "bogus"); answer != "This button no longer works." {
+		t.Errorf("unknown button: answer %q", answer)
+	}
+}
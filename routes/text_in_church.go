@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"log"
+	"sync"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// textInChurchContact is the TextInChurch contact record for member.
+func textInChurchContact(member *models.Member) services.TextInChurchContact {
+	phone := member.PhoneNormalized
+	if phone == "" {
+		phone = member.PhoneNumber
+	}
+	return services.TextInChurchContact{
+		ID:         member.TextInChurchID,
+		ExternalID: member.ID,
+		FirstName:  member.FirstName,
+		LastName:   member.LastName,
+		Phone:      phone,
+		Email:      member.Email,
+		OptedOut:   member.OptedOut || member.SMSOptInAt == nil,
+	}
+}
+
+// textInChurchSyncs runs background syncs one at a time, so quick edits to
+// the same member can't race and create two contacts for them.
+var textInChurchSyncs sync.Mutex
+
+// syncMemberToTextInChurch pushes a member's contact details to
+// TextInChurch in the background when it is configured, so a slow API never
+// holds up the request that triggered it. Failures are logged rather than
+// returned so a sync problem never undoes the change that triggered it.
+func syncMemberToTextInChurch(c fiber.Ctx, db *mongo.Database, memberID string) {
+	tic, ok := fiber.GetState[*services.TextInChurchService](c.App().State(), "textInChurchService")
+	if !ok || !tic.Configured() {
+		return
+	}
+	go func() {
+		textInChurchSyncs.Lock()
+		defer textInChurchSyncs.Unlock()
+		// Read the member when the sync runs, so it sends the latest details
+		member, err := models.GetMemberByID(db, memberID)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		if err := syncTextInChurchContact(db, tic, member); err != nil {
+			log.Print("Error syncing ", member.FullName(), " to TextInChurch: ", err)
+		}
+	}()
+}
+
+func syncTextInChurchContact(db *mongo.Database, tic *services.TextInChurchService, member *models.Member) error {
+	if member.PhoneNumber == "" && member.Email == "" {
+		return nil
+	}
+	contact, err := tic.SyncContact(textInChurchContact(member))
+	if err != nil {
+		return err
+	}
+	if contact.ID != "" && contact.ID != member.TextInChurchID {
+		return models.SetMemberTextInChurchID(db, member.ID, contact.ID)
+	}
+	return nil
+}
+
+// syncAllToTextInChurch pushes every member to TextInChurch, returning how
+// many were synced and how many failed.
+func syncAllToTextInChurch(db *mongo.Database, tic *services.TextInChurchService) (int, int, error) {
+	members, err := models.GetAllMembers(db)
+	if err != nil {
+		return 0, 0, err
+	}
+	textInChurchSyncs.Lock()
+	defer textInChurchSyncs.Unlock()
+	synced, failed := 0, 0
+	for i := range members {
+		if err := syncTextInChurchContact(db, tic, &members[i]); err != nil {
+			log.Print("Error syncing ", members[i].FullName(), " to TextInChurch: ", err)
+			failed++
+			continue
+		}
+		synced++
+	}
+	return synced, failed, nil
+}
@@ -34,6 +34,12 @@ func NewClickSendService(username, apiKey, fromNumber string) *ClickSendService
 }
 
 func (s *ClickSendService) SendSMSCS(to string, body string) error {
+	_, err := s.SendSMS(to, body)
+	return err
+}
+
+// SendSMS sends body to the number to and returns the ClickSend message ID.
+func (s *ClickSendService) SendSMS(to string, body string) (string, error) {
 	reqUrl := s.BaseURL + "/sms/send"
 	messages := []CSMessage{
 		{
@@ -47,12 +53,12 @@ func (s *ClickSendService) SendSMSCS(to string, body string) error {
 		"messages": messages,
 	})
 	if err != nil {
-		return fmt.Errorf("Error marshaling JSON payload: %w", err)
+		return "", fmt.Errorf("Error marshaling JSON payload: %w", err)
 	}
 
 	req, err := http.NewRequest("POST", reqUrl, bytes.NewBuffer(payload))
 	if err != nil {
-		return fmt.Errorf("Error creating HTTP request: %w", err)
+		return "", fmt.Errorf("Error creating HTTP request: %w", err)
 	}
 
 	req.SetBasicAuth(s.Username, s.APIKey)
@@ -60,19 +66,50 @@ func (s *ClickSendService) SendSMSCS(to string, body string) error {
 
 	res, err := s.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("Error sending HTTP request: %w", err)
+		return "", fmt.Errorf("Error sending HTTP request: %w", err)
 	}
 	defer res.Body.Close()
 
 	bodyBytes, err := io.ReadAll(res.Body)
 	if err != nil {
-		return fmt.Errorf("Error reading response body: %w", err)
+		return "", fmt.Errorf("Error reading response body: %w", err)
 	}
 
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return fmt.Errorf("Error response from ClickSend: %s", string(bodyBytes))
+		return "", fmt.Errorf("Error response from ClickSend: %s", string(bodyBytes))
+	}
+
+	// A 200 response can still reject individual messages.
+	var result csResponse
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return "", fmt.Errorf("Error decoding ClickSend response: %w", err)
+	}
+	if len(result.Data.Messages) == 0 {
+		return "", fmt.Errorf("ClickSend accepted no messages")
+	}
+	sent := result.Data.Messages[0]
+	if sent.Status != "SUCCESS" {
+		return "", fmt.Errorf("ClickSend rejected message: %s", sent.Status)
 	}
+	return sent.MessageID, nil
+}
+
+type csResponse struct {
+	Data struct {
+		Messages []struct {
+			MessageID string `json:"message_id"`
+			Status    string `json:"status"`
+		} `json:"messages"`
+	} `json:"data"`
+}
+
+func (s *ClickSendService) Name() string     { return "clicksend" }
+func (s *ClickSendService) Channel() Channel { return ChannelSMS }
+
+func (s *ClickSendService) Configured() bool {
+	return s.Username != "" && s.APIKey != "" && s.FromNumber != ""
+}
 
-	fmt.Println("Response from ClickSend:", string(bodyBytes))
-	return nil
+func (s *ClickSendService) Send(msg Message) (string, error) {
+	return s.SendSMS(msg.To, msg.Body)
 }
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FakeProvider records messages instead of delivering them, so the
+// notification path can be exercised without any external service. Sent
+// messages are kept in memory and, when File is set, appended to it as
+// JSON lines.
+type FakeProvider struct {
+	name    string
+	channel Channel
+	File    string
+	// Fail, when set, is returned by Send in place of delivering, to
+	// exercise failover.
+	Fail error
+
+	mu   sync.Mutex
+	sent []FakeMessage
+}
+
+// FakeMessage is a message accepted by a FakeProvider.
+type FakeMessage struct {
+	ID       string    `json:"id"`
+	Provider string    `json:"provider"`
+	Channel  Channel   `json:"channel"`
+	To       string    `json:"to"`
+	Subject  string    `json:"subject,omitempty"`
+	Body     string    `json:"body"`
+	HTML     string    `json:"html,omitempty"`
+	SentAt   time.Time `json:"sentAt"`
+}
+
+func NewFakeProvider(channel Channel, file string) *FakeProvider {
+	return &FakeProvider{
+		name:    "fake",
+		channel: channel,
+		File:    file,
+	}
+}
+
+func (p *FakeProvider) Name() string     { return p.name }
+func (p *FakeProvider) Channel() Channel { return p.channel }
+func (p *FakeProvider) Configured() bool { return true }
+
+func (p *FakeProvider) Send(msg Message) (string, error) {
+	if p.Fail != nil {
+		return "", p.Fail
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sent := FakeMessage{
+		ID:       fmt.Sprintf("fake-%s-%d", p.channel, len(p.sent)+1),
+		Provider: p.name,
+		Channel:  msg.Channel,
+		To:       msg.To,
+		Subject:  msg.Subject,
+		Body:     msg.Body,
+		HTML:     msg.HTML,
+		SentAt:   time.Now(),
+	}
+	if p.File != "" {
+		line, err := json.Marshal(sent)
+		if err != nil {
+			return "", err
+		}
+		f, err := os.OpenFile(p.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return "", fmt.Errorf("Error opening fake provider file: %w", err)
+		}
+		defer f.Close()
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			return "", fmt.Errorf("Error writing fake provider file: %w", err)
+		}
+	}
+	p.sent = append(p.sent, sent)
+	return sent.ID, nil
+}
+
+// Sent returns the messages accepted so far.
+func (p *FakeProvider) Sent() []FakeMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]FakeMessage(nil), p.sent...)
+}
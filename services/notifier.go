@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Channel is a way of reaching a person.
+type Channel string
+
+const (
+	ChannelSMS      Channel = "sms"
+	ChannelEmail    Channel = "email"
+	ChannelTelegram Channel = "telegram"
+)
+
+// ErrNoProvider is returned when a message is sent on a channel that has no
+// configured provider.
+var ErrNoProvider = errors.New("no provider is configured for this channel")
+
+// Message is a single notification to one recipient. To is a phone number
+// for SMS, an address for email and a chat ID for Telegram; Subject, HTML,
+// Actions and Attachments are ignored by channels that don't support them.
+type Message struct {
+	Channel     Channel
+	To          string
+	Subject     string
+	Body        string
+	HTML        string
+	Actions     []Action
+	Attachments []Attachment
+}
+
+// Attachment is a file sent with an email.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Action is a button offered with a message. Data comes back to the app
+// when the recipient presses it.
+type Action struct {
+	Label string
+	Data  string
+}
+
+// Provider delivers messages on one channel through an external service.
+type Provider interface {
+	// Name identifies the provider in config and delivery records.
+	Name() string
+	Channel() Channel
+	// Configured reports whether the provider has the credentials it needs.
+	Configured() bool
+	// Send delivers msg and returns the provider's ID for it.
+	Send(msg Message) (string, error)
+}
+
+// Receipt records which provider accepted a message.
+type Receipt struct {
+	Provider   string
+	ProviderID string
+}
+
+// Notifier sends messages through the providers configured for each
+// channel. When a channel has several providers they are tried in order,
+// so a later provider takes over when an earlier one fails.
+type Notifier struct {
+	providers map[Channel][]Provider
+}
+
+// NewNotifier builds a notifier from providers in failover order.
+// Providers that are not configured are skipped.
+func NewNotifier(providers ...Provider) *Notifier {
+	n := &Notifier{providers: map[Channel][]Provider{}}
+	for _, p := range providers {
+		if p == nil {
+			continue
+		}
+		if !p.Configured() {
+			log.Printf("Notification provider %s is not configured and will not be used", p.Name())
+			continue
+		}
+		n.providers[p.Channel()] = append(n.providers[p.Channel()], p)
+	}
+	return n
+}
+
+// Enabled reports whether any provider can send on channel.
+func (n *Notifier) Enabled(channel Channel) bool {
+	return len(n.providers[channel]) > 0
+}
+
+// ChannelNames lists the channels that have a provider.
+func (n *Notifier) ChannelNames() []string {
+	var names []string
+	for _, channel := range []Channel{ChannelSMS, ChannelEmail, ChannelTelegram} {
+		if n.Enabled(channel) {
+			names = append(names, string(channel))
+		}
+	}
+	return names
+}
+
+// Send delivers msg through the first provider for its channel that
+// accepts it. If every provider fails, their errors are joined.
+func (n *Notifier) Send(msg Message) (Receipt, error) {
+	providers := n.providers[msg.Channel]
+	if len(providers) == 0 {
+		return Receipt{}, fmt.Errorf("%w: %s", ErrNoProvider, msg.Channel)
+	}
+	var errs []error
+	for _, p := range providers {
+		id, err := p.Send(msg)
+		if err == nil {
+			return Receipt{Provider: p.Name(), ProviderID: id}, nil
+		}
+		log.Printf("Provider %s failed to send %s: %v", p.Name(), msg.Channel, err)
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	return Receipt{}, errors.Join(errs...)
+}
+
+// Final delivery statuses reported by providers after a message was
+// accepted.
+const (
+	DeliveryDelivered   = "delivered"
+	DeliveryUndelivered = "undelivered"
+	DeliveryBounced     = "bounced"
+	DeliverySpam        = "spam"
+)
+
+// DeliveryEvent is a provider's report of what became of a message it
+// accepted. ProviderID matches the ID Send returned.
+type DeliveryEvent struct {
+	Provider   string
+	ProviderID string
+	Status     string
+	Detail     string
+	At         time.Time
+}
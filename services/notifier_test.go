@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// namedFake returns a fake SMS provider called name, failing with fail.
+func namedFake(name string, fail error) *FakeProvider {
+	p := NewFakeProvider(ChannelSMS, "")
+	p.name = name
+	p.Fail = fail
+	return p
+}
+
+func TestNotifierFallsBackInOrder(t *testing.T) {
+	first := namedFake("first", errors.New("first is down"))
+	second := namedFake("second", nil)
+	third := namedFake("third", nil)
+	notifier := NewNotifier(first, second, third)
+
+	receipt, err := notifier.Send(Message{Channel: ChannelSMS, To: "+15555550100", Body: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Provider != "second" || receipt.ProviderID != "fake-sms-1" {
+		t.Errorf("receipt = %+v, want second's", receipt)
+	}
+	if len(second.Sent()) != 1 || len(third.Sent()) != 0 {
+		t.Errorf("sent second %d, third %d; want 1, 0", len(second.Sent()), len(third.Sent()))
+	}
+
+	// The first provider is tried again for the next message
+	first.Fail = nil
+	if receipt, _ := notifier.Send(Message{Channel: ChannelSMS, To: "+15555550100", Body: "Hello"}); receipt.Provider != "first" {
+		t.Errorf("receipt = %+v, want first's", receipt)
+	}
+}
+
+func TestNotifierJoinsErrors(t *testing.T) {
+	errFirst := errors.New("first is down")
+	errSecond := errors.New("second is down")
+	notifier := NewNotifier(namedFake("first", errFirst), namedFake("second", errSecond))
+
+	_, err := notifier.Send(Message{Channel: ChannelSMS, To: "+15555550100", Body: "Hello"})
+	if !errors.Is(err, errFirst) || !errors.Is(err, errSecond) {
+		t.Fatalf("error = %v, want both providers' errors", err)
+	}
+	if got, want := err.Error(), "first: first is down\nsecond: second is down"; got != want {
+		t.Errorf("error = %q, want %q", got, want)
+	}
+}
+
+func TestNotifierNoProvider(t *testing.T) {
+	notifier := NewNotifier(namedFake("sms", nil), nil)
+	if notifier.Enabled(ChannelEmail) {
+		t.Error("email is enabled without a provider")
+	}
+	_, err := notifier.Send(Message{Channel: ChannelEmail, To: "jo@example.com", Body: "Hello"})
+	if !errors.Is(err, ErrNoProvider) || !strings.Contains(err.Error(), "email") {
+		t.Errorf("error = %v, want ErrNoProvider for email", err)
+	}
+	if got := notifier.ChannelNames(); len(got) != 1 || got[0] != "sms" {
+		t.Errorf("ChannelNames() = %v, want [sms]", got)
+	}
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"crypto/ecdsa"
+	"encoding/base64"
 	"fmt"
 	"log"
 
@@ -9,31 +11,64 @@ import (
 )
 
 type SendGridService struct {
-	APIKey string
-	client *sendgrid.Client
+	APIKey    string
+	FromEmail string
+	// WebhookKey verifies signed event webhook requests. Events are not
+	// accepted without it.
+	WebhookKey *ecdsa.PublicKey
+	client     *sendgrid.Client
 }
 
-func NewSendGridService(apiKey string) *SendGridService {
+func NewSendGridService(apiKey, fromEmail string) *SendGridService {
 	if apiKey == "" {
 		log.Println("SendGrid API Key is not provided. Email functionality will not work.")
 	}
 	client := sendgrid.NewSendClient(apiKey)
 	return &SendGridService{
-		APIKey: apiKey,
-		client: client,
+		APIKey:    apiKey,
+		FromEmail: fromEmail,
+		client:    client,
 	}
 }
 
 func (s *SendGridService) SendEmail(fromEmail, toEmail, subject, plainTextContent, htmlContent string) error {
+	_, err := s.sendEmail(fromEmail, toEmail, subject, plainTextContent, htmlContent, nil)
+	return err
+}
+
+// sendEmail sends the email and returns the SendGrid message ID.
+func (s *SendGridService) sendEmail(fromEmail, toEmail, subject, plainTextContent, htmlContent string, attachments []Attachment) (string, error) {
 	from := mail.NewEmail("", fromEmail)
 	to := mail.NewEmail("", toEmail)
 	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
+	for _, a := range attachments {
+		attachment := mail.NewAttachment()
+		attachment.SetFilename(a.Filename)
+		attachment.SetType(a.ContentType)
+		attachment.SetDisposition("attachment")
+		attachment.SetContent(base64.StdEncoding.EncodeToString(a.Data))
+		message.AddAttachment(attachment)
+	}
 	response, err := s.client.Send(message)
 	if err != nil {
-		return fmt.Errorf("failed to send email: %v", err)
+		return "", fmt.Errorf("failed to send email: %v", err)
 	}
 	if response.StatusCode >= 400 {
-		return fmt.Errorf("failed to send email, status code: %d, body: %s", response.StatusCode, response.Body)
+		return "", fmt.Errorf("failed to send email, status code: %d, body: %s", response.StatusCode, response.Body)
 	}
-	return nil
+	if ids := response.Headers["X-Message-Id"]; len(ids) > 0 {
+		return ids[0], nil
+	}
+	return "", nil
+}
+
+func (s *SendGridService) Name() string     { return "sendgrid" }
+func (s *SendGridService) Channel() Channel { return ChannelEmail }
+
+func (s *SendGridService) Configured() bool {
+	return s.APIKey != "" && s.FromEmail != ""
+}
+
+func (s *SendGridService) Send(msg Message) (string, error) {
+	return s.sendEmail(s.FromEmail, msg.To, msg.Subject, msg.Body, msg.HTML, msg.Attachments)
 }
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
+)
+
+// SendGridEvent is one entry of a SendGrid event webhook post. Only the
+// fields used for delivery tracking are decoded.
+type SendGridEvent struct {
+	Email       string `json:"email"`
+	Event       string `json:"event"`
+	SGMessageID string `json:"sg_message_id"`
+	Timestamp   int64  `json:"timestamp"`
+	Reason      string `json:"reason"`
+	Type        string `json:"type"`
+}
+
+// ParseSendGridEvents decodes an event webhook request body.
+func ParseSendGridEvents(body []byte) ([]SendGridEvent, error) {
+	var events []SendGridEvent
+	err := json.Unmarshal(body, &events)
+	return events, err
+}
+
+// MessageID returns the X-Message-Id the message was sent with. SendGrid
+// adds a suffix for each recipient to the IDs in events.
+func (e SendGridEvent) MessageID() string {
+	id, _, _ := strings.Cut(e.SGMessageID, ".filter")
+	return id
+}
+
+// DeliveryEvent reports the event as a delivery event. Opens, clicks,
+// deferrals and the like are not reported; ok is false for them.
+func (e SendGridEvent) DeliveryEvent() (DeliveryEvent, bool) {
+	event := DeliveryEvent{Provider: "sendgrid", ProviderID: e.MessageID(), Detail: e.Reason, At: time.Unix(e.Timestamp, 0)}
+	switch e.Event {
+	case "delivered":
+		event.Status = DeliveryDelivered
+		event.Detail = ""
+	case "bounce":
+		event.Status = DeliveryBounced
+		if e.Type == "blocked" {
+			// Blocked by the receiving server, usually not the address's fault
+			event.Status = DeliveryUndelivered
+		}
+	case "dropped":
+		event.Status = DeliveryUndelivered
+	case "spamreport":
+		event.Status = DeliverySpam
+		event.Detail = "Marked our email as spam"
+	default:
+		return event, false
+	}
+	return event, event.ProviderID != ""
+}
+
+// ParseSendGridPublicKey reads the verification key shown in SendGrid's
+// signed event webhook settings, as base64 or PEM.
+func ParseSendGridPublicKey(key string) (*ecdsa.PublicKey, error) {
+	key = strings.TrimSpace(key)
+	var der []byte
+	if block, _ := pem.Decode([]byte(key)); block != nil {
+		der = block.Bytes
+	} else {
+		var err error
+		if der, err = base64.StdEncoding.DecodeString(key); err != nil {
+			return nil, err
+		}
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	ecdsaKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("SendGrid webhook key is not an ECDSA key")
+	}
+	return ecdsaKey, nil
+}
+
+// ValidSendGridSignature reports whether signature, from the
+// X-Twilio-Email-Event-Webhook-Signature header, signs timestamp and body
+// with key.
+func ValidSendGridSignature(key *ecdsa.PublicKey, signature, timestamp string, body []byte) bool {
+	if key == nil || signature == "" || timestamp == "" {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	hash := sha256.Sum256(append([]byte(timestamp), body...))
+	return ecdsa.VerifyASN1(key, hash[:], sig)
+}
+
+// ValidateWebhook checks an event webhook request against the configured
+// verification key.
+func (s *SendGridService) ValidateWebhook(signature, timestamp string, body []byte) bool {
+	return ValidSendGridSignature(s.WebhookKey, signature, timestamp, body)
+}
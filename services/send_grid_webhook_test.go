@@ -0,0 +1,130 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+)
+
+// sendGridKey returns a new signing key and its public half as SendGrid
+// shows it, in base64.
+func sendGridKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, base64.StdEncoding.EncodeToString(der)
+}
+
+// signSendGrid signs timestamp and body as SendGrid does.
+func signSendGrid(t *testing.T, key *ecdsa.PrivateKey, timestamp string, body []byte) string {
+	t.Helper()
+	hash := sha256.Sum256(append([]byte(timestamp), body...))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestValidSendGridSignature(t *testing.T) {
+	private, encoded := sendGridKey(t)
+	key, err := ParseSendGridPublicKey(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`[{"email":"jo@example.com","event":"delivered","sg_message_id":"abc.filter0001","timestamp":1772366400}]`)
+	const timestamp = "1772366400"
+	signature := signSendGrid(t, private, timestamp, body)
+
+	if !ValidSendGridSignature(key, signature, timestamp, body) {
+		t.Error("rejected a valid signature")
+	}
+	other, _ := sendGridKey(t)
+	tests := map[string]struct {
+		key       *ecdsa.PublicKey
+		signature string
+		timestamp string
+		body      []byte
+	}{
+		"changed body":      {key, signature, timestamp, append([]byte(nil), body[:len(body)-1]...)},
+		"changed timestamp": {key, signature, "1772366401", body},
+		"other key":         {&other.PublicKey, signature, timestamp, body},
+		"no key":            {nil, signature, timestamp, body},
+		"no signature":      {key, "", timestamp, body},
+		"no timestamp":      {key, signature, "", body},
+		"not base64":        {key, "not base64!", timestamp, body},
+	}
+	for name, tt := range tests {
+		if ValidSendGridSignature(tt.key, tt.signature, tt.timestamp, tt.body) {
+			t.Errorf("%s: signature accepted", name)
+		}
+	}
+}
+
+func TestParseSendGridPublicKey(t *testing.T) {
+	private, encoded := sendGridKey(t)
+	der, _ := base64.StdEncoding.DecodeString(encoded)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	for name, value := range map[string]string{"base64": encoded, "PEM": pemKey, "padded": "  " + encoded + "\n"} {
+		key, err := ParseSendGridPublicKey(value)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !key.Equal(&private.PublicKey) {
+			t.Errorf("%s: parsed a different key", name)
+		}
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if _, err := ParseSendGridPublicKey(base64.StdEncoding.EncodeToString(rsaDER)); err == nil {
+		t.Error("accepted an RSA key")
+	}
+	if _, err := ParseSendGridPublicKey("not a key"); err == nil {
+		t.Error("accepted garbage")
+	}
+}
+
+func TestSendGridDeliveryEvent(t *testing.T) {
+	tests := []struct {
+		event      SendGridEvent
+		ok         bool
+		want       string
+		wantDetail string
+	}{
+		{SendGridEvent{Event: "delivered", Reason: "250 OK"}, true, DeliveryDelivered, ""},
+		{SendGridEvent{Event: "bounce", Reason: "550 No such user"}, true, DeliveryBounced, "550 No such user"},
+		{SendGridEvent{Event: "bounce", Type: "blocked", Reason: "554 Blocked"}, true, DeliveryUndelivered, "554 Blocked"},
+		{SendGridEvent{Event: "dropped", Reason: "Bounced Address"}, true, DeliveryUndelivered, "Bounced Address"},
+		{SendGridEvent{Event: "spamreport"}, true, DeliverySpam, "Marked our email as spam"},
+		{SendGridEvent{Event: "open"}, false, "", ""},
+		{SendGridEvent{Event: "deferred"}, false, "", ""},
+	}
+	for _, tt := range tests {
+		tt.event.SGMessageID = "abc123.filter0001.16648.5515E0B88.0"
+		event, ok := tt.event.DeliveryEvent()
+		if ok != tt.ok || event.Status != tt.want || event.Detail != tt.wantDetail {
+			t.Errorf("%s: got %q %q, %v; want %q %q, %v", tt.event.Event, event.Status, event.Detail, ok, tt.want, tt.wantDetail, tt.ok)
+		}
+		if ok && event.ProviderID != "abc123" {
+			t.Errorf("%s: provider ID = %q, want abc123", tt.event.Event, event.ProviderID)
+		}
+	}
+}
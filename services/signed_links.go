@@ -0,0 +1,113 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidLink = errors.New("link is invalid")
+	ErrExpiredLink = errors.New("link has expired")
+)
+
+// LinkClaims is the payload carried by a signed link. Action says what the
+// link does; the IDs say what it applies to.
+type LinkClaims struct {
+	Action     string    `json:"a"`
+	EventID    string    `json:"e,omitempty"`
+	PositionID string    `json:"p,omitempty"`
+	MemberID   string    `json:"m,omitempty"`
+	RefID      string    `json:"r,omitempty"`
+	ExpiresAt  time.Time `json:"x"`
+}
+
+// Signed link actions for replying to an assignment.
+const (
+	LinkAssignmentConfirm = "assignment_confirm"
+	LinkAssignmentDecline = "assignment_decline"
+)
+
+// LinkSigner creates and verifies HMAC signed, expiring links so members
+// can act on their assignments without logging in.
+type LinkSigner struct {
+	BaseURL string
+	secret  []byte
+}
+
+func NewLinkSigner(secret, baseURL string) *LinkSigner {
+	key := []byte(secret)
+	if secret == "" {
+		log.Println("Link signing secret is not provided. Using a random key; links will stop working on restart.")
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &LinkSigner{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		secret:  key,
+	}
+}
+
+// Sign encodes the claims into a URL safe token.
+func (s *LinkSigner) Sign(claims LinkClaims) string {
+	payload, _ := json.Marshal(claims)
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(s.mac(body))
+}
+
+// Verify checks a token's signature and expiry and returns its claims.
+func (s *LinkSigner) Verify(token string) (*LinkClaims, error) {
+	body, sig, found := strings.Cut(token, ".")
+	if !found {
+		return nil, ErrInvalidLink
+	}
+	gotMAC, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(gotMAC, s.mac(body)) {
+		return nil, ErrInvalidLink
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalidLink
+	}
+	var claims LinkClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidLink
+	}
+	if time.Now().After(claims.ExpiresAt) {
+		return &claims, ErrExpiredLink
+	}
+	return &claims, nil
+}
+
+// URL returns an absolute link to path with the signed token appended.
+func (s *LinkSigner) URL(path string, claims LinkClaims) string {
+	return s.BaseURL + path + "/" + s.Sign(claims)
+}
+
+func (s *LinkSigner) mac(body string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(body))
+	return h.Sum(nil)
+}
+
+// AssignmentURLs returns the links a member uses to confirm or decline
+// their assignment to a position.
+func (s *LinkSigner) AssignmentURLs(eventID, positionID, memberID string, expiresAt time.Time) (confirm, decline string) {
+	claims := LinkClaims{
+		EventID:    eventID,
+		PositionID: positionID,
+		MemberID:   memberID,
+		ExpiresAt:  expiresAt,
+	}
+	claims.Action = LinkAssignmentConfirm
+	confirm = s.URL("/assignments", claims)
+	claims.Action = LinkAssignmentDecline
+	decline = s.URL("/assignments", claims)
+	return confirm, decline
+}
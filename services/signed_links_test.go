@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinkSignerRoundTrip(t *testing.T) {
+	signer := NewLinkSigner("link-secret", "https://example.com/")
+	claims := LinkClaims{Action: LinkAssignmentConfirm, EventID: "e1", PositionID: "cam", MemberID: "m1", ExpiresAt: time.Now().UTC().Add(time.Hour).Truncate(time.Second)}
+
+	got, err := signer.Verify(signer.Sign(claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != claims {
+		t.Errorf("claims = %+v, want %+v", got, claims)
+	}
+	if url := signer.URL("/assignments", claims); !strings.HasPrefix(url, "https://example.com/assignments/") {
+		t.Errorf("URL = %q", url)
+	}
+}
+
+func TestLinkSignerRejectsTampering(t *testing.T) {
+	signer := NewLinkSigner("link-secret", "")
+	token := signer.Sign(LinkClaims{Action: LinkAssignmentConfirm, MemberID: "m1", ExpiresAt: time.Now().Add(time.Hour)})
+	body, sig, _ := strings.Cut(token, ".")
+	other := NewLinkSigner("other-secret", "").Sign(LinkClaims{Action: LinkAssignmentConfirm, MemberID: "m2", ExpiresAt: time.Now().Add(time.Hour)})
+	otherBody, _, _ := strings.Cut(other, ".")
+
+	flip := func(s string) string {
+		c := byte('A')
+		if s[0] == 'A' {
+			c = 'B'
+		}
+		return string(c) + s[1:]
+	}
+	tests := map[string]string{
+		"tampered body":      flip(body) + "." + sig,
+		"swapped body":       otherBody + "." + sig,
+		"tampered signature": body + "." + flip(sig),
+		"other secret":       other,
+		"no signature":       body,
+		"empty":              "",
+		"not base64":         body + ".!!!",
+	}
+	for name, token := range tests {
+		if _, err := signer.Verify(token); !errors.Is(err, ErrInvalidLink) {
+			t.Errorf("%s: error = %v, want ErrInvalidLink", name, err)
+		}
+	}
+}
+
+func TestLinkSignerExpired(t *testing.T) {
+	signer := NewLinkSigner("link-secret", "")
+	token := signer.Sign(LinkClaims{Action: LinkAssignmentDecline, MemberID: "m1", ExpiresAt: time.Now().Add(-time.Minute)})
+	claims, err := signer.Verify(token)
+	if !errors.Is(err, ErrExpiredLink) {
+		t.Fatalf("error = %v, want ErrExpiredLink", err)
+	}
+	if claims == nil || claims.MemberID != "m1" {
+		t.Errorf("claims = %+v, want them returned with the error", claims)
+	}
+}
+
+func TestAssignmentURLs(t *testing.T) {
+	signer := NewLinkSigner("link-secret", "https://example.com")
+	expires := time.Now().Add(time.Hour)
+	confirm, decline := signer.AssignmentURLs("e1", "cam", "m1", expires)
+
+	for url, action := range map[string]string{confirm: LinkAssignmentConfirm, decline: LinkAssignmentDecline} {
+		token, ok := strings.CutPrefix(url, "https://example.com/assignments/")
+		if !ok {
+			t.Fatalf("URL = %q", url)
+		}
+		claims, err := signer.Verify(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.Action != action || claims.EventID != "e1" || claims.PositionID != "cam" || claims.MemberID != "m1" || !claims.ExpiresAt.Equal(expires) {
+			t.Errorf("claims = %+v, want %s for e1/cam/m1", claims, action)
+		}
+	}
+}
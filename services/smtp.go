@@ -0,0 +1,357 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How the connection to the SMTP server is secured.
+const (
+	// SMTPStartTLS upgrades a plain connection, usually on port 587.
+	SMTPStartTLS = "starttls"
+	// SMTPTLS connects over TLS from the start, usually on port 465.
+	SMTPTLS = "tls"
+	// SMTPNone sends in the clear, for a local relay or test sink.
+	SMTPNone = "none"
+)
+
+// SMTPService sends email through any SMTP server: Gmail, Office 365, a
+// self-hosted relay or a local sink such as Mailpit.
+type SMTPService struct {
+	Host      string
+	Port      int
+	Username  string
+	Password  string
+	FromEmail string
+	FromName  string
+	Security  string
+	// InsecureSkipVerify accepts the server's certificate without checking
+	// it, for relays with self-signed certificates.
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+}
+
+// NewSMTPService creates an SMTPService. A zero port picks the usual port
+// for security.
+func NewSMTPService(host string, port int, username, password, fromEmail, fromName, security string) *SMTPService {
+	if security == "" {
+		security = SMTPStartTLS
+	}
+	if port == 0 {
+		switch security {
+		case SMTPTLS:
+			port = 465
+		case SMTPNone:
+			port = 25
+		default:
+			port = 587
+		}
+	}
+	return &SMTPService{
+		Host:      host,
+		Port:      port,
+		Username:  username,
+		Password:  password,
+		FromEmail: fromEmail,
+		FromName:  fromName,
+		Security:  security,
+		Timeout:   30 * time.Second,
+	}
+}
+
+// SendEmail sends msg to its recipient and returns the Message-ID it was
+// sent with.
+func (s *SMTPService) SendEmail(msg Message) (string, error) {
+	messageID, err := newMessageID(s.FromEmail)
+	if err != nil {
+		return "", err
+	}
+	body, err := s.buildMessage(msg, messageID, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("Error building email: %w", err)
+	}
+
+	client, err := s.dial()
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	if s.Username != "" {
+		auth, err := s.auth(client)
+		if err != nil {
+			return "", err
+		}
+		if err := client.Auth(auth); err != nil {
+			return "", fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(s.FromEmail); err != nil {
+		return "", fmt.Errorf("SMTP server refused sender: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return "", fmt.Errorf("SMTP server refused recipient: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return "", fmt.Errorf("Error starting SMTP data: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return "", fmt.Errorf("Error writing SMTP data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("SMTP server rejected message: %w", err)
+	}
+	if err := client.Quit(); err != nil {
+		return "", fmt.Errorf("Error closing SMTP connection: %w", err)
+	}
+	return strings.Trim(messageID, "<>"), nil
+}
+
+// dial connects to the server and secures the connection as configured.
+func (s *SMTPService) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	tlsConfig := &tls.Config{ServerName: s.Host, InsecureSkipVerify: s.InsecureSkipVerify}
+	dialer := &net.Dialer{Timeout: s.Timeout}
+
+	var conn net.Conn
+	var err error
+	if s.Security == SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to SMTP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(s.Timeout))
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Error starting SMTP session: %w", err)
+	}
+	if s.Security == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("Error starting TLS: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// auth picks PLAIN or LOGIN authentication from what the server offers.
+// Office 365 only offers LOGIN.
+func (s *SMTPService) auth(client *smtp.Client) (smtp.Auth, error) {
+	ok, params := client.Extension("AUTH")
+	if !ok {
+		return nil, errors.New("SMTP server does not accept authentication")
+	}
+	mechanisms := strings.Fields(strings.ToUpper(params))
+	switch {
+	case slices.Contains(mechanisms, "PLAIN"):
+		return smtp.PlainAuth("", s.Username, s.Password, s.Host), nil
+	case slices.Contains(mechanisms, "LOGIN"):
+		return &loginAuth{username: s.Username, password: s.Password}, nil
+	}
+	return nil, fmt.Errorf("SMTP server offers no supported authentication (%s)", params)
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp leaves out.
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		return "", nil, errors.New("refusing to send password over an unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+// partCreator starts a MIME part with the given header and returns where
+// its body goes.
+type partCreator func(header textproto.MIMEHeader) (io.Writer, error)
+
+// buildMessage writes msg as a MIME message: plain text with an HTML
+// alternative when there is one, wrapped with any attachments.
+func (s *SMTPService) buildMessage(msg Message, messageID string, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	from := mail.Address{Name: s.FromName, Address: s.FromEmail}
+	to := mail.Address{Address: msg.To}
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	// The top level part's header follows the message headers
+	top := func(header textproto.MIMEHeader) (io.Writer, error) {
+		keys := slices.Sorted(maps.Keys(header))
+		for _, key := range keys {
+			fmt.Fprintf(&buf, "%s: %s\r\n", key, header.Get(key))
+		}
+		buf.WriteString("\r\n")
+		return &buf, nil
+	}
+
+	if len(msg.Attachments) == 0 {
+		if err := writeBody(top, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	boundary := newBoundary()
+	w, _ := top(textproto.MIMEHeader{
+		"Content-Type": {mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": boundary})},
+	})
+	mixed := multipart.NewWriter(w)
+	if err := mixed.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	if err := writeBody(mixed.CreatePart, msg); err != nil {
+		return nil, err
+	}
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": attachment.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64Lines(part, attachment.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBody writes the text of msg, with its HTML as an alternative when
+// there is one.
+func writeBody(create partCreator, msg Message) error {
+	if msg.HTML == "" {
+		return writeTextPart(create, "text/plain", msg.Body)
+	}
+	boundary := newBoundary()
+	w, err := create(textproto.MIMEHeader{
+		"Content-Type": {mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": boundary})},
+	})
+	if err != nil {
+		return err
+	}
+	alternative := multipart.NewWriter(w)
+	if err := alternative.SetBoundary(boundary); err != nil {
+		return err
+	}
+	if err := writeTextPart(alternative.CreatePart, "text/plain", msg.Body); err != nil {
+		return err
+	}
+	if err := writeTextPart(alternative.CreatePart, "text/html", msg.HTML); err != nil {
+		return err
+	}
+	return alternative.Close()
+}
+
+// writeTextPart writes text as a quoted-printable UTF-8 part.
+func writeTextPart(create partCreator, contentType, text string) error {
+	w, err := create(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64Lines writes data base64 encoded in 76 character lines.
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := io.WriteString(w, encoded[:n]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+func newBoundary() string {
+	return multipart.NewWriter(io.Discard).Boundary()
+}
+
+// newMessageID makes a unique Message-ID in the sender's domain.
+func newMessageID(fromEmail string) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	domain := "localhost"
+	if _, d, ok := strings.Cut(fromEmail, "@"); ok && d != "" {
+		domain = d
+	}
+	return "<" + hex.EncodeToString(raw) + "@" + domain + ">", nil
+}
+
+func (s *SMTPService) Name() string     { return "smtp" }
+func (s *SMTPService) Channel() Channel { return ChannelEmail }
+
+func (s *SMTPService) Configured() bool {
+	return s.Host != "" && s.FromEmail != ""
+}
+
+func (s *SMTPService) Send(msg Message) (string, error) {
+	return s.SendEmail(msg)
+}
@@ -0,0 +1,381 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpSink is an in-process SMTP server that accepts mail and keeps it.
+type smtpSink struct {
+	listener net.Listener
+	// startTLS offers STARTTLS with this config when set.
+	startTLS *tls.Config
+	// auth lists the AUTH mechanisms offered; none when empty.
+	auth     []string
+	username string
+	password string
+
+	mu       sync.Mutex
+	messages [][]byte
+	// mechanism is the AUTH mechanism last used.
+	mechanism string
+	// tlsUsed records whether the last message arrived over TLS.
+	tlsUsed bool
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &smtpSink{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+	return sink
+}
+
+// service returns an SMTPService pointed at the sink.
+func (s *smtpSink) service(security string) *SMTPService {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	smtp := NewSMTPService("127.0.0.1", addr.Port, "", "", "scheduler@example.com", "Church Scheduler", security)
+	smtp.InsecureSkipVerify = true
+	return smtp
+}
+
+// testTLSConfig borrows the self-signed certificate httptest serves with.
+func testTLSConfig(t *testing.T) *tls.Config {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	return &tls.Config{Certificates: server.TLS.Certificates}
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	// conn is replaced by the TLS connection after STARTTLS
+	defer func() { conn.Close() }()
+	tp := textproto.NewConn(conn)
+	usingTLS := false
+	tp.PrintfLine("220 sink ready")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			lines := []string{"sink"}
+			if s.startTLS != nil && !usingTLS {
+				lines = append(lines, "STARTTLS")
+			}
+			if len(s.auth) > 0 {
+				lines = append(lines, "AUTH "+strings.Join(s.auth, " "))
+			}
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				tp.PrintfLine("250%s%s", sep, l)
+			}
+		case "STARTTLS":
+			if s.startTLS == nil {
+				tp.PrintfLine("502 not supported")
+				continue
+			}
+			tp.PrintfLine("220 go ahead")
+			tlsConn := tls.Server(conn, s.startTLS)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, usingTLS = tlsConn, true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			if !s.authenticate(tp, strings.ToUpper(mechanism), initial) {
+				tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			s.mu.Lock()
+			s.mechanism = strings.ToUpper(mechanism)
+			s.mu.Unlock()
+			tp.PrintfLine("235 authenticated")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 send it")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data)
+			s.tlsUsed = usingTLS
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("500 unknown command")
+		}
+	}
+}
+
+func (s *smtpSink) authenticate(tp *textproto.Conn, mechanism, initial string) bool {
+	decode := func(v string) string {
+		b, _ := base64.StdEncoding.DecodeString(v)
+		return string(b)
+	}
+	challenge := func(prompt string) string {
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt)))
+		line, _ := tp.ReadLine()
+		return decode(line)
+	}
+	switch mechanism {
+	case "PLAIN":
+		if initial == "" {
+			initial = base64.StdEncoding.EncodeToString([]byte(challenge("")))
+		}
+		parts := strings.Split(decode(initial), "\x00")
+		return len(parts) == 3 && parts[1] == s.username && parts[2] == s.password
+	case "LOGIN":
+		return challenge("Username:") == s.username && challenge("Password:") == s.password
+	}
+	return false
+}
+
+func (s *smtpSink) lastMessage(t *testing.T) *mail.Message {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) == 0 {
+		t.Fatal("sink received no message")
+	}
+	msg, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(s.messages[len(s.messages)-1])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// mimePart is one part of a multipart body, with its transfer encoding
+// undone where multipart.Reader does so.
+type mimePart struct {
+	Header textproto.MIMEHeader
+	Body   []byte
+}
+
+// readParts returns the parts of a multipart body, checking its type.
+func readParts(t *testing.T, contentType string, body io.Reader, want string) []mimePart {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != want {
+		t.Fatalf("Content-Type = %s, want %s", mediaType, want)
+	}
+	reader := multipart.NewReader(body, params["boundary"])
+	var parts []mimePart
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, mimePart{Header: part.Header, Body: data})
+	}
+}
+
+// state returns how the last message arrived.
+func (s *smtpSink) state() (received int, mechanism string, tlsUsed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.messages), s.mechanism, s.tlsUsed
+}
+
+func TestSMTPSendMultipartAlternative(t *testing.T) {
+	sink := newSMTPSink(t)
+	smtp := sink.service(SMTPNone)
+
+	id, err := smtp.Send(Message{
+		Channel: ChannelEmail,
+		To:      "jo@example.com",
+		Subject: "Café schedule for Sunday",
+		Body:    "Hi Jo, you're serving Sunday.",
+		HTML:    "<p>Hi Jo, you're serving <strong>Sunday</strong>.</p>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := sink.lastMessage(t)
+	if got := msg.Header.Get("Message-Id"); got != "<"+id+">" {
+		t.Errorf("Message-ID = %q, want <%s>", got, id)
+	}
+	if !strings.HasSuffix(id, "@example.com") {
+		t.Errorf("Message-ID %q is not in the sender's domain", id)
+	}
+	rawSubject := msg.Header["Subject"][0]
+	if !strings.HasPrefix(rawSubject, "=?utf-8?q?") {
+		t.Errorf("Subject %q is not Q-encoded", rawSubject)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(rawSubject); subject != "Café schedule for Sunday" {
+		t.Errorf("decoded Subject = %q", subject)
+	}
+	if from, _ := mail.ParseAddress(msg.Header.Get("From")); from == nil || from.Name != "Church Scheduler" || from.Address != "scheduler@example.com" {
+		t.Errorf("From = %q", msg.Header.Get("From"))
+	}
+
+	parts := readParts(t, msg.Header.Get("Content-Type"), msg.Body, "multipart/alternative")
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parts))
+	}
+	for i, want := range []string{"text/plain", "text/html"} {
+		mediaType, params, _ := mime.ParseMediaType(parts[i].Header.Get("Content-Type"))
+		if mediaType != want || params["charset"] != "utf-8" {
+			t.Errorf("part %d Content-Type = %q, want %s; charset=utf-8", i, parts[i].Header.Get("Content-Type"), want)
+		}
+	}
+	// multipart.Reader undoes the quoted-printable encoding
+	if got := string(parts[0].Body); got != "Hi Jo, you're serving Sunday." {
+		t.Errorf("text part = %q", got)
+	}
+	if got := string(parts[1].Body); !strings.Contains(got, "<strong>Sunday</strong>") {
+		t.Errorf("HTML part = %q", got)
+	}
+}
+
+func TestSMTPSendAttachments(t *testing.T) {
+	sink := newSMTPSink(t)
+	smtp := sink.service(SMTPNone)
+
+	calendar := bytes.Repeat([]byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"), 10)
+	_, err := smtp.Send(Message{
+		Channel:     ChannelEmail,
+		To:          "jo@example.com",
+		Subject:     "Your schedule",
+		Body:        "Attached.",
+		Attachments: []Attachment{{Filename: "schedule.ics", ContentType: "text/calendar", Data: calendar}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := sink.lastMessage(t)
+	parts := readParts(t, msg.Header.Get("Content-Type"), msg.Body, "multipart/mixed")
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parts))
+	}
+	attachment := parts[1]
+	if _, params, _ := mime.ParseMediaType(attachment.Header.Get("Content-Disposition")); params["filename"] != "schedule.ics" {
+		t.Errorf("Content-Disposition = %q", attachment.Header.Get("Content-Disposition"))
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(attachment.Body), "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, calendar) {
+		t.Error("attachment data changed in transit")
+	}
+}
+
+func TestSMTPStartTLSRefusedWithoutSupport(t *testing.T) {
+	sink := newSMTPSink(t)
+
+	_, err := sink.service(SMTPStartTLS).Send(Message{Channel: ChannelEmail, To: "jo@example.com", Subject: "Hi", Body: "Hi"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("error = %v, want STARTTLS refusal", err)
+	}
+	if received, _, _ := sink.state(); received != 0 {
+		t.Error("message was sent in the clear when STARTTLS was required")
+	}
+
+	// Sending in the clear has to be asked for
+	if _, err := sink.service(SMTPNone).Send(Message{Channel: ChannelEmail, To: "jo@example.com", Subject: "Hi", Body: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, tlsUsed := sink.state(); tlsUsed {
+		t.Error("security none used TLS")
+	}
+}
+
+func TestSMTPAuth(t *testing.T) {
+	for _, tt := range []struct {
+		offered []string
+		want    string
+	}{
+		{[]string{"PLAIN", "LOGIN"}, "PLAIN"},
+		{[]string{"LOGIN"}, "LOGIN"},
+	} {
+		t.Run(tt.want, func(t *testing.T) {
+			sink := newSMTPSink(t)
+			sink.startTLS = testTLSConfig(t)
+			sink.auth = tt.offered
+			sink.username, sink.password = "scheduler", "s3cret"
+
+			smtp := sink.service(SMTPStartTLS)
+			smtp.Username, smtp.Password = "scheduler", "s3cret"
+			if _, err := smtp.Send(Message{Channel: ChannelEmail, To: "jo@example.com", Subject: "Hi", Body: "Hi"}); err != nil {
+				t.Fatal(err)
+			}
+			_, mechanism, tlsUsed := sink.state()
+			if mechanism != tt.want {
+				t.Errorf("authenticated with %q, want %q", mechanism, tt.want)
+			}
+			if !tlsUsed {
+				t.Error("message was not sent over STARTTLS")
+			}
+
+			smtp.Password = "wrong"
+			if _, err := smtp.Send(Message{Channel: ChannelEmail, To: "jo@example.com", Subject: "Hi", Body: "Hi"}); err == nil {
+				t.Error("wrong password was accepted")
+			}
+		})
+	}
+}
+
+func TestSMTPLoginAuthNeedsTLS(t *testing.T) {
+	sink := newSMTPSink(t)
+	sink.auth = []string{"LOGIN"}
+	sink.username, sink.password = "scheduler", "s3cret"
+
+	smtp := sink.service(SMTPNone)
+	smtp.Username, smtp.Password = "scheduler", "s3cret"
+	_, err := smtp.Send(Message{Channel: ChannelEmail, To: "jo@example.com", Subject: "Hi", Body: "Hi"})
+	if err == nil || !strings.Contains(err.Error(), "unencrypted") {
+		t.Errorf("error = %v, want refusal to send the password in the clear", err)
+	}
+}
+
+func TestNewSMTPServiceDefaultPorts(t *testing.T) {
+	for security, want := range map[string]int{SMTPStartTLS: 587, SMTPTLS: 465, SMTPNone: 25, "": 587} {
+		if got := NewSMTPService("mail.example.com", 0, "", "", "a@example.com", "", security).Port; got != want {
+			t.Errorf("security %q: port %d, want %d", security, got, want)
+		}
+	}
+}
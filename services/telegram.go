@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TelegramService talks to the Telegram Bot API. BaseURL can point at a
+// local stub server in place of api.telegram.org.
+type TelegramService struct {
+	Token    string
+	BaseURL  string
+	Username string
+	// WebhookSecret is the token Telegram sends with each webhook request.
+	WebhookSecret string
+	client        *http.Client
+}
+
+func NewTelegramService(token, baseURL, username, webhookSecret string) *TelegramService {
+	if baseURL == "" {
+		baseURL = "https://api.telegram.org"
+	}
+	return &TelegramService{
+		Token:         token,
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		Username:      strings.TrimPrefix(username, "@"),
+		WebhookSecret: webhookSecret,
+		client:        &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// TelegramUpdate is an incoming update posted to the bot's webhook. Only
+// the parts the bot uses are decoded.
+type TelegramUpdate struct {
+	UpdateID      int64                  `json:"update_id"`
+	Message       *TelegramMessage       `json:"message"`
+	CallbackQuery *TelegramCallbackQuery `json:"callback_query"`
+}
+
+type TelegramUser struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+}
+
+type TelegramChat struct {
+	ID int64 `json:"id"`
+}
+
+type TelegramMessage struct {
+	MessageID int64         `json:"message_id"`
+	From      *TelegramUser `json:"from"`
+	Chat      TelegramChat  `json:"chat"`
+	Text      string        `json:"text"`
+}
+
+// TelegramCallbackQuery is a press of an inline button.
+type TelegramCallbackQuery struct {
+	ID      string           `json:"id"`
+	From    TelegramUser     `json:"from"`
+	Message *TelegramMessage `json:"message"`
+	Data    string           `json:"data"`
+}
+
+// ChatID returns the chat the button was pressed in.
+func (q *TelegramCallbackQuery) ChatID() string {
+	if q.Message != nil {
+		return strconv.FormatInt(q.Message.Chat.ID, 10)
+	}
+	// Private chats share the user's ID
+	return strconv.FormatInt(q.From.ID, 10)
+}
+
+// ParseTelegramUpdate decodes a webhook request body.
+func ParseTelegramUpdate(body []byte) (TelegramUpdate, error) {
+	var update TelegramUpdate
+	err := json.Unmarshal(body, &update)
+	return update, err
+}
+
+// ValidTelegramSecret reports whether header matches the secret token the
+// webhook was registered with. An empty secret never validates.
+func ValidTelegramSecret(secret, header string) bool {
+	if secret == "" || header == "" {
+		return false
+	}
+	return hmac.Equal([]byte(secret), []byte(header))
+}
+
+// ValidateRequest checks the secret token header of a webhook request.
+func (s *TelegramService) ValidateRequest(header string) bool {
+	return ValidTelegramSecret(s.WebhookSecret, header)
+}
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+}
+
+type telegramButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+// call invokes a Bot API method and decodes its result into result, when
+// not nil.
+func (s *TelegramService) call(method string, params any, result any) error {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("Error marshaling JSON payload: %w", err)
+	}
+	res, err := s.client.Post(s.BaseURL+"/bot"+s.Token+"/"+method, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		// The URL holds the token; don't let it reach the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("Error calling Telegram %s: %w", method, err)
+	}
+	defer res.Body.Close()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("Error reading response body: %w", err)
+	}
+	var response telegramResponse
+	if err := json.Unmarshal(bodyBytes, &response); err != nil {
+		return fmt.Errorf("Error decoding Telegram response (status %d): %w", res.StatusCode, err)
+	}
+	if !response.OK {
+		return fmt.Errorf("Telegram %s failed: %s", method, response.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(response.Result, result)
+	}
+	return nil
+}
+
+// SendMessage sends text to a chat, offering actions as inline buttons two
+// to a row. It returns the Telegram message ID.
+func (s *TelegramService) SendMessage(chatID, text string, actions []Action) (int64, error) {
+	params := map[string]any{"chat_id": chatID, "text": text}
+	if len(actions) > 0 {
+		var rows [][]telegramButton
+		for i, action := range actions {
+			button := telegramButton{Text: action.Label, CallbackData: action.Data}
+			if i%2 == 0 {
+				rows = append(rows, []telegramButton{button})
+			} else {
+				rows[len(rows)-1] = append(rows[len(rows)-1], button)
+			}
+		}
+		params["reply_markup"] = map[string]any{"inline_keyboard": rows}
+	}
+	var sent TelegramMessage
+	if err := s.call("sendMessage", params, &sent); err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+// AnswerCallback acknowledges a button press, showing text briefly to the
+// member.
+func (s *TelegramService) AnswerCallback(callbackID, text string) error {
+	return s.call("answerCallbackQuery", map[string]any{"callback_query_id": callbackID, "text": text}, nil)
+}
+
+// SetWebhook tells Telegram to post updates to webhookURL with secret in the
+// X-Telegram-Bot-Api-Secret-Token header.
+func (s *TelegramService) SetWebhook(webhookURL, secret string) error {
+	return s.call("setWebhook", map[string]any{
+		"url":             webhookURL,
+		"secret_token":    secret,
+		"allowed_updates": []string{"message", "callback_query"},
+	}, nil)
+}
+
+// DeepLink returns the t.me link that opens the bot and sends it
+// "/start code".
+func (s *TelegramService) DeepLink(code string) string {
+	return "https://t.me/" + s.Username + "?start=" + code
+}
+
+func (s *TelegramService) Name() string     { return "telegram" }
+func (s *TelegramService) Channel() Channel { return ChannelTelegram }
+
+func (s *TelegramService) Configured() bool {
+	return s.Token != ""
+}
+
+func (s *TelegramService) Send(msg Message) (string, error) {
+	id, err := s.SendMessage(msg.To, msg.Body, msg.Actions)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
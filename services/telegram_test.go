@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// botCall is a Bot API request received by botStub.
+type botCall struct {
+	Method string
+	Params map[string]any
+}
+
+// botStub is an httptest stand-in for the Telegram Bot API that records
+// the calls made to it.
+type botStub struct {
+	calls []botCall
+	// fail, when set, is returned as the description of a failed call.
+	fail string
+}
+
+func newBotStub(t *testing.T) (*botStub, *TelegramService) {
+	stub := &botStub{}
+	server := httptest.NewServer(http.HandlerFunc(stub.serve))
+	t.Cleanup(server.Close)
+	return stub, NewTelegramService("123:secret", server.URL, "@church_bot", "hook-secret")
+}
+
+func (s *botStub) serve(w http.ResponseWriter, r *http.Request) {
+	method, ok := strings.CutPrefix(r.URL.Path, "/bot123:secret/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"ok": false, "description": "Not Found"})
+		return
+	}
+	var params map[string]any
+	json.NewDecoder(r.Body).Decode(&params)
+	s.calls = append(s.calls, botCall{Method: method, Params: params})
+
+	if s.fail != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"ok": false, "description": s.fail})
+		return
+	}
+	result := any(true)
+	if method == "sendMessage" {
+		chatID, _ := strconv.ParseInt(fmt.Sprint(params["chat_id"]), 10, 64)
+		result = map[string]any{"message_id": len(s.calls), "chat": map[string]any{"id": chatID}, "text": params["text"]}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func TestTelegramSendMessageWithButtons(t *testing.T) {
+	stub, tg := newBotStub(t)
+
+	id, err := tg.Send(Message{
+		Channel: ChannelTelegram,
+		To:      "4242",
+		Body:    "You're on Camera on Sunday",
+		Actions: []Action{
+			{Label: "Confirm Camera", Data: "confirm:cam"},
+			{Label: "Can't make it: Camera", Data: "decline:cam"},
+			{Label: "Confirm Lights", Data: "confirm:lights"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "1" {
+		t.Errorf("message ID = %q, want 1", id)
+	}
+	if len(stub.calls) != 1 || stub.calls[0].Method != "sendMessage" {
+		t.Fatalf("calls = %+v", stub.calls)
+	}
+	params := stub.calls[0].Params
+	if params["chat_id"] != "4242" || params["text"] != "You're on Camera on Sunday" {
+		t.Errorf("params = %v", params)
+	}
+
+	markup, _ := json.Marshal(params["reply_markup"])
+	want := `{"inline_keyboard":[` +
+		`[{"callback_data":"confirm:cam","text":"Confirm Camera"},{"callback_data":"decline:cam","text":"Can't make it: Camera"}],` +
+		`[{"callback_data":"confirm:lights","text":"Confirm Lights"}]]}`
+	if string(markup) != want {
+		t.Errorf("reply_markup = %s\nwant %s", markup, want)
+	}
+}
+
+func TestTelegramSendMessageWithoutButtons(t *testing.T) {
+	stub, tg := newBotStub(t)
+	if _, err := tg.SendMessage("4242", "Hello", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stub.calls[0].Params["reply_markup"]; ok {
+		t.Errorf("reply_markup sent without actions: %v", stub.calls[0].Params)
+	}
+}
+
+func TestTelegramAPIError(t *testing.T) {
+	stub, tg := newBotStub(t)
+	stub.fail = "Bad Request: chat not found"
+
+	_, err := tg.SendMessage("4242", "Hello", nil)
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("error = %v, want the API's description", err)
+	}
+}
+
+func TestTelegramErrorHidesToken(t *testing.T) {
+	tg := NewTelegramService("123:secret", "http://127.0.0.1:1", "church_bot", "")
+	_, err := tg.SendMessage("4242", "Hello", nil)
+	if err == nil {
+		t.Fatal("sending to a closed port succeeded")
+	}
+	if strings.Contains(err.Error(), "123:secret") {
+		t.Errorf("error %q contains the bot token", err)
+	}
+}
+
+func TestTelegramAnswerCallback(t *testing.T) {
+	stub, tg := newBotStub(t)
+	if err := tg.AnswerCallback("cb-1", "Confirmed"); err != nil {
+		t.Fatal(err)
+	}
+	call := stub.calls[0]
+	if call.Method != "answerCallbackQuery" || call.Params["callback_query_id"] != "cb-1" || call.Params["text"] != "Confirmed" {
+		t.Errorf("call = %+v", call)
+	}
+}
+
+func TestTelegramDeepLinkAndSecret(t *testing.T) {
+	_, tg := newBotStub(t)
+	if got := tg.DeepLink("abc123"); got != "https://t.me/church_bot?start=abc123" {
+		t.Errorf("DeepLink = %q", got)
+	}
+	for header, want := range map[string]bool{"hook-secret": true, "other": false, "": false} {
+		if got := tg.ValidateRequest(header); got != want {
+			t.Errorf("ValidateRequest(%q) = %v, want %v", header, got, want)
+		}
+	}
+	if ValidTelegramSecret("", "") {
+		t.Error("an empty secret validated")
+	}
+}
+
+func TestParseTelegramCallback(t *testing.T) {
+	update, err := ParseTelegramUpdate([]byte(`{"update_id":7,"callback_query":{"id":"cb-1","from":{"id":99},"data":"confirm:cam"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := update.CallbackQuery
+	if q == nil || q.Data != "confirm:cam" {
+		t.Fatalf("callback query = %+v", q)
+	}
+	// Without the message, the chat is the user's private chat
+	if q.ChatID() != "99" {
+		t.Errorf("ChatID() = %q, want 99", q.ChatID())
+	}
+}
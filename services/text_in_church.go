@@ -1,9 +1,190 @@
 package services
 
-import "net/http"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Errors a TextInChurchError can match with errors.Is.
+var (
+	ErrTextInChurchUnauthorized  = errors.New("TextInChurch rejected the API key")
+	ErrTextInChurchNotFound      = errors.New("TextInChurch record not found")
+	ErrTextInChurchInvalidNumber = errors.New("TextInChurch can't text this number")
+	ErrTextInChurchRateLimited   = errors.New("TextInChurch rate limit reached")
+	ErrTextInChurchUnavailable   = errors.New("TextInChurch is unavailable")
+)
+
+// TextInChurchError is an error response from the TextInChurch API.
+type TextInChurchError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *TextInChurchError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Code != "" {
+		return fmt.Sprintf("TextInChurch error %d (%s): %s", e.StatusCode, e.Code, msg)
+	}
+	return fmt.Sprintf("TextInChurch error %d: %s", e.StatusCode, msg)
+}
+
+// Is maps the response onto the ErrTextInChurch errors so callers don't
+// need to know the API's status codes.
+func (e *TextInChurchError) Is(target error) bool {
+	switch target {
+	case ErrTextInChurchUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrTextInChurchNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrTextInChurchInvalidNumber:
+		return e.Code == "invalid_phone" || e.Code == "unsubscribed" || e.Code == "landline"
+	case ErrTextInChurchRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrTextInChurchUnavailable:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// TextInChurchContact is a person in the church's TextInChurch account.
+// ExternalID holds our member ID.
+type TextInChurchContact struct {
+	ID         string `json:"id,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	Phone      string `json:"phone,omitempty"`
+	Email      string `json:"email,omitempty"`
+	// OptedOut keeps TextInChurch from texting a member who has asked us
+	// to stop.
+	OptedOut bool `json:"opted_out"`
+}
 
 type TextInChurchService struct {
 	BaseURL string
 	APIKey  string
 	client  *http.Client
 }
+
+func NewTextInChurchService(apiKey, baseURL string) *TextInChurchService {
+	if baseURL == "" {
+		baseURL = "https://api.textinchurch.com/v1"
+	}
+	return &TextInChurchService{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type ticErrorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// do sends a JSON request to the API and decodes the response into result,
+// when not nil. Error responses come back as a *TextInChurchError.
+func (s *TextInChurchService) do(method, path string, body any, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("Error marshaling JSON payload: %w", err)
+		}
+		reqBody = bytes.NewBuffer(payload)
+	}
+
+	req, err := http.NewRequest(method, s.BaseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("Error creating HTTP request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error sending HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("Error reading response body: %w", err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		apiErr := &TextInChurchError{StatusCode: res.StatusCode}
+		var errResponse ticErrorResponse
+		if json.Unmarshal(bodyBytes, &errResponse) == nil {
+			apiErr.Code = errResponse.Error.Code
+			apiErr.Message = errResponse.Error.Message
+		}
+		return apiErr
+	}
+	if result != nil {
+		if err := json.Unmarshal(bodyBytes, result); err != nil {
+			return fmt.Errorf("Error decoding TextInChurch response: %w", err)
+		}
+	}
+	return nil
+}
+
+// SyncContact creates or updates contact in TextInChurch and returns it
+// with its TextInChurch ID. A contact whose ID is no longer known there is
+// created again.
+func (s *TextInChurchService) SyncContact(contact TextInChurchContact) (TextInChurchContact, error) {
+	var saved TextInChurchContact
+	if contact.ID != "" {
+		err := s.do(http.MethodPut, "/contacts/"+url.PathEscape(contact.ID), contact, &saved)
+		if !errors.Is(err, ErrTextInChurchNotFound) {
+			return saved, err
+		}
+		contact.ID = ""
+	}
+	err := s.do(http.MethodPost, "/contacts", contact, &saved)
+	return saved, err
+}
+
+// SendSMS sends body to the number to and returns the TextInChurch message
+// ID.
+func (s *TextInChurchService) SendSMS(to string, body string) (string, error) {
+	var sent struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	err := s.do(http.MethodPost, "/messages", map[string]string{"to": to, "body": body}, &sent)
+	if err != nil {
+		return "", fmt.Errorf("Error sending SMS message: %w", err)
+	}
+	if sent.Status == "rejected" {
+		return "", fmt.Errorf("TextInChurch rejected message %s", sent.ID)
+	}
+	return sent.ID, nil
+}
+
+func (s *TextInChurchService) Name() string     { return "textinchurch" }
+func (s *TextInChurchService) Channel() Channel { return ChannelSMS }
+
+func (s *TextInChurchService) Configured() bool {
+	return s.APIKey != ""
+}
+
+func (s *TextInChurchService) Send(msg Message) (string, error) {
+	return s.SendSMS(msg.To, msg.Body)
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// ticStub is an httptest stand-in for the TextInChurch API. It keeps
+// contacts in memory and records the requests it was sent.
+type ticStub struct {
+	contacts map[string]TextInChurchContact
+	requests []string
+	// failStatus and failBody, when set, answer every request instead.
+	failStatus int
+	failBody   string
+}
+
+func newTICStub(t *testing.T) (*ticStub, *TextInChurchService) {
+	stub := &ticStub{contacts: map[string]TextInChurchContact{}}
+	server := httptest.NewServer(http.HandlerFunc(stub.serve))
+	t.Cleanup(server.Close)
+	return stub, NewTextInChurchService("test-key", server.URL+"/")
+}
+
+func (s *ticStub) serve(w http.ResponseWriter, r *http.Request) {
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") != "Bearer test-key" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if s.failStatus != 0 {
+		w.WriteHeader(s.failStatus)
+		w.Write([]byte(s.failBody))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/messages":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["to"] == "" || body["body"] == "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": "msg-1", "status": "queued"})
+	case r.Method == http.MethodPost && r.URL.Path == "/contacts":
+		var contact TextInChurchContact
+		json.NewDecoder(r.Body).Decode(&contact)
+		contact.ID = fmt.Sprintf("c%d", len(s.contacts)+1)
+		s.contacts[contact.ID] = contact
+		json.NewEncoder(w).Encode(contact)
+	case r.Method == http.MethodPut && len(r.URL.Path) > len("/contacts/"):
+		id := r.URL.Path[len("/contacts/"):]
+		if _, ok := s.contacts[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"not_found","message":"Contact not found"}}`))
+			return
+		}
+		var contact TextInChurchContact
+		json.NewDecoder(r.Body).Decode(&contact)
+		contact.ID = id
+		s.contacts[id] = contact
+		json.NewEncoder(w).Encode(contact)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestTextInChurchSendSMS(t *testing.T) {
+	stub, tic := newTICStub(t)
+	id, err := tic.Send(Message{Channel: ChannelSMS, To: "+15555550100", Body: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "msg-1" {
+		t.Errorf("message ID = %q, want msg-1", id)
+	}
+	if len(stub.requests) != 1 || stub.requests[0] != "POST /messages" {
+		t.Errorf("requests = %v", stub.requests)
+	}
+}
+
+func TestTextInChurchSyncContact(t *testing.T) {
+	stub, tic := newTICStub(t)
+
+	created, err := tic.SyncContact(TextInChurchContact{ExternalID: "m1", FirstName: "Jo", LastName: "Smith", Phone: "+15555550100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" {
+		t.Fatal("created contact has no ID")
+	}
+
+	created.OptedOut = true
+	updated, err := tic.SyncContact(created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.ID != created.ID || !stub.contacts[created.ID].OptedOut {
+		t.Errorf("update did not change contact %q: %+v", created.ID, stub.contacts[created.ID])
+	}
+	want := []string{"POST /contacts", "PUT /contacts/" + created.ID}
+	if !slices.Equal(stub.requests, want) {
+		t.Errorf("requests = %v, want %v", stub.requests, want)
+	}
+}
+
+func TestTextInChurchSyncContactRecreatesMissing(t *testing.T) {
+	stub, tic := newTICStub(t)
+
+	saved, err := tic.SyncContact(TextInChurchContact{ID: "deleted", ExternalID: "m1", FirstName: "Jo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID == "" || saved.ID == "deleted" {
+		t.Errorf("contact ID = %q, want a new ID", saved.ID)
+	}
+	want := []string{"PUT /contacts/deleted", "POST /contacts"}
+	if !slices.Equal(stub.requests, want) {
+		t.Errorf("requests = %v, want %v", stub.requests, want)
+	}
+}
+
+func TestTextInChurchErrorBody(t *testing.T) {
+	stub, tic := newTICStub(t)
+	stub.failStatus = http.StatusUnprocessableEntity
+	stub.failBody = `{"error":{"code":"landline","message":"Number is a landline"}}`
+
+	_, err := tic.SendSMS("+15555550100", "Hello")
+	var apiErr *TextInChurchError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want a *TextInChurchError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Code != "landline" || apiErr.Message != "Number is a landline" {
+		t.Errorf("error = %+v", apiErr)
+	}
+	if !errors.Is(err, ErrTextInChurchInvalidNumber) {
+		t.Errorf("errors.Is(%v, ErrTextInChurchInvalidNumber) = false", err)
+	}
+}
+
+func TestTextInChurchErrorIs(t *testing.T) {
+	sentinels := []error{
+		ErrTextInChurchUnauthorized,
+		ErrTextInChurchNotFound,
+		ErrTextInChurchInvalidNumber,
+		ErrTextInChurchRateLimited,
+		ErrTextInChurchUnavailable,
+	}
+	tests := []struct {
+		status int
+		code   string
+		want   error
+	}{
+		{http.StatusUnauthorized, "", ErrTextInChurchUnauthorized},
+		{http.StatusForbidden, "", ErrTextInChurchUnauthorized},
+		{http.StatusNotFound, "", ErrTextInChurchNotFound},
+		{http.StatusTooManyRequests, "", ErrTextInChurchRateLimited},
+		{http.StatusInternalServerError, "", ErrTextInChurchUnavailable},
+		{http.StatusServiceUnavailable, "", ErrTextInChurchUnavailable},
+		{http.StatusUnprocessableEntity, "invalid_phone", ErrTextInChurchInvalidNumber},
+		{http.StatusUnprocessableEntity, "unsubscribed", ErrTextInChurchInvalidNumber},
+		{http.StatusUnprocessableEntity, "landline", ErrTextInChurchInvalidNumber},
+		{http.StatusBadRequest, "bad_request", nil},
+	}
+	for _, tt := range tests {
+		err := &TextInChurchError{StatusCode: tt.status, Code: tt.code}
+		for _, sentinel := range sentinels {
+			if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+				t.Errorf("errors.Is(%d %q, %v) = %v", tt.status, tt.code, sentinel, got)
+			}
+		}
+	}
+}
+
+func TestTextInChurchUnauthorized(t *testing.T) {
+	_, tic := newTICStub(t)
+	tic.APIKey = "wrong"
+	if _, err := tic.SendSMS("+15555550100", "Hello"); !errors.Is(err, ErrTextInChurchUnauthorized) {
+		t.Errorf("error = %v, want ErrTextInChurchUnauthorized", err)
+	}
+}
@@ -1,7 +1,6 @@
 package services
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/twilio/twilio-go"
@@ -13,6 +12,9 @@ type TwilioService struct {
 	authToken string
 	Client    *twilio.RestClient
 	From      string
+	// StatusCallback, when set, is the URL Twilio reports delivery status
+	// to.
+	StatusCallback string
 }
 
 func NewTwilioService(accountSID, authToken, fromNumber string) *TwilioService {
@@ -30,17 +32,37 @@ func NewTwilioService(accountSID, authToken, fromNumber string) *TwilioService {
 }
 
 func (s *TwilioService) TSendSMS(to string, body string) error {
+	_, err := s.SendSMS(to, body)
+	return err
+}
+
+// SendSMS sends body to the number to and returns the message SID.
+func (s *TwilioService) SendSMS(to string, body string) (string, error) {
 	params := &twilioApi.CreateMessageParams{}
 	params.SetTo(to)
 	params.SetFrom(s.From)
 	params.SetBody(body)
+	if s.StatusCallback != "" {
+		params.SetStatusCallback(s.StatusCallback)
+	}
 
 	resp, err := s.Client.Api.CreateMessage(params)
 	if err != nil {
-		return fmt.Errorf("Error sending SMS message: %w", err)
-	} else {
-		response, _ := json.Marshal(*resp)
-		fmt.Println("Response: " + string(response))
-		return nil
+		return "", fmt.Errorf("Error sending SMS message: %w", err)
 	}
+	if resp.Sid == nil {
+		return "", nil
+	}
+	return *resp.Sid, nil
+}
+
+func (s *TwilioService) Name() string     { return "twilio" }
+func (s *TwilioService) Channel() Channel { return ChannelSMS }
+
+func (s *TwilioService) Configured() bool {
+	return s.sID != "" && s.authToken != "" && s.From != ""
+}
+
+func (s *TwilioService) Send(msg Message) (string, error) {
+	return s.SendSMS(msg.To, msg.Body)
 }
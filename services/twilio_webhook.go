@@ -0,0 +1,144 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InboundSMS is a text message received through Twilio's webhook.
+type InboundSMS struct {
+	MessageSID string
+	From       string
+	To         string
+	Body       string
+}
+
+// ParseInboundSMS reads the fields Twilio posts for an incoming message.
+func ParseInboundSMS(params url.Values) InboundSMS {
+	return InboundSMS{
+		MessageSID: params.Get("MessageSid"),
+		From:       params.Get("From"),
+		To:         params.Get("To"),
+		Body:       params.Get("Body"),
+	}
+}
+
+// TwilioStatus is a message status callback from Twilio.
+type TwilioStatus struct {
+	MessageSID string
+	Status     string
+	ErrorCode  string
+	To         string
+}
+
+// ParseTwilioStatus reads the fields Twilio posts to a status callback.
+func ParseTwilioStatus(params url.Values) TwilioStatus {
+	return TwilioStatus{
+		MessageSID: params.Get("MessageSid"),
+		Status:     params.Get("MessageStatus"),
+		ErrorCode:  params.Get("ErrorCode"),
+		To:         params.Get("To"),
+	}
+}
+
+// twilioErrors describes the error codes Twilio reports for texts that
+// don't arrive.
+var twilioErrors = map[string]string{
+	"21610": "Recipient has unsubscribed from our number",
+	"30003": "Phone unreachable",
+	"30004": "Blocked by the recipient",
+	"30005": "Unknown or inactive number",
+	"30006": "Landline or unreachable carrier",
+	"30007": "Filtered by the carrier",
+	"30008": "Unknown delivery error",
+}
+
+// DeliveryEvent reports the callback as a delivery event. Only final
+// statuses are reported; ok is false for queued, sent and the like.
+func (s TwilioStatus) DeliveryEvent(at time.Time) (DeliveryEvent, bool) {
+	event := DeliveryEvent{Provider: "twilio", ProviderID: s.MessageSID, At: at}
+	switch s.Status {
+	case "delivered", "read":
+		event.Status = DeliveryDelivered
+	case "undelivered", "failed":
+		event.Status = DeliveryUndelivered
+		event.Detail = twilioErrors[s.ErrorCode]
+		if event.Detail == "" && s.ErrorCode != "" {
+			event.Detail = "Twilio error " + s.ErrorCode
+		}
+	default:
+		return event, false
+	}
+	return event, s.MessageSID != ""
+}
+
+// TwilioSignature computes the X-Twilio-Signature Twilio sends with a
+// webhook request to fullURL carrying form params.
+func TwilioSignature(authToken, fullURL string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var payload strings.Builder
+	payload.WriteString(fullURL)
+	for _, key := range keys {
+		for _, value := range params[key] {
+			payload.WriteString(key)
+			payload.WriteString(value)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(payload.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidTwilioSignature reports whether signature is what Twilio would send
+// for the request. An empty auth token never validates.
+func ValidTwilioSignature(authToken, fullURL string, params url.Values, signature string) bool {
+	if authToken == "" || signature == "" {
+		return false
+	}
+	expected := TwilioSignature(authToken, fullURL, params)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// ValidateRequest checks a webhook request against this account's auth
+// token.
+func (s *TwilioService) ValidateRequest(fullURL string, params url.Values, signature string) bool {
+	return ValidTwilioSignature(s.authToken, fullURL, params, signature)
+}
+
+// FakeInboundSMS builds the form payload and signature Twilio would post to
+// fullURL for a text from one number to another. It stands in for Twilio
+// when exercising the webhook locally.
+func FakeInboundSMS(authToken, fullURL, from, to, body string) (url.Values, string) {
+	params := url.Values{}
+	params.Set("MessageSid", "SMfake"+strings.ReplaceAll(uuid.NewString(), "-", ""))
+	params.Set("AccountSid", "ACfake")
+	params.Set("From", from)
+	params.Set("To", to)
+	params.Set("Body", body)
+	params.Set("NumMedia", "0")
+	return params, TwilioSignature(authToken, fullURL, params)
+}
+
+// TwiMLMessage returns a TwiML response that replies with text, or an empty
+// response when text is blank.
+func TwiMLMessage(text string) string {
+	if text == "" {
+		return xml.Header + "<Response></Response>"
+	}
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return xml.Header + "<Response><Message>" + escaped.String() + "</Message></Response>"
+}
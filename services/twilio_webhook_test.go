@@ -0,0 +1,63 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// The example in Twilio's webhook security documentation.
+func TestTwilioSignatureMatchesTwilioExample(t *testing.T) {
+	params := url.Values{}
+	params.Set("CallSid", "CA1234567890ABCDE")
+	params.Set("Caller", "+12349013030")
+	params.Set("Digits", "1234")
+	params.Set("From", "+12349013030")
+	params.Set("To", "+18005551212")
+	fullURL := "https://mycompany.com/myapp.php?foo=1&bar=2"
+
+	const want = "0/KCTR6DLpKmkAf8muzZqo1nDgQ="
+	if got := TwilioSignature("12345", fullURL, params); got != want {
+		t.Errorf("TwilioSignature = %q, want %q", got, want)
+	}
+	if !ValidTwilioSignature("12345", fullURL, params, want) {
+		t.Error("ValidTwilioSignature rejected Twilio's example")
+	}
+	params.Set("Digits", "4321")
+	if ValidTwilioSignature("12345", fullURL, params, want) {
+		t.Error("ValidTwilioSignature accepted changed params")
+	}
+	if ValidTwilioSignature("", fullURL, params, TwilioSignature("", fullURL, params)) {
+		t.Error("ValidTwilioSignature accepted an empty auth token")
+	}
+}
+
+func TestTwilioStatusDeliveryEvent(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status    TwilioStatus
+		ok        bool
+		want      string
+		wantError string
+	}{
+		{TwilioStatus{MessageSID: "SM1", Status: "delivered"}, true, DeliveryDelivered, ""},
+		{TwilioStatus{MessageSID: "SM1", Status: "read"}, true, DeliveryDelivered, ""},
+		{TwilioStatus{MessageSID: "SM1", Status: "undelivered", ErrorCode: "30006"}, true, DeliveryUndelivered, "Landline or unreachable carrier"},
+		{TwilioStatus{MessageSID: "SM1", Status: "failed", ErrorCode: "21610"}, true, DeliveryUndelivered, "Recipient has unsubscribed from our number"},
+		{TwilioStatus{MessageSID: "SM1", Status: "failed", ErrorCode: "12345"}, true, DeliveryUndelivered, "Twilio error 12345"},
+		{TwilioStatus{MessageSID: "SM1", Status: "undelivered"}, true, DeliveryUndelivered, ""},
+		{TwilioStatus{MessageSID: "SM1", Status: "queued"}, false, "", ""},
+		{TwilioStatus{MessageSID: "SM1", Status: "sent"}, false, "", ""},
+		{TwilioStatus{MessageSID: "SM1", Status: "accepted"}, false, "", ""},
+		{TwilioStatus{Status: "delivered"}, false, DeliveryDelivered, ""},
+	}
+	for _, tt := range tests {
+		event, ok := tt.status.DeliveryEvent(at)
+		if ok != tt.ok || event.Status != tt.want || event.Detail != tt.wantError {
+			t.Errorf("%+v: got %q %q, %v; want %q %q, %v", tt.status, event.Status, event.Detail, ok, tt.want, tt.wantError, tt.ok)
+		}
+		if ok && (event.Provider != "twilio" || event.ProviderID != "SM1" || !event.At.Equal(at)) {
+			t.Errorf("%+v: event = %+v", tt.status, event)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package workers
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// testDatabase returns an empty database on the MongoDB server at
+// TEST_MONGODB_URI, dropped when the test ends. Tests that need one are
+// skipped when it isn't set. It is a copy of the helper in routes/db_test.go,
+// since test files can't be imported from another package; keep the two
+// the same.
+func testDatabase(t *testing.T) *mongo.Database {
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI is not set")
+	}
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(context.TODO(), nil); err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database("test_" + uuid.NewString()[:8])
+	t.Cleanup(func() {
+		db.Drop(context.TODO())
+		client.Disconnect(context.TODO())
+	})
+	return db
+}
@@ -0,0 +1,165 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// DigestSchedule says when the schedule digest goes out and what it covers.
+type DigestSchedule struct {
+	// Weekdays the digest is sent on. None turns the digest off.
+	Weekdays []time.Weekday
+	// Clock is the wall clock time ("15:04") to send at, in the
+	// organization time zone.
+	Clock string
+	// Days is how many days, starting with the send day, the digest covers.
+	Days int
+	// SkipEmpty leaves out members with nothing scheduled in that time.
+	SkipEmpty bool
+}
+
+// DigestWorker sends every member a digest of their upcoming assignments
+// across all teams. The digest goes by email where the member gets email,
+// with the assignments attached as a calendar file, and as a short text on
+// their other channels otherwise. Each day's digest
+// is claimed in the database while it is queued, so several app instances
+// can run the worker side by side, and one that fails is tried again on the
+// next run.
+type DigestWorker struct {
+	db       *mongo.Database
+	notifier *services.Notifier
+	schedule DigestSchedule
+}
+
+func NewDigestWorker(db *mongo.Database, notifier *services.Notifier, schedule DigestSchedule) *DigestWorker {
+	return &DigestWorker{
+		db:       db,
+		notifier: notifier,
+		schedule: schedule,
+	}
+}
+
+// Start runs the worker every interval until ctx is cancelled.
+func (w *DigestWorker) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := w.RunOnce(time.Now()); err != nil {
+				log.Print("Error sending schedule digests: ", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce queues the digest if one is due at now. A digest is due from its
+// send time until the end of the send day, so one missed while the app was
+// down still goes out later that day.
+func (w *DigestWorker) RunOnce(now time.Time) error {
+	local := now.In(models.Location())
+	if !slices.Contains(w.schedule.Weekdays, local.Weekday()) {
+		return nil
+	}
+	sendAt, ok := models.AtClock(local, w.schedule.Clock)
+	if !ok || now.Before(sendAt) {
+		return nil
+	}
+
+	start := models.DateOnly(local)
+	end := start.AddDate(0, 0, max(w.schedule.Days, 1)-1)
+	claimed, err := models.ClaimDigest(w.db, start, end, now)
+	if err != nil || !claimed {
+		return err
+	}
+	if err := w.sendDigests(start, end, now); err != nil {
+		if releaseErr := models.ReleaseDigest(w.db, start); releaseErr != nil {
+			log.Print("Error releasing schedule digest: ", releaseErr)
+		}
+		return err
+	}
+	return models.CompleteDigest(w.db, start, now)
+}
+
+// sendDigests queues the digest for every member. Members whose message
+// can't be queued make it return an error, so the digest is retried; the
+// idempotency key keeps the others from getting it twice.
+func (w *DigestWorker) sendDigests(start, end, now time.Time) error {
+	schedules, err := models.GetMembersSchedules(w.db, start, end.Add(24*time.Hour-time.Nanosecond))
+	if err != nil {
+		return err
+	}
+	members, err := models.GetAllMembers(w.db)
+	if err != nil {
+		return err
+	}
+	byID := map[string]*models.Member{}
+	for i := range members {
+		byID[members[i].ID] = &members[i]
+	}
+	teams, err := models.GetAllTeams(w.db)
+	if err != nil {
+		return err
+	}
+	teamNames := map[string]string{}
+	for _, team := range teams {
+		teamNames[team.ID] = team.Name
+	}
+
+	period := models.FormatPeriod(start, end)
+	available := w.notifier.ChannelNames()
+	var errs []error
+	for _, schedule := range schedules {
+		member, ok := byID[schedule.ID]
+		if !ok {
+			continue
+		}
+		lines := models.NewScheduleLines(schedule.Events, teamNames, now)
+		if len(lines) == 0 && w.schedule.SkipEmpty {
+			continue
+		}
+		data := models.NewMessageData(member, nil)
+		data.Period = period
+		data.Schedule = lines
+		key := "digest:" + start.Format("2006-01-02") + ":" + member.ID
+		msg, err := models.RenderMemberMessage(w.db, models.TemplateDigest, data, key)
+		if err != nil {
+			log.Print("Error rendering digest for member ", member.ID, ": ", err)
+			continue
+		}
+		if len(lines) > 0 {
+			msg.Attachments = []models.MessageAttachment{{
+				Filename:    "schedule.ics",
+				ContentType: "text/calendar",
+				Data:        models.MemberScheduleICS(member.ID, schedule.Events, teamNames, now),
+			}}
+		}
+		if err := models.EnqueueMemberMessage(w.db, member, digestChannels(member, available), msg); err != nil {
+			errs = append(errs, fmt.Errorf("member %s: %w", member.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// digestChannels picks where a member's digest goes: email when they get
+// email, since the full schedule reads best there, and the short text
+// version on their other channels when they don't.
+func digestChannels(member *models.Member, available []string) []string {
+	channels := member.Channels(available)
+	if slices.Contains(channels, models.ChannelEmail) && member.Email != "" {
+		return []string{models.ChannelEmail}
+	}
+	return channels
+}
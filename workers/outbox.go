@@ -0,0 +1,153 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Retry delays grow from outboxBaseDelay, doubling with each attempt, up to
+// outboxMaxDelay.
+const (
+	outboxBaseDelay = 30 * time.Second
+	outboxMaxDelay  = time.Hour
+)
+
+// OutboxWorker delivers queued messages through the notifier. Messages are
+// claimed one at a time, so several app instances can run the worker side
+// by side.
+type OutboxWorker struct {
+	db       *mongo.Database
+	notifier *services.Notifier
+}
+
+func NewOutboxWorker(db *mongo.Database, notifier *services.Notifier) *OutboxWorker {
+	return &OutboxWorker{
+		db:       db,
+		notifier: notifier,
+	}
+}
+
+// Start runs the worker every interval until ctx is cancelled.
+func (w *OutboxWorker) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := w.RunOnce(time.Now()); err != nil {
+				log.Print("Error delivering outbox: ", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce delivers every message that is due at now.
+func (w *OutboxWorker) RunOnce(now time.Time) error {
+	for {
+		msg, err := models.ClaimOutboxMessage(w.db, now)
+		if err != nil {
+			return err
+		}
+		if msg == nil {
+			return nil
+		}
+		if err := w.deliver(msg, now); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *OutboxWorker) deliver(msg *models.OutboxMessage, now time.Time) error {
+	// Preferences may have changed since the message was queued
+	if msg.MemberID != "" {
+		member, err := models.GetMemberByID(w.db, msg.MemberID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.SkipOutboxMessage(w.db, msg.ID, "member no longer exists")
+		}
+		if err != nil {
+			return err
+		}
+		if !member.Accepts(msg.Channel) {
+			return models.SkipOutboxMessage(w.db, msg.ID, "member does not accept "+msg.Channel+" messages")
+		}
+		if until := member.QuietUntil(now); !until.IsZero() {
+			return models.DeferOutboxMessage(w.db, msg.ID, until)
+		}
+	}
+
+	receipt, sendErr := w.notifier.Send(services.Message{
+		Channel:     services.Channel(msg.Channel),
+		To:          msg.To,
+		Subject:     msg.Subject,
+		Body:        msg.Body,
+		HTML:        msg.HTML,
+		Actions:     outboxActions(msg.Actions),
+		Attachments: outboxAttachments(msg.Attachments),
+	})
+	if sendErr == nil {
+		return models.MarkOutboxSent(w.db, msg.ID, receipt.Provider, receipt.ProviderID)
+	}
+
+	log.Print("Error sending outbox message ", msg.ID, ": ", sendErr)
+	if msg.Attempts >= models.OutboxMaxAttempts || permanentSendError(sendErr) {
+		return models.MarkOutboxFailed(w.db, msg.ID, sendErr, nil)
+	}
+	retryAt := now.Add(outboxBackoff(msg.Attempts))
+	return models.MarkOutboxFailed(w.db, msg.ID, sendErr, &retryAt)
+}
+
+// permanentSendError reports whether retrying a failed send is pointless:
+// there is no provider to retry against, or every provider refused the
+// message in a way another attempt won't fix, such as a landline number or
+// a rejected API key.
+func permanentSendError(err error) bool {
+	if errors.Is(err, services.ErrNoProvider) {
+		return true
+	}
+	// The notifier joins the error from each provider it tried
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		if !errors.Is(err, services.ErrTextInChurchInvalidNumber) && !errors.Is(err, services.ErrTextInChurchUnauthorized) {
+			return false
+		}
+	}
+	return true
+}
+
+func outboxActions(actions []models.MessageAction) []services.Action {
+	var out []services.Action
+	for _, a := range actions {
+		out = append(out, services.Action{Label: a.Label, Data: a.Data})
+	}
+	return out
+}
+
+func outboxAttachments(attachments []models.MessageAttachment) []services.Attachment {
+	var out []services.Attachment
+	for _, a := range attachments {
+		out = append(out, services.Attachment{Filename: a.Filename, ContentType: a.ContentType, Data: a.Data})
+	}
+	return out
+}
+
+// outboxBackoff returns the delay before the attempt after attempt.
+func outboxBackoff(attempt int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempt && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxDelay)
+}
@@ -0,0 +1,161 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempt); got != tt.want {
+			t.Errorf("outboxBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestPermanentSendError(t *testing.T) {
+	invalid := &services.TextInChurchError{StatusCode: 422, Code: "landline"}
+	unauthorized := &services.TextInChurchError{StatusCode: 401}
+	unavailable := &services.TextInChurchError{StatusCode: 503}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no provider", fmt.Errorf("%w: sms", services.ErrNoProvider), true},
+		{"invalid number", errors.Join(fmt.Errorf("textinchurch: %w", invalid)), true},
+		{"every provider refused", errors.Join(fmt.Errorf("textinchurch: %w", invalid), fmt.Errorf("other: %w", unauthorized)), true},
+		{"one provider unavailable", errors.Join(fmt.Errorf("textinchurch: %w", invalid), fmt.Errorf("twilio: %w", unavailable)), false},
+		{"unavailable", unavailable, false},
+		{"other", errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		if got := permanentSendError(tt.err); got != tt.want {
+			t.Errorf("%s: permanentSendError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func getOutboxMessage(t *testing.T, db *mongo.Database, id string) models.OutboxMessage {
+	t.Helper()
+	var msg models.OutboxMessage
+	if err := db.Collection(models.OutboxCollection).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func enqueueSMS(t *testing.T, db *mongo.Database, now time.Time) *models.OutboxMessage {
+	t.Helper()
+	msg, err := models.EnqueueMessage(db, models.OutboxMessage{
+		Channel:       models.ChannelSMS,
+		To:            "+15555550100",
+		Body:          "Hello",
+		NextAttemptAt: now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestOutboxWorkerFallsBack(t *testing.T) {
+	db := testDatabase(t)
+	now := time.Now().Truncate(time.Millisecond)
+
+	down := services.NewFakeProvider(services.ChannelSMS, "")
+	down.Fail = errors.New("carrier down")
+	backup := services.NewFakeProvider(services.ChannelSMS, "")
+	worker := NewOutboxWorker(db, services.NewNotifier(down, backup))
+
+	queued := enqueueSMS(t, db, now)
+	if err := worker.RunOnce(now); err != nil {
+		t.Fatal(err)
+	}
+	msg := getOutboxMessage(t, db, queued.ID)
+	if msg.Status != models.OutboxSent || msg.Attempts != 1 || msg.ProviderID != "fake-sms-1" {
+		t.Errorf("message = %s after %d attempts via %q, want sent after 1 via fake-sms-1", msg.Status, msg.Attempts, msg.ProviderID)
+	}
+	if sent := backup.Sent(); len(sent) != 1 || sent[0].To != "+15555550100" {
+		t.Errorf("backup sent %+v", sent)
+	}
+}
+
+func TestOutboxWorkerRetriesThenFails(t *testing.T) {
+	db := testDatabase(t)
+	now := time.Now().Truncate(time.Millisecond)
+
+	down := services.NewFakeProvider(services.ChannelSMS, "")
+	down.Fail = errors.New("carrier down")
+	worker := NewOutboxWorker(db, services.NewNotifier(down))
+
+	queued := enqueueSMS(t, db, now)
+	for attempt := 1; attempt < models.OutboxMaxAttempts; attempt++ {
+		if err := worker.RunOnce(now); err != nil {
+			t.Fatal(err)
+		}
+		msg := getOutboxMessage(t, db, queued.ID)
+		if msg.Status != models.OutboxPending || msg.Attempts != attempt {
+			t.Fatalf("attempt %d: message is %s after %d attempts", attempt, msg.Status, msg.Attempts)
+		}
+		if want := now.Add(outboxBackoff(attempt)); !msg.NextAttemptAt.Equal(want) {
+			t.Errorf("attempt %d: next attempt at %v, want %v", attempt, msg.NextAttemptAt, want)
+		}
+
+		// Nothing is sent before the retry is due
+		if err := worker.RunOnce(msg.NextAttemptAt.Add(-time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		if got := getOutboxMessage(t, db, queued.ID).Attempts; got != attempt {
+			t.Fatalf("attempt %d: retried early, %d attempts", attempt, got)
+		}
+		now = msg.NextAttemptAt
+	}
+
+	if err := worker.RunOnce(now); err != nil {
+		t.Fatal(err)
+	}
+	msg := getOutboxMessage(t, db, queued.ID)
+	if msg.Status != models.OutboxFailed || msg.Attempts != models.OutboxMaxAttempts {
+		t.Errorf("message is %s after %d attempts, want failed after %d", msg.Status, msg.Attempts, models.OutboxMaxAttempts)
+	}
+	if !strings.Contains(msg.LastError, "carrier down") {
+		t.Errorf("last error = %q", msg.LastError)
+	}
+}
+
+func TestOutboxWorkerFailsWithoutProvider(t *testing.T) {
+	db := testDatabase(t)
+	now := time.Now().Truncate(time.Millisecond)
+	worker := NewOutboxWorker(db, services.NewNotifier(services.NewFakeProvider(services.ChannelEmail, "")))
+
+	queued := enqueueSMS(t, db, now)
+	if err := worker.RunOnce(now); err != nil {
+		t.Fatal(err)
+	}
+	if msg := getOutboxMessage(t, db, queued.ID); msg.Status != models.OutboxFailed || msg.Attempts != 1 {
+		t.Errorf("message is %s after %d attempts, want failed after 1", msg.Status, msg.Attempts)
+	}
+}
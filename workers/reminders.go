@@ -0,0 +1,133 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ReminderWorker periodically queues event reminders for assigned members.
+// Each reminder is claimed in the database before it is queued, so several
+// app instances can run the worker side by side; the outbox worker handles
+// delivery and retries.
+type ReminderWorker struct {
+	db       *mongo.Database
+	notifier *services.Notifier
+	signer   *services.LinkSigner
+}
+
+func NewReminderWorker(db *mongo.Database, notifier *services.Notifier, signer *services.LinkSigner) *ReminderWorker {
+	return &ReminderWorker{
+		db:       db,
+		notifier: notifier,
+		signer:   signer,
+	}
+}
+
+// Start runs the worker every interval until ctx is cancelled.
+func (w *ReminderWorker) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := w.RunOnce(time.Now()); err != nil {
+				log.Print("Error sending reminders: ", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce queues every reminder that is due at now.
+func (w *ReminderWorker) RunOnce(now time.Time) error {
+	events, err := models.GetEventsAwaitingReminder(w.db, now)
+	if err != nil {
+		return err
+	}
+	for i := range events {
+		event := &events[i]
+		if now.Before(event.ReminderAt()) {
+			continue
+		}
+		// Don't remind people about something that has already started
+		if !event.StartAt.IsZero() && now.After(event.StartAt) {
+			continue
+		}
+		claimed, err := models.ClaimEventReminder(w.db, event.ID, now)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		if err := w.sendEventReminder(event); err != nil {
+			log.Print("Error sending reminder for event ", event.ID, ": ", err)
+		}
+	}
+	return nil
+}
+
+func (w *ReminderWorker) sendEventReminder(event *models.Event) error {
+	positions := map[string][]models.PositionAssignment{}
+	var memberIDs []string
+	for _, pa := range event.PositionAssignments {
+		if pa.MemberID == "" {
+			continue
+		}
+		if _, ok := positions[pa.MemberID]; !ok {
+			memberIDs = append(memberIDs, pa.MemberID)
+		}
+		positions[pa.MemberID] = append(positions[pa.MemberID], pa)
+	}
+	if len(memberIDs) == 0 {
+		return nil
+	}
+
+	members, err := models.GetMembersByIDs(w.db, memberIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		var names []string
+		for _, pa := range positions[member.ID] {
+			names = append(names, pa.PositionName)
+		}
+		data := models.NewMessageData(&member, event, names...)
+		data.Assignments = assignmentLinks(w.signer, event, positions[member.ID])
+		if len(data.Assignments) > 0 {
+			data.ConfirmLink, data.DeclineLink = data.Assignments[0].ConfirmLink, data.Assignments[0].DeclineLink
+		}
+		key := "reminder:" + event.ID + ":" + member.ID + ":" + strconv.FormatInt(event.StartAt.Unix(), 10)
+		msg, err := models.RenderMemberMessage(w.db, models.TemplateReminder, data, key)
+		if err != nil {
+			log.Print("Error rendering reminder for member ", member.ID, ": ", err)
+			continue
+		}
+		msg.Actions = models.AssignmentActions(positions[member.ID])
+		if err := models.EnqueueMemberMessage(w.db, &member, w.notifier.ChannelNames(), msg); err != nil {
+			log.Print("Error queuing reminder for member ", member.ID, ": ", err)
+		}
+	}
+	return nil
+}
+
+// assignmentLinks makes signed links for confirming or declining each of
+// positions.
+func assignmentLinks(signer *services.LinkSigner, event *models.Event, positions []models.PositionAssignment) []models.AssignmentLinks {
+	var links []models.AssignmentLinks
+	for _, pa := range positions {
+		confirm, decline := signer.AssignmentURLs(event.ID, pa.ID, pa.MemberID, event.LinkExpiry())
+		links = append(links, models.AssignmentLinks{Position: pa.PositionName, ConfirmLink: confirm, DeclineLink: decline})
+	}
+	return links
+}
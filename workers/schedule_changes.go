@@ -0,0 +1,151 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/bcrowe306/nltst_scheduler.git/models"
+	"github.com/bcrowe306/nltst_scheduler.git/services"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ScheduleChangeWorker tells members about changes to their schedule.
+// Changes are held until a member's schedule has been left alone for the
+// batching delay, so editing a whole event sends each person one message.
+// A member whose schedule keeps changing still hears within maxWait.
+type ScheduleChangeWorker struct {
+	db       *mongo.Database
+	notifier *services.Notifier
+	signer   *services.LinkSigner
+	delay    time.Duration
+	maxWait  time.Duration
+}
+
+func NewScheduleChangeWorker(db *mongo.Database, notifier *services.Notifier, signer *services.LinkSigner, delay time.Duration) *ScheduleChangeWorker {
+	return &ScheduleChangeWorker{
+		db:       db,
+		notifier: notifier,
+		signer:   signer,
+		delay:    delay,
+		maxWait:  6 * delay,
+	}
+}
+
+// Start runs the worker every interval until ctx is cancelled.
+func (w *ScheduleChangeWorker) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := w.RunOnce(time.Now()); err != nil {
+				log.Print("Error sending schedule changes: ", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce queues a message for every member whose changes have settled at
+// now. A batch is only marked sent once its message is queued; one that
+// fails is tried again on the next run under the same batch ID, whose
+// idempotency key stops channels that were queued already from repeating.
+func (w *ScheduleChangeWorker) RunOnce(now time.Time) error {
+	memberIDs, err := models.GetMembersWithSettledChanges(w.db, now.Add(-w.delay), now.Add(-w.maxWait))
+	if err != nil {
+		return err
+	}
+	for _, memberID := range memberIDs {
+		batchID, changes, err := models.ClaimScheduleChanges(w.db, memberID)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if err := w.sendChanges(memberID, batchID, changes); err != nil {
+			log.Print("Error sending schedule changes to member ", memberID, ": ", err)
+			continue
+		}
+		if err := models.MarkScheduleChangesSent(w.db, batchID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *ScheduleChangeWorker) sendChanges(memberID, batchID string, changes []models.ScheduleChange) error {
+	events := map[string]*models.Event{}
+	var lines []models.ChangeLine
+	var added []models.PositionAssignment
+	for _, change := range models.NetScheduleChanges(changes) {
+		event, ok := events[change.EventID]
+		if !ok {
+			// The event may have been deleted since; describe it from the
+			// change alone
+			event, _ = models.GetEventByID(w.db, change.EventID)
+			events[change.EventID] = event
+		}
+		line := models.ChangeLine{Text: describeScheduleChange(change, event)}
+		if line.Text == "" {
+			continue
+		}
+		// New assignments can be confirmed straight from the message
+		if change.Kind == models.ChangeAssigned {
+			if pa := event.FindPosition(change.PositionID); pa != nil && pa.MemberID == memberID {
+				line.ConfirmLink, line.DeclineLink = w.signer.AssignmentURLs(event.ID, pa.ID, memberID, event.LinkExpiry())
+				added = append(added, *pa)
+			}
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	member, err := models.GetMemberByID(w.db, memberID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Nobody left to tell
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data := models.NewMessageData(member, nil)
+	data.Changes = lines
+	msg, err := models.RenderMemberMessage(w.db, models.TemplateScheduleChange, data, "schedule-change:"+batchID)
+	if err != nil {
+		return err
+	}
+	msg.Actions = models.AssignmentActions(added)
+	return models.EnqueueMemberMessage(w.db, member, w.notifier.ChannelNames(), msg)
+}
+
+// describeScheduleChange describes one change for a schedule change
+// message, or returns "" when there is nothing left to say.
+func describeScheduleChange(change models.ScheduleChange, event *models.Event) string {
+	name, when := change.EventName, ""
+	if event != nil {
+		name, when = event.Name, " on "+event.When()
+	}
+	switch change.Kind {
+	case models.ChangeAssigned:
+		if event == nil {
+			return ""
+		}
+		return "Added: " + change.PositionName + " for " + name + when
+	case models.ChangeRemoved:
+		return "Removed: " + change.PositionName + " for " + name + when
+	case models.ChangeMoved:
+		if event == nil || event.When() == change.PreviousWhen {
+			return ""
+		}
+		return "Moved: " + name + " is now " + event.When() + " (was " + change.PreviousWhen + ")"
+	}
+	return ""
+}